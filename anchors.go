@@ -7,8 +7,10 @@
 package goat
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"net/netip"
 	"net/url"
 )
@@ -109,15 +111,22 @@ type anchorListingPage struct {
 
 // AnchorFilter struct holds specified filters and other options
 type AnchorFilter struct {
+	client  *Client
 	params  url.Values
 	id      uint
 	limit   uint
 	verbose bool
 }
 
-// NewAnchorFilter prepares a new anchor filter object
+// NewAnchorFilter prepares a new anchor filter object using the default client
 func NewAnchorFilter() AnchorFilter {
+	return defaultClient.NewAnchorFilter()
+}
+
+// NewAnchorFilter prepares a new anchor filter object using this client
+func (client *Client) NewAnchorFilter() AnchorFilter {
 	filter := AnchorFilter{}
+	filter.client = client
 	filter.params = url.Values{}
 	return filter
 }
@@ -174,6 +183,14 @@ func (filter *AnchorFilter) verifyFilters() error {
 func (filter *AnchorFilter) GetAnchorCount() (
 	count uint,
 	err error,
+) {
+	return filter.GetAnchorCountContext(context.Background())
+}
+
+// GetAnchorCountContext is like GetAnchorCount but the API call can be cancelled via the context
+func (filter *AnchorFilter) GetAnchorCountContext(ctx context.Context) (
+	count uint,
+	err error,
 ) {
 	// sanity checks - late in the process, but not too late
 	err = filter.verifyFilters()
@@ -183,14 +200,19 @@ func (filter *AnchorFilter) GetAnchorCount() (
 
 	// counting needs application of the specified filters
 	filter.params.Add("page_size", "0")
-	query := apiBaseURL + "anchors/?" + filter.params.Encode()
+	client := clientOrDefault(filter.client)
+	query := client.apiBaseURL + "anchors/?" + filter.params.Encode()
 
-	resp, err := apiGetRequest(filter.verbose, query, nil)
+	resp, err := client.apiGetRequest(ctx, filter.verbose, query, client.apiKeyFor(nil, ApiKeyDefault))
 	if err != nil {
 		return 0, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != 200 {
+		return 0, parseAPIError(resp)
+	}
+
 	// grab and store the actual content
 	var page anchorListingPage
 	err = json.NewDecoder(resp.Body).Decode(&page)
@@ -206,41 +228,54 @@ func (filter *AnchorFilter) GetAnchorCount() (
 // Results (or an error) appear on a channel
 func (filter *AnchorFilter) GetAnchors(
 	anchors chan AsyncAnchorResult,
+) {
+	filter.GetAnchorsContext(context.Background(), anchors)
+}
+
+// GetAnchorsContext is like GetAnchors, but it stops and closes the channel
+// when the context is cancelled
+func (filter *AnchorFilter) GetAnchorsContext(
+	ctx context.Context,
+	anchors chan AsyncAnchorResult,
 ) {
 	defer close(anchors)
 
+	client := clientOrDefault(filter.client)
+
 	// special case: a specific ID was "filtered"
 	if filter.id != 0 {
-		anchor, err := GetAnchor(filter.verbose, filter.id)
+		anchor, err := client.GetAnchorContext(ctx, filter.verbose, filter.id)
 		if err != nil {
-			anchors <- AsyncAnchorResult{Anchor{}, err}
+			sendContext(ctx, anchors, AsyncAnchorResult{Anchor{}, err})
 			return
 		}
-		anchors <- AsyncAnchorResult{*anchor, nil}
+		sendContext(ctx, anchors, AsyncAnchorResult{*anchor, nil})
 		return
 	}
 
 	// sanity checks - late in the process, but not too late
 	err := filter.verifyFilters()
 	if err != nil {
-		anchors <- AsyncAnchorResult{Anchor{}, err}
+		sendContext(ctx, anchors, AsyncAnchorResult{Anchor{}, err})
 		return
 	}
 
-	query := apiBaseURL + "anchors/?" + filter.params.Encode()
+	key := client.apiKeyFor(nil, ApiKeyDefault)
+	query := client.apiBaseURL + "anchors/?" + filter.params.Encode()
 
-	resp, err := apiGetRequest(filter.verbose, query, nil)
+	resp, err := client.apiGetRequest(ctx, filter.verbose, query, key)
 
 	// results are paginated with next= (and previous=)
 	var total uint = 0
 	for {
 		if err != nil {
-			anchors <- AsyncAnchorResult{Anchor{}, err}
+			sendContext(ctx, anchors, AsyncAnchorResult{Anchor{}, err})
+			return
 		}
 		defer resp.Body.Close()
 
 		if resp.StatusCode != 200 {
-			anchors <- AsyncAnchorResult{Anchor{}, err}
+			sendContext(ctx, anchors, AsyncAnchorResult{Anchor{}, parseAPIError(resp)})
 			return
 		}
 
@@ -248,12 +283,15 @@ func (filter *AnchorFilter) GetAnchors(
 		var page anchorListingPage
 		err = json.NewDecoder(resp.Body).Decode(&page)
 		if err != nil {
-			anchors <- AsyncAnchorResult{Anchor{}, err}
+			sendContext(ctx, anchors, AsyncAnchorResult{Anchor{}, err})
+			return
 		}
 
 		// return items while observing the limit
 		for _, anchor := range page.Anchors {
-			anchors <- AsyncAnchorResult{anchor, nil}
+			if !sendContext(ctx, anchors, AsyncAnchorResult{anchor, nil}) {
+				return
+			}
 			total++
 			if total >= filter.limit {
 				return
@@ -266,11 +304,19 @@ func (filter *AnchorFilter) GetAnchors(
 		}
 
 		// just follow the next link
-		resp, err = apiGetRequest(filter.verbose, page.Next, nil)
+		resp, err = client.apiGetRequest(ctx, filter.verbose, page.Next, key)
 	}
 }
 
-// GetAnchor retrieves data for a single anchor, by ID
+// Anchors is an iterator over the anchors matching the filter. Breaking
+// out of the loop stops fetching more pages.
+func (filter *AnchorFilter) Anchors(ctx context.Context) iter.Seq2[Anchor, error] {
+	return iterate(ctx, filter.GetAnchorsContext, func(item AsyncAnchorResult) (Anchor, error) {
+		return item.Anchor, item.Error
+	})
+}
+
+// GetAnchor retrieves data for a single anchor, by ID, using the default client
 // returns anchor, _ if an anchor was found
 // returns nil, _ if an anchor was not found
 // returns _, err on error
@@ -281,9 +327,44 @@ func GetAnchor(
 	anchor *Anchor,
 	err error,
 ) {
-	query := fmt.Sprintf("%sanchors/%d/", apiBaseURL, id)
+	return defaultClient.GetAnchor(verbose, id)
+}
+
+// GetAnchorContext is like GetAnchor but the API call can be cancelled via the context
+func GetAnchorContext(
+	ctx context.Context,
+	verbose bool,
+	id uint,
+) (
+	anchor *Anchor,
+	err error,
+) {
+	return defaultClient.GetAnchorContext(ctx, verbose, id)
+}
+
+// GetAnchor retrieves data for a single anchor, by ID, using this client
+func (client *Client) GetAnchor(
+	verbose bool,
+	id uint,
+) (
+	anchor *Anchor,
+	err error,
+) {
+	return client.GetAnchorContext(context.Background(), verbose, id)
+}
+
+// GetAnchorContext is like GetAnchor but the API call can be cancelled via the context
+func (client *Client) GetAnchorContext(
+	ctx context.Context,
+	verbose bool,
+	id uint,
+) (
+	anchor *Anchor,
+	err error,
+) {
+	query := fmt.Sprintf("%sanchors/%d/", client.apiBaseURL, id)
 
-	resp, err := apiGetRequest(verbose, query, nil)
+	resp, err := client.apiGetRequest(ctx, verbose, query, client.apiKeyFor(nil, ApiKeyDefault))
 	if err != nil {
 		return
 	}
@@ -0,0 +1,263 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// API key purposes, these match the permissions that can be assigned to
+// API keys. The empty purpose means the key is used for everything.
+const (
+	ApiKeyDefault            = ""
+	ApiKeyListMeasurements   = "list_measurements"
+	ApiKeyCreateMeasurements = "create_measurements"
+	ApiKeyStopMeasurements   = "stop_measurements"
+	ApiKeyUpdateMeasurements = "update_measurements"
+	ApiKeyGetCredits         = "get_credits"
+	ApiKeyTransferCredits    = "transfer_credits"
+)
+
+// Client holds everything needed to talk to a particular (RIPE Atlas
+// compatible) API: base URLs, user agent, API keys and the HTTP client.
+// Filters and measurement specifications made by a client use its settings.
+type Client struct {
+	apiBaseURL    string
+	streamBaseURL string
+	userAgent     string
+	keys          map[string]uuid.UUID
+	httpClient    *http.Client
+	timeout       time.Duration // applies to non-GET calls
+	retry         RetryPolicy
+}
+
+// the client used by the package level functions
+var defaultClient = NewClient()
+
+// NewClient prepares a new client object with the default settings
+func NewClient() *Client {
+	client := Client{}
+	client.apiBaseURL = "https://atlas.ripe.net/api/v2/"
+	client.streamBaseURL = "wss://atlas-stream.ripe.net/stream/"
+	client.userAgent = "goat " + version
+	client.keys = make(map[string]uuid.UUID)
+	client.httpClient = &http.Client{}
+	client.timeout = time.Second * 15
+	client.retry = DefaultRetryPolicy()
+	return &client
+}
+
+// DefaultClient returns the client used by the package level functions
+func DefaultClient() *Client {
+	return defaultClient
+}
+
+// the default client is used if nothing else was specified
+func clientOrDefault(client *Client) *Client {
+	if client == nil {
+		return defaultClient
+	}
+	return client
+}
+
+// SetAPIBase allows the caller to modify the API to talk to
+func (client *Client) SetAPIBase(newAPIBaseURL string) {
+	// TODO: check sanity of new API base URL
+	client.apiBaseURL = newAPIBaseURL
+}
+
+// APIBase retrieves the currently configured API base URL
+func (client *Client) APIBase() string {
+	return client.apiBaseURL
+}
+
+// SetStreamBase allows the caller to modify the stream to talk to
+func (client *Client) SetStreamBase(newStreamBaseURL string) {
+	// TODO: check sanity of new stream base URL
+	client.streamBaseURL = newStreamBaseURL
+}
+
+// StreamBase retrieves the currently configured stream base URL
+func (client *Client) StreamBase() string {
+	return client.streamBaseURL
+}
+
+// SetUserAgent changes the user agent used in API calls
+func (client *Client) SetUserAgent(ua string) {
+	client.userAgent = ua
+}
+
+// UserAgent returns the user agent used in API calls
+func (client *Client) UserAgent() string {
+	return client.userAgent
+}
+
+// ApiKey sets the API key to be used for a particular purpose (see ApiKey*)
+// The key for ApiKeyDefault is used if there's no key for a specific purpose.
+// Keys set explicitly on filters or specifications take precedence.
+// A nil key removes the key for that purpose.
+func (client *Client) ApiKey(purpose string, key *uuid.UUID) {
+	if key == nil {
+		delete(client.keys, purpose)
+	} else {
+		client.keys[purpose] = *key
+	}
+}
+
+// SetHTTPClient replaces the HTTP client used to make API calls
+func (client *Client) SetHTTPClient(httpClient *http.Client) {
+	client.httpClient = httpClient
+}
+
+// SetTransport replaces the transport (round tripper) of the HTTP client
+func (client *Client) SetTransport(transport http.RoundTripper) {
+	client.httpClient.Transport = transport
+}
+
+// SetTimeout sets the timeout for API calls that make changes, i.e. creating
+// or stopping measurements. Downloads (GETs) are not limited by this.
+func (client *Client) SetTimeout(timeout time.Duration) {
+	client.timeout = timeout
+}
+
+// SetRetryPolicy changes how transient API failures are retried
+// Use a policy with MaxAttempts of 1 to turn off retries
+func (client *Client) SetRetryPolicy(policy RetryPolicy) {
+	client.retry = policy
+}
+
+// RetryPolicy returns the current retry policy
+func (client *Client) RetryPolicy() RetryPolicy {
+	return client.retry
+}
+
+// figure out which API key to use: an explicitly specified one, one for this
+// purpose or the default one
+func (client *Client) apiKeyFor(key *uuid.UUID, purpose string) *uuid.UUID {
+	if key != nil {
+		return key
+	}
+	if k, ok := client.keys[purpose]; ok {
+		return &k
+	}
+	if k, ok := client.keys[ApiKeyDefault]; ok {
+		return &k
+	}
+	return nil
+}
+
+func (client *Client) apiGetRequest(
+	ctx context.Context,
+	verbose bool,
+	url string,
+	key *uuid.UUID,
+) (*http.Response, error) {
+	return client.apiRequest(ctx, verbose, "GET", url, key, nil)
+}
+
+// apiRequest makes an API call with the specified method, optionally
+// with some (JSON) content to be sent
+// The request is abandoned if the context is cancelled
+// Transient failures are retried according to the client's retry policy
+func (client *Client) apiRequest(
+	ctx context.Context,
+	verbose bool,
+	method string,
+	url string,
+	key *uuid.UUID,
+	content []byte,
+) (*http.Response, error) {
+	if verbose {
+		msg := fmt.Sprintf("# API call: %s %s", method, url)
+		if content != nil {
+			msg += fmt.Sprintf(" with content '%s'", string(content))
+		}
+		if key != nil {
+			msg += fmt.Sprintf(" (using API key %s...)", (*key).String()[:8])
+		}
+		fmt.Println(msg)
+	}
+
+	httpClient := client.httpClient
+	if method != "GET" {
+		// changes are limited in time, downloads are not
+		limited := *client.httpClient
+		limited.Timeout = client.timeout
+		httpClient = &limited
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := client.newRequest(ctx, method, url, key, content)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := httpClient.Do(req)
+
+		delay, retry := client.retry.shouldRetry(ctx, method, attempt, resp, err)
+		if !retry {
+			return resp, err
+		}
+
+		if verbose {
+			reason := ""
+			if err != nil {
+				reason = err.Error()
+			} else {
+				reason = resp.Status
+			}
+			fmt.Printf("# API call failed (%s), retrying in %v (attempt %d of %d)\n",
+				reason, delay.Round(time.Millisecond), attempt+1, client.retry.MaxAttempts)
+		}
+
+		// the response is not passed on, so get rid of it
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// newRequest prepares an HTTP request with all the necessary headers
+func (client *Client) newRequest(
+	ctx context.Context,
+	method string,
+	url string,
+	key *uuid.UUID,
+	content []byte,
+) (*http.Request, error) {
+	var body *bytes.Buffer
+	if content != nil {
+		body = bytes.NewBuffer(content)
+	} else {
+		body = new(bytes.Buffer)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if method != "GET" {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+	}
+	req.Header.Set("User-Agent", client.userAgent)
+	if key != nil {
+		req.Header.Set("Authorization", "Key "+(*key).String())
+	}
+	return req, nil
+}
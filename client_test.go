@@ -0,0 +1,101 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Test if API key precedence works: explicit, then purpose, then default
+func TestClientApiKeyFor(t *testing.T) {
+	client := NewClient()
+	if client.apiKeyFor(nil, ApiKeyListMeasurements) != nil {
+		t.Error("empty client should not have an API key")
+	}
+
+	def := uuid.New()
+	list := uuid.New()
+	explicit := uuid.New()
+
+	client.ApiKey(ApiKeyDefault, &def)
+	if key := client.apiKeyFor(nil, ApiKeyListMeasurements); key == nil || *key != def {
+		t.Error("default API key not used")
+	}
+	client.ApiKey(ApiKeyListMeasurements, &list)
+	if key := client.apiKeyFor(nil, ApiKeyListMeasurements); key == nil || *key != list {
+		t.Error("purpose specific API key not used")
+	}
+	if key := client.apiKeyFor(nil, ApiKeyCreateMeasurements); key == nil || *key != def {
+		t.Error("default API key not used for other purposes")
+	}
+	if key := client.apiKeyFor(&explicit, ApiKeyListMeasurements); key == nil || *key != explicit {
+		t.Error("explicit API key not used")
+	}
+
+	client.ApiKey(ApiKeyListMeasurements, nil)
+	if key := client.apiKeyFor(nil, ApiKeyListMeasurements); key == nil || *key != def {
+		t.Error("removing purpose specific API key failed")
+	}
+}
+
+// Test if two clients can talk to different APIs with different settings
+func TestClientsAreIndependent(t *testing.T) {
+	type seen struct {
+		ua   string
+		auth string
+	}
+	serve := func(got *seen) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got.ua = r.Header.Get("User-Agent")
+			got.auth = r.Header.Get("Authorization")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"count":42,"next":"","previous":"","results":[]}`))
+		}))
+	}
+
+	var seen1, seen2 seen
+	server1 := serve(&seen1)
+	defer server1.Close()
+	server2 := serve(&seen2)
+	defer server2.Close()
+
+	key := uuid.New()
+	client1 := NewClient()
+	client1.SetAPIBase(server1.URL + "/")
+	client1.SetUserAgent("client-one")
+	client1.ApiKey(ApiKeyDefault, &key)
+	client2 := NewClient()
+	client2.SetAPIBase(server2.URL + "/")
+	client2.SetUserAgent("client-two")
+
+	filter1 := client1.NewProbeFilter()
+	count, err := filter1.GetProbeCount()
+	if err != nil {
+		t.Fatalf("client1 probe count failed: %v", err)
+	}
+	if count != 42 {
+		t.Errorf("client1 probe count is %d, expected 42", count)
+	}
+	filter2 := client2.NewMeasurementFilter()
+	if _, err = filter2.GetMeasurementCount(); err != nil {
+		t.Fatalf("client2 measurement count failed: %v", err)
+	}
+
+	if seen1.ua != "client-one" || seen1.auth != "Key "+key.String() {
+		t.Errorf("client1 sent wrong headers: %+v", seen1)
+	}
+	if seen2.ua != "client-two" || seen2.auth != "" {
+		t.Errorf("client2 sent wrong headers: %+v", seen2)
+	}
+	if DefaultClient().APIBase() == client1.APIBase() || DefaultClient().UserAgent() != UserAgent() {
+		t.Error("default client was affected by other clients")
+	}
+}
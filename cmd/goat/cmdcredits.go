@@ -0,0 +1,136 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/robert-kisteleki/goat"
+	"github.com/robert-kisteleki/goat/cmd/goat/output"
+)
+
+// struct to receive/store command line args for credits
+type creditsFlags struct {
+	income   bool   // list income items
+	expense  bool   // list expense items
+	transfer uint   // transfer this amount...
+	to       string // ...to this user
+	comment  string // ...with this comment
+
+	output  string
+	outopts multioption
+	limit   uint
+}
+
+// Implementation of the "credits" subcommand. Parses command line flags
+// and interacts with goatAPI to show the balance, income or expense items,
+// or to transfer credits
+func commandCredits(args []string) {
+	flags := parseCreditsArgs(args)
+	formatter := flags.output
+
+	if flags.transfer != 0 || flags.to != "" {
+		transferCredits(flags)
+		return
+	}
+
+	if !output.Verify(formatter, "credits") {
+		fmt.Fprintf(os.Stderr, "ERROR: unknown or unsupported output format '%s' for credits\n", formatter)
+		os.Exit(1)
+	}
+	if flags.income && flags.expense {
+		fmt.Fprintf(os.Stderr, "ERROR: income and expense items cannot be listed at the same time\n")
+		os.Exit(1)
+	}
+
+	key := getApiKey("get_credits")
+	if key == nil {
+		fmt.Fprintf(os.Stderr, "ERROR: you need to provide the API key get_credits - please consult the config file\n")
+		os.Exit(1)
+	}
+
+	output.Setup(formatter, flagVerbose, flags.outopts)
+	output.Start(formatter)
+
+	if !flags.income && !flags.expense {
+		// just the balance
+		credits, err := goat.GetCredits(flagVerbose, key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		output.Process(formatter, credits)
+		output.Finish(formatter)
+		return
+	}
+
+	filter := goat.NewCreditItemFilter()
+	filter.Verbose(flagVerbose)
+	filter.ApiKey(key)
+	filter.Limit(flags.limit)
+
+	// most of the work is done by goatAPI
+	items := make(chan goat.AsyncCreditItemResult)
+	if flags.income {
+		go filter.GetIncomeItems(items)
+	} else {
+		go filter.GetExpenseItems(items)
+	}
+
+	for item := range items {
+		if item.Error != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", item.Error)
+			os.Exit(1)
+		} else {
+			output.Process(formatter, item)
+		}
+	}
+	output.Finish(formatter)
+}
+
+// transferCredits does the actual transfer
+func transferCredits(flags *creditsFlags) {
+	if flags.transfer == 0 || flags.to == "" {
+		fmt.Fprintf(os.Stderr, "ERROR: both the amount and the recipient need to be specified for transfers\n")
+		os.Exit(1)
+	}
+
+	key := getApiKey("transfer_credits")
+	if key == nil {
+		fmt.Fprintf(os.Stderr, "ERROR: you need to provide the API key transfer_credits - please consult the config file\n")
+		os.Exit(1)
+	}
+
+	err := goat.TransferCredits(flagVerbose, key, flags.to, flags.transfer, flags.comment)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR while trying to transfer credits: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%d credits have been transferred to %s.\n", flags.transfer, flags.to)
+}
+
+// Define and parse command line args for this subcommand using the flags package
+func parseCreditsArgs(args []string) *creditsFlags {
+	var flags creditsFlags
+
+	// what to do
+	flagsCredits.BoolVar(&flags.income, "income", false, "List income items")
+	flagsCredits.BoolVar(&flags.expense, "expense", false, "List expense items")
+	flagsCredits.UintVar(&flags.transfer, "transfer", 0, "Transfer this many credits (needs -to)")
+	flagsCredits.StringVar(&flags.to, "to", "", "Recipient (e-mail address) of the transfer")
+	flagsCredits.StringVar(&flags.comment, "comment", "", "Comment for the transfer")
+
+	// options
+	flagsCredits.StringVar(&flags.output, "output", "some", "Output format: 'some' or 'most'")
+	flagsCredits.Var(&flags.outopts, "opt", "Options to pass to the output formatter")
+	flagsCredits.UintVar(&flags.limit, "limit", 100, "Maximum amount of items to retrieve")
+
+	_ = flagsCredits.Parse(args)
+
+	return &flags
+}
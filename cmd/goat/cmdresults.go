@@ -7,7 +7,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
@@ -28,17 +30,29 @@ type resultFlags struct {
 	filterPublicProbes bool
 	filterLatest       bool
 	filterLatestDays   int
+	filterMsmIDs       string
+	filterType         string
+	filterAf           uint
+	filterDst          string
+	dump               bool // mixed results, as in the daily dumps
 
 	stream bool
 
-	saveFileName string   // save raw result to this file (name)
-	saveFile     *os.File // save raw result to this file (handle after opening)
+	saveFileName string         // save raw result to this file (name)
+	saveFile     io.WriteCloser // save raw result to this file (handle after opening)
 	saveAll      bool
 	output       string // output formater
 	outopts      multioption
 	limit        uint
 	timeout      uint // stream read timeout in secods
 	backlog      bool // ask for stream result backlog?
+	reconnect    bool // reconnect the stream if it's lost?
+	speed        uint // stream replay speed
+	parallel     uint // number of download workers
+
+	probestatus bool   // stream probe status events instead of results
+	filterCc    string // country filter for probe status events
+	filterAsn   uint   // ASN filter for probe status events
 }
 
 // Implementation stub of the "result" subcommand, starting from command line args
@@ -50,6 +64,11 @@ func commandResult(args []string) {
 // Actual implementation of the "result" subcommand. Based on flags it
 // interacts with goatAPI to apply those filters+options to fetch results
 func commandResultFromFlags(flags *resultFlags) {
+	if flags.probestatus {
+		commandProbeStatus(flags)
+		return
+	}
+
 	filter, options := processResultFlags(flags)
 
 	formatter := options["output"].(string)
@@ -65,7 +84,11 @@ func commandResultFromFlags(flags *resultFlags) {
 	}
 
 	if flags.saveFile != nil {
-		defer flags.saveFile.Close()
+		defer func() {
+			if err := flags.saveFile.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: could not finish writing to logfile (%v)\n", err)
+			}
+		}()
 	}
 
 	if flagVerbose && flags.stream {
@@ -85,10 +108,22 @@ func commandResultFromFlags(flags *resultFlags) {
 	results := make(chan result.AsyncResult)
 	go filter.GetResults(flagVerbose, results)
 
-	output.Setup(formatter, flagVerbose, flags.outopts)
+	outputResults(formatter, flags.outopts, results)
+
+	if flagVerbose && flags.stream {
+		fmt.Printf("# Done listening to the stream at %v\n", time.Now().UTC())
+	}
+}
+
+// outputResults sends incoming results to the output formatter
+func outputResults(formatter string, outopts multioption, results chan result.AsyncResult) {
+	output.Setup(formatter, flagVerbose, outopts)
 	output.Start(formatter)
 	for result := range results {
-		if result.Error != nil {
+		var reconnect *goat.StreamReconnectError
+		if errors.As(result.Error, &reconnect) {
+			fmt.Fprintf(os.Stderr, "WARNING: %s\n", result.Error)
+		} else if result.Error != nil {
 			if strings.Contains(result.Error.Error(), "EOF") {
 				fmt.Fprintf(os.Stderr, "EOF\n")
 			} else {
@@ -99,8 +134,48 @@ func commandResultFromFlags(flags *resultFlags) {
 		}
 	}
 	output.Finish(formatter)
+}
 
-	if flagVerbose && flags.stream {
+// commandProbeStatus streams probe connection events
+func commandProbeStatus(flags *resultFlags) {
+	if !output.Verify(flags.output, "connection") {
+		fmt.Fprintf(os.Stderr, "ERROR: output format '%s' cannot show probe status events\n", flags.output)
+		os.Exit(1)
+	}
+
+	filter := goat.NewProbeStatusFilter()
+	filter.Limit(flags.limit)
+	filter.StreamTimeout(time.Duration(flags.timeout) * time.Second)
+	if flags.filterProbeIDs != "" {
+		list, err := makeIntList(flags.filterProbeIDs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: invalid ID in list: %s\n", flags.filterProbeIDs)
+			os.Exit(1)
+		}
+		filter.FilterProbeIDs(list)
+	}
+	if flags.filterCc != "" {
+		filter.FilterCountry(flags.filterCc)
+	}
+	if flags.filterAsn != 0 {
+		filter.FilterASN(flags.filterAsn)
+	}
+
+	if flagVerbose {
+		fmt.Printf("# Listening to probe status events (at %s), starting at %v\n",
+			goat.GetStreamBase(),
+			time.Now().UTC(),
+		)
+	}
+
+	// events should be shown as they come, not at the end
+	outopts := append(flags.outopts, "live")
+
+	results := make(chan result.AsyncResult)
+	go filter.GetProbeStatus(flagVerbose, results)
+	outputResults(flags.output, outopts, results)
+
+	if flagVerbose {
 		fmt.Printf("# Done listening to the stream at %v\n", time.Now().UTC())
 	}
 }
@@ -181,15 +256,42 @@ func processResultFlags(flags *resultFlags) (
 		filter.FilterLatestLookbackDays(uint(flags.filterLatestDays))
 	}
 
+	if flags.filterMsmIDs != "" {
+		list, err := makeIntList(flags.filterMsmIDs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: invalid ID in list: %s\n", flags.filterMsmIDs)
+			os.Exit(1)
+		}
+		filter.FilterMeasurementIDs(list)
+	}
+	if flags.filterType != "" {
+		filter.FilterType(flags.filterType)
+	}
+	if flags.filterAf != 0 {
+		if flags.filterAf != 4 && flags.filterAf != 6 {
+			fmt.Fprintf(os.Stderr, "ERROR: address family should be 4 or 6\n")
+			os.Exit(1)
+		}
+		filter.FilterAddressFamily(flags.filterAf)
+	}
+	if flags.filterDst != "" {
+		filter.FilterDestination(flags.filterDst)
+	}
+	filter.Dump(flags.dump)
+
 	filter.Stream(flags.stream)
 	filter.SendBacklog(flags.backlog)
+	filter.Reconnect(flags.reconnect)
+	filter.StreamSpeed(flags.speed)
+	filter.Parallel(flags.parallel)
 
 	if flags.saveFileName != "" {
-		f, err := os.Create(flags.saveFileName)
+		f, err := goat.CreateResultFile(flags.saveFileName)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "ERROR: could not write to logfile (%v)\n", err)
 			os.Exit(1)
 		}
+		flags.saveFile = f
 		filter.Save(f)
 	}
 
@@ -212,15 +314,28 @@ func parseResultArgs(args []string) *resultFlags {
 	flagsGetResult.BoolVar(&flags.filterPublicProbes, "public", false, "Filter for public probes only")
 	flagsGetResult.BoolVar(&flags.filterLatest, "latest", false, "Filter for latest results only")
 	flagsGetResult.IntVar(&flags.filterLatestDays, "lookback", -1, "How many days to look back to consider a result. Default is 7.")
+	flagsGetResult.StringVar(&flags.filterMsmIDs, "msm", "", "Filter on measurement ID being on this comma separated list (useful for files)")
+	flagsGetResult.StringVar(&flags.filterType, "type", "", "Filter on result type (ping, traceroute, dns, ...)")
+	flagsGetResult.UintVar(&flags.filterAf, "af", 0, "Filter on address family (4 or 6)")
+	flagsGetResult.StringVar(&flags.filterDst, "dst", "", "Filter on destination name or address")
+	flagsGetResult.BoolVar(&flags.dump, "dump", false, "The input is a daily dump (or other file) mixing results of many measurements and types")
 
 	// options
-	flagsGetResult.StringVar(&flags.saveFileName, "save", "", "Save raw results to this file")
+	flagsGetResult.StringVar(&flags.saveFileName, "save", "", "Save raw results to this file (compressed if it ends with .gz, .xz or .zst)")
 	flagsGetResult.BoolVar(&flags.saveAll, "saveall", false, "Save all retrieved results, not only the ones that matched filters")
 	flagsGetResult.BoolVar(&flags.stream, "stream", false, "Use the result stream")
 	flagsGetResult.StringVar(&flags.output, "output", "some", "Output format: 'some' or 'most' or other available plugins")
 	flagsGetResult.Var(&flags.outopts, "opt", "Options to pass to the output formatter")
 	flagsGetResult.UintVar(&flags.timeout, "timeout", 60, "Timeout in seconds for result streaming")
 	flagsGetResult.BoolVar(&flags.backlog, "backlog", false, "Request backlog when streaming")
+	flagsGetResult.UintVar(&flags.speed, "speed", 0, "Replay speed when streaming past results (with -start), e.g. 10 is ten times real time")
+	flagsGetResult.UintVar(&flags.parallel, "parallel", 0, "Download the results in time slices using this many parallel workers")
+	flagsGetResult.BoolVar(&flags.reconnect, "reconnect", false, "Reconnect the stream if it's lost, and fetch the results missed in the meantime")
+
+	// probe status events
+	flagsGetResult.BoolVar(&flags.probestatus, "probestatus", false, "Stream probe connection events instead of results (-probe, -cc and -asn filters apply)")
+	flagsGetResult.StringVar(&flags.filterCc, "cc", "", "Filter probe connection events for probes in this country")
+	flagsGetResult.UintVar(&flags.filterAsn, "asn", 0, "Filter probe connection events for this ASN")
 
 	// limit
 	flagsGetResult.UintVar(&flags.limit, "limit", 0, "Maximum amount of results to fetch")
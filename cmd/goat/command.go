@@ -39,6 +39,8 @@ func commandSelector() {
 		commandResult(args[1:])
 	case args[0] == "status":
 		commandStatusCheck(args[1:])
+	case args[0] == "credits":
+		commandCredits(args[1:])
 	case args[0] == "measure":
 		commandMeasure(args[1:])
 	case args[0] == "ping":
@@ -79,6 +81,7 @@ func printUsage() {
 	fmt.Println("	result           download results")
 	fmt.Println("	status           measurement status check")
 	fmt.Println("	measure          start new measurement(s)")
+	fmt.Println("	credits          credit balance, income, expenses and transfers")
 	fmt.Println("	dns              shortcut to -dns -name")
 	fmt.Println("	http             shortcut to -http -target")
 	fmt.Println("	ntp              shortcut to -ntp -target")
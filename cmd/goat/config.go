@@ -13,6 +13,7 @@ import (
 
 	"github.com/robert-kisteleki/goat"
 	"github.com/robert-kisteleki/goat/cmd/goat/output/annotate"
+	"github.com/robert-kisteleki/goat/goattest"
 
 	"github.com/go-ini/ini"
 	"github.com/google/uuid"
@@ -24,6 +25,7 @@ var (
 	flagVerbose   bool
 	flagAPIKey    string
 	flagAPIEnvKey string
+	flagDemo      bool
 
 	// subcommand specific arguments
 	flagsVersion     *flag.FlagSet
@@ -33,6 +35,7 @@ var (
 	flagsGetResult   *flag.FlagSet
 	flagsStatusCheck *flag.FlagSet
 	flagsMeasure     *flag.FlagSet
+	flagsCredits     *flag.FlagSet
 
 	apiKey  *uuid.UUID           // specified on the command line explicitly or via env
 	apiKeys map[string]uuid.UUID // collected from config file
@@ -61,6 +64,7 @@ func setupFlags() {
 	flag.BoolVar(&flagVerbose, "verbose", false, "Be verbose")
 	flag.StringVar(&flagAPIKey, "key", "", "Use this API key")
 	flag.StringVar(&flagAPIEnvKey, "env", "", "Use this environment variable as API key")
+	flag.BoolVar(&flagDemo, "demo", false, "Use a built-in fake API with demo data instead of RIPE Atlas")
 
 	flag.Parse()
 
@@ -95,6 +99,7 @@ func configure() {
 	flagsGetResult = flag.NewFlagSet("result", flag.ExitOnError)
 	flagsStatusCheck = flag.NewFlagSet("status", flag.ExitOnError)
 	flagsMeasure = flag.NewFlagSet("measure", flag.ExitOnError)
+	flagsCredits = flag.NewFlagSet("credits", flag.ExitOnError)
 
 	Subcommands = map[string]*flag.FlagSet{
 		flagsVersion.Name():     flagsVersion,
@@ -104,6 +109,7 @@ func configure() {
 		flagsGetResult.Name():   flagsGetResult,
 		flagsStatusCheck.Name(): flagsStatusCheck,
 		flagsMeasure.Name():     flagsStatusCheck,
+		flagsCredits.Name():     flagsCredits,
 	}
 	setupFlags()
 
@@ -121,9 +127,34 @@ func configure() {
 		}
 	}
 
+	if flagDemo {
+		startDemo()
+	}
+
 	annotate.SetCacheDir(CacheDir, flagVerbose)
 }
 
+// startDemo starts a fake API with demo data and points goat to it
+func startDemo() {
+	fake := goattest.New()
+	if err := fake.LoadDemo(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load demo data: %v\n", err)
+		os.Exit(1)
+	}
+	goat.SetAPIBase(fake.APIBase())
+	goat.SetStreamBase(fake.StreamBase())
+
+	// the fake accepts any key, but some calls need one
+	if apiKey == nil {
+		key := uuid.New()
+		apiKey = &key
+	}
+
+	if flagVerbose {
+		fmt.Printf("# Using demo API at %s\n", fake.APIBase())
+	}
+}
+
 // readConfig deals with configuration file loading
 func readConfig(confFile string) bool {
 	if flagVerbose {
@@ -143,6 +174,8 @@ func readConfig(confFile string) bool {
 	loadApiKey(cfg, "create_measurements")
 	loadApiKey(cfg, "stop_measurements")
 	loadApiKey(cfg, "update_measurements")
+	loadApiKey(cfg, "get_credits")
+	loadApiKey(cfg, "transfer_credits")
 	// TODO: add more API key variations here
 
 	// allow config to override where the API is
@@ -210,6 +243,8 @@ list_measurements = ""
 create_measurements = ""
 stop_measurements = ""
 update_measurements = ""
+get_credits = ""
+transfer_credits = ""
 
 # default probe specifications for new measurements
 [probespec]
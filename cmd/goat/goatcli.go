@@ -7,12 +7,18 @@
 package main
 
 import (
+	_ "github.com/robert-kisteleki/goat/cmd/goat/output/certstat"
+	_ "github.com/robert-kisteleki/goat/cmd/goat/output/dnssec"
 	_ "github.com/robert-kisteleki/goat/cmd/goat/output/dnsstat"
+	_ "github.com/robert-kisteleki/goat/cmd/goat/output/graph"
 	_ "github.com/robert-kisteleki/goat/cmd/goat/output/id"
 	_ "github.com/robert-kisteleki/goat/cmd/goat/output/idcsv"
 	_ "github.com/robert-kisteleki/goat/cmd/goat/output/most"
+	_ "github.com/robert-kisteleki/goat/cmd/goat/output/mtr"
+	_ "github.com/robert-kisteleki/goat/cmd/goat/output/multipath"
 	_ "github.com/robert-kisteleki/goat/cmd/goat/output/native"
 	_ "github.com/robert-kisteleki/goat/cmd/goat/output/none"
+	_ "github.com/robert-kisteleki/goat/cmd/goat/output/routechange"
 	_ "github.com/robert-kisteleki/goat/cmd/goat/output/some"
 )
 
@@ -0,0 +1,47 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package output
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robert-kisteleki/goat"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+// LoadAsnTable loads the routing data (pfx2as or MRT RIB dump, possibly
+// compressed) and IXP prefixes given in the asn:FILE and ixp:FILE options.
+// It returns nil if there were no such options.
+func LoadAsnTable(options []string) (*result.AsnTable, error) {
+	var table *result.AsnTable
+	for _, opt := range options {
+		key, filename, _ := strings.Cut(opt, ":")
+		if key != "asn" && key != "ixp" {
+			continue
+		}
+		if table == nil {
+			newtable := result.NewAsnTable()
+			table = &newtable
+		}
+
+		file, err := goat.OpenResultFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if key == "asn" {
+			err = table.Read(file)
+		} else {
+			err = table.ReadIxps(file)
+		}
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+	return table, nil
+}
@@ -0,0 +1,212 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+/*
+  Defines the "certstat" output formatter. It verifies the certificate
+  chains of TLS results and groups the distinct chains by the country and
+  ASN of the probes that saw them.
+*/
+
+package certstat
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robert-kisteleki/goat/cmd/goat/output"
+	"github.com/robert-kisteleki/goat/cmd/goat/output/annotate"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+var verbose bool
+var total uint
+var verifier result.CertVerifier
+var certstatcollector map[string]*collectorItem
+
+type collectorItem struct {
+	Total    uint
+	Subject  string
+	Issuer   string
+	NotAfter time.Time
+	Problems map[string]uint
+	CCs      map[string]uint
+	Asns     map[string]uint
+}
+
+func init() {
+	output.Register("certstat", supports, setup, start, process, finish)
+}
+
+func supports(outtype string) bool {
+	return outtype == "tls"
+}
+
+func setup(isverbose bool, options []string) {
+	verbose = isverbose
+	verifier = result.NewCertVerifier()
+	issuers := make([]string, 0)
+	for _, opt := range options {
+		key, value, _ := strings.Cut(opt, ":")
+		switch key {
+		case "ca":
+			if err := readCABundle(value); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				os.Exit(1)
+			}
+		case "name":
+			verifier.ServerName(value)
+		case "issuer":
+			issuers = append(issuers, value)
+		case "horizon":
+			days, err := strconv.ParseUint(value, 10, 0)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: invalid horizon %s\n", value)
+				os.Exit(1)
+			}
+			verifier.ExpiryHorizon(time.Duration(days) * 24 * time.Hour)
+		}
+	}
+	if len(issuers) > 0 {
+		verifier.ExpectedIssuers(issuers)
+	}
+}
+
+func readCABundle(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := verifier.ReadCABundle(file); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	if verbose {
+		fmt.Printf("# Using CA bundle %s\n", filename)
+	}
+	return nil
+}
+
+func start() {
+	certstatcollector = make(map[string]*collectorItem)
+	annotate.InitProbeCache()
+}
+
+func process(res any) {
+	total++
+
+	var cert *result.CertResult
+	if t, ok := res.(*result.Result); ok {
+		cert, _ = (*t).(*result.CertResult)
+	}
+	if cert == nil {
+		fmt.Printf("This output formatter only works for TLS results\n")
+		return
+	}
+
+	// results without a chain are collected by what went wrong
+	switch {
+	case cert.Error != nil:
+		registerResult("ERROR", nil, cert)
+	case cert.DnsError != "":
+		registerResult("DNSERR", nil, cert)
+	case cert.Alert != nil:
+		registerResult("ALERT", nil, cert)
+	default:
+		verdict, err := verifier.Verify(cert)
+		if err != nil {
+			registerResult("NOCERT", nil, cert)
+		} else {
+			registerResult(verdict.Fingerprint, &verdict, cert)
+		}
+	}
+}
+
+func registerResult(key string, verdict *result.CertVerdict, cert *result.CertResult) {
+	item, ok := certstatcollector[key]
+	if !ok {
+		item = &collectorItem{
+			Problems: make(map[string]uint),
+			CCs:      make(map[string]uint),
+			Asns:     make(map[string]uint),
+		}
+		if verdict != nil {
+			item.Subject = verdict.Subject
+			item.Issuer = verdict.Issuer
+			item.NotAfter = verdict.NotAfter
+		}
+		certstatcollector[key] = item
+	}
+
+	item.Total++
+	item.CCs[annotate.GetProbeCountry(cert.ProbeID)]++
+	if cert.AddressFamily == 6 {
+		item.Asns[annotate.GetProbeAsn6(cert.ProbeID)]++
+	} else {
+		item.Asns[annotate.GetProbeAsn4(cert.ProbeID)]++
+	}
+	if verdict != nil {
+		// the same chain can have different problems, e.g. as time passes
+		for _, problem := range verdict.Problems() {
+			item.Problems[problem]++
+		}
+	}
+}
+
+func finish() {
+	keys := make([]string, 0, len(certstatcollector))
+	for key := range certstatcollector {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ti, tj := certstatcollector[keys[i]].Total, certstatcollector[keys[j]].Total
+		if ti != tj {
+			return ti > tj
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, key := range keys {
+		item := certstatcollector[key]
+		if item.NotAfter.IsZero() {
+			fmt.Printf("%d\t%s", item.Total, key)
+		} else {
+			fmt.Printf("%d\t%s\t\"%s\"\t\"%s\"\t%s\t%s",
+				item.Total,
+				key[:16],
+				item.Subject,
+				item.Issuer,
+				item.NotAfter.UTC().Format(time.RFC3339),
+				problemsString(item.Problems),
+			)
+		}
+		fmt.Print("\t")
+		output.PrintTopN(item.CCs, 5, "")
+		fmt.Print("\t")
+		output.PrintTopN(item.Asns, 5, "AS")
+		fmt.Println()
+	}
+
+	if verbose {
+		fmt.Printf("# %d results, %d groups\n", total, len(keys))
+	}
+}
+
+// problemsString summarises the problems, "OK" if there were none
+func problemsString(problems map[string]uint) string {
+	if len(problems) == 0 {
+		return "OK"
+	}
+	list := make([]string, 0, len(problems))
+	for problem, count := range problems {
+		list = append(list, fmt.Sprintf("%s:%d", problem, count))
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
@@ -0,0 +1,201 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+/*
+  Defines the "dnssec" output formatter. It validates DNS responses against
+  trust anchors and aggregates the verdicts per probe, country and ASN.
+*/
+
+package dnssec
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/robert-kisteleki/goat/cmd/goat/output"
+	"github.com/robert-kisteleki/goat/cmd/goat/output/annotate"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+var verbose bool
+var total uint
+var validator result.DnssecValidator
+var showReasons bool
+var probeStats map[uint]*verdictCounts
+var ccStats map[string]*verdictCounts
+var asnStats map[string]*verdictCounts
+var totalStats verdictCounts
+var reasons map[string]uint
+
+// verdictCounts counts the verdicts per status
+type verdictCounts [4]uint
+
+func init() {
+	output.Register("dnssec", supports, setup, start, process, finish)
+}
+
+func supports(outtype string) bool {
+	return outtype == "dns"
+}
+
+func setup(isverbose bool, options []string) {
+	verbose = isverbose
+	validator = result.NewDnssecValidator()
+	anchors := 0
+	for _, opt := range options {
+		switch {
+		case strings.HasPrefix(opt, "anchor:"):
+			if err := readAnchors(opt[7:]); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				os.Exit(1)
+			}
+			anchors++
+		case opt == "reasons":
+			showReasons = true
+		}
+	}
+	if anchors == 0 {
+		// a root anchor alone would not help: results don't contain the chain
+		fmt.Fprintf(os.Stderr, "ERROR: the dnssec output formatter needs trust anchors (-opt anchor:FILE)\n")
+		os.Exit(1)
+	}
+}
+
+func readAnchors(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := validator.ReadTrustAnchors(file); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	if verbose {
+		fmt.Printf("# Using trust anchors from %s\n", filename)
+	}
+	return nil
+}
+
+func start() {
+	probeStats = make(map[uint]*verdictCounts)
+	ccStats = make(map[string]*verdictCounts)
+	asnStats = make(map[string]*verdictCounts)
+	reasons = make(map[string]uint)
+	totalStats = verdictCounts{}
+	annotate.InitProbeCache()
+}
+
+func process(res any) {
+	total++
+
+	var dnsres *result.DnsResult
+	if t, ok := res.(*result.Result); ok {
+		dnsres, _ = (*t).(*result.DnsResult)
+	}
+	if dnsres == nil {
+		fmt.Printf("This output formatter only works for DNS results\n")
+		return
+	}
+
+	for i, verdict := range validator.Validate(dnsres) {
+		registerVerdict(dnsres.ProbeID, dnsres.Responses[i].AddressFamily, verdict)
+	}
+}
+
+func registerVerdict(pid uint, af uint, verdict result.DnssecVerdict) {
+	asn := annotate.GetProbeAsn4(pid)
+	if af == 6 {
+		asn = annotate.GetProbeAsn6(pid)
+	}
+	for _, counts := range []*verdictCounts{
+		counter(probeStats, pid),
+		counter(ccStats, annotate.GetProbeCountry(pid)),
+		counter(asnStats, asn),
+		&totalStats,
+	} {
+		counts[verdict.Status]++
+	}
+	if verdict.Reason != "" {
+		reasons[verdict.Status.String()+": "+verdict.Reason]++
+	}
+}
+
+func counter[K comparable](stats map[K]*verdictCounts, key K) *verdictCounts {
+	if _, ok := stats[key]; !ok {
+		stats[key] = &verdictCounts{}
+	}
+	return stats[key]
+}
+
+func finish() {
+	fmt.Println("# what\tkey\tsecure\tinsecure\tbogus\tindeterminate")
+	printCounts("total", "-", &totalStats)
+	for _, pid := range slices.Sorted(maps.Keys(probeStats)) {
+		printCounts("probe", fmt.Sprint(pid), probeStats[pid])
+	}
+	for _, cc := range sortedByBogus(ccStats) {
+		printCounts("cc", cc, ccStats[cc])
+	}
+	for _, asn := range sortedByBogus(asnStats) {
+		key := asn
+		if asn != "N/A" {
+			key = "AS" + asn
+		}
+		printCounts("asn", key, asnStats[asn])
+	}
+
+	if showReasons {
+		type reasonCount struct {
+			reason string
+			count  uint
+		}
+		list := make([]reasonCount, 0, len(reasons))
+		for reason, count := range reasons {
+			list = append(list, reasonCount{reason, count})
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].count != list[j].count {
+				return list[i].count > list[j].count
+			}
+			return list[i].reason < list[j].reason
+		})
+		for _, item := range list {
+			fmt.Printf("reason\t\"%s\"\t%d\n", item.reason, item.count)
+		}
+	}
+
+	if verbose {
+		fmt.Printf("# %d results\n", total)
+	}
+}
+
+func printCounts(what string, key string, counts *verdictCounts) {
+	fmt.Printf("%s\t%s\t%d\t%d\t%d\t%d\n",
+		what,
+		key,
+		counts[result.DnssecSecure],
+		counts[result.DnssecInsecure],
+		counts[result.DnssecBogus],
+		counts[result.DnssecIndeterminate],
+	)
+}
+
+// sortedByBogus returns the keys ordered by the number of bogus verdicts
+func sortedByBogus(stats map[string]*verdictCounts) []string {
+	keys := slices.Collect(maps.Keys(stats))
+	sort.Slice(keys, func(i, j int) bool {
+		bi, bj := stats[keys[i]][result.DnssecBogus], stats[keys[j]][result.DnssecBogus]
+		if bi != bj {
+			return bi > bj
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
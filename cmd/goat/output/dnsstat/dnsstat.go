@@ -147,11 +147,11 @@ func finish() {
 		fmt.Printf("%d\t\"%s\"", v.val.Total, v.key)
 		if makeCcStats {
 			fmt.Print("\t")
-			printTopN(v.val.CCs, 5, "")
+			output.PrintTopN(v.val.CCs, 5, "")
 		}
 		if makeAsnStats {
 			fmt.Print("\t")
-			printTopN(v.val.Asns, 5, "AS")
+			output.PrintTopN(v.val.Asns, 5, "AS")
 		}
 		fmt.Println()
 		anssum += v.val.Total
@@ -185,23 +185,3 @@ func registerResult(key string, af uint, pid uint) {
 		}
 	}
 }
-
-func printTopN(data map[string]uint, max uint, as string) {
-	type valPlusCount struct {
-		val   string
-		count uint
-	}
-
-	vpc := make([]valPlusCount, 0)
-	for key, val := range data {
-		if key == "N/A" {
-			vpc = append(vpc, valPlusCount{"(N/A)", val})
-		} else {
-			vpc = append(vpc, valPlusCount{key, val})
-		}
-	}
-	sort.Slice(vpc, func(i, j int) bool { return vpc[i].count > vpc[j].count })
-	for i := 0; i < 10 && i < len(vpc); i++ {
-		fmt.Printf(" %s%s:%d", as, vpc[i].val, vpc[i].count)
-	}
-}
@@ -0,0 +1,118 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+/*
+  Defines the "graph" output formatter. It combines traceroutes into an
+  IP or AS level topology and writes it as DOT, GraphML or JSON.
+*/
+
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/robert-kisteleki/goat/cmd/goat/output"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+var verbose bool
+var total uint
+var format string
+var asLevel bool
+var asnTable *result.AsnTable
+var topology result.Topology
+
+func init() {
+	output.Register("graph", supports, setup, start, process, finish)
+}
+
+func supports(outtype string) bool {
+	return outtype == "trace"
+}
+
+func setup(isverbose bool, options []string) {
+	verbose = isverbose
+	format = "dot"
+	for _, opt := range options {
+		key, value, _ := strings.Cut(opt, ":")
+		switch key {
+		case "format":
+			if value != "dot" && value != "graphml" && value != "json" {
+				fmt.Fprintf(os.Stderr, "ERROR: invalid graph format %s (dot, graphml or json)\n", value)
+				os.Exit(1)
+			}
+			format = value
+		case "level":
+			if value != "ip" && value != "as" {
+				fmt.Fprintf(os.Stderr, "ERROR: invalid graph level %s (ip or as)\n", value)
+				os.Exit(1)
+			}
+			asLevel = value == "as"
+		}
+	}
+
+	var err error
+	asnTable, err = output.LoadAsnTable(options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	if asLevel && asnTable == nil {
+		fmt.Fprintf(os.Stderr, "ERROR: an AS level graph needs routing data (-opt asn:FILE)\n")
+		os.Exit(1)
+	}
+}
+
+func start() {
+	topology = result.NewTopology()
+	if asLevel {
+		topology.AsLevel(asnTable)
+	} else if asnTable != nil {
+		topology.AsnTable(asnTable)
+	}
+}
+
+func process(res any) {
+	total++
+
+	var trace *result.TracerouteResult
+	if t, ok := res.(*result.Result); ok {
+		trace, _ = (*t).(*result.TracerouteResult)
+	}
+	if trace == nil {
+		fmt.Printf("This output formatter only works for traceroute results\n")
+		return
+	}
+	topology.Add(trace)
+}
+
+func finish() {
+	var err error
+	switch format {
+	case "dot":
+		err = topology.WriteDot(os.Stdout)
+	case "graphml":
+		err = topology.WriteGraphML(os.Stdout)
+	case "json":
+		var data []byte
+		data, err = json.Marshal(topology)
+		if err == nil {
+			fmt.Println(string(data))
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	if verbose && format == "dot" {
+		fmt.Printf("// %d results, %d nodes, %d edges\n",
+			total, len(topology.Nodes()), len(topology.Edges()))
+	}
+}
@@ -12,8 +12,11 @@ package most
 
 import (
 	"fmt"
+	"maps"
+	"os"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/robert-kisteleki/goat"
 	"github.com/robert-kisteleki/goat/cmd/goat/output"
@@ -24,6 +27,7 @@ import (
 
 var verbose bool
 var total uint
+var asnTable *result.AsnTable
 
 func init() {
 	output.Register("most", supports, setup, start, process, finish)
@@ -33,7 +37,7 @@ func supports(outtype string) bool {
 	if slices.Contains([]string{"ping", "trace", "dns", "tls", "ntp", "http"}, outtype) ||
 		outtype == "connection" || outtype == "uptime" ||
 		outtype == "probe" || outtype == "anchor" || outtype == "msm" ||
-		outtype == "status" {
+		outtype == "status" || outtype == "credits" {
 		return true
 	}
 	return false
@@ -41,6 +45,13 @@ func supports(outtype string) bool {
 
 func setup(isverbose bool, options []string) {
 	verbose = isverbose
+
+	var err error
+	asnTable, err = output.LoadAsnTable(options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
 }
 
 func start() {
@@ -79,6 +90,10 @@ func process(res any) {
 		fmt.Println(t.Measurement.LongString())
 	case goat.AsyncStatusCheckResult:
 		fmt.Println(t.Status.LongString())
+	case *goat.Credits:
+		fmt.Println(t.LongString())
+	case goat.AsyncCreditItemResult:
+		fmt.Println(t.Item.LongString())
 	default:
 		fmt.Printf("No output formatter defined for object type '%T'\n", t)
 	}
@@ -117,8 +132,41 @@ func mostOutputDnsResponse(resp *result.DnsResponse) string {
 }
 
 func mostOutputTraceroute(res *result.TracerouteResult) string {
-	return some.SomeOutputTraceroute(res) +
+	ret := some.SomeOutputTraceroute(res) +
 		fmt.Sprintf("\t%v\t%d", res.DestinationReached(), res.ParisID)
+	// the AS columns are always there to keep the number of columns fixed
+	if asnTable != nil {
+		path := res.AsPath(asnTable)
+		asns := make([]string, 0)
+		for _, asn := range path.Asns() {
+			asns = append(asns, fmt.Sprintf("AS%d", asn))
+		}
+		ret += fmt.Sprintf("\t%s\t[%s]", path, strings.Join(asns, " "))
+	} else {
+		ret += "\tN/A\t[]"
+	}
+	ret += "\t[" + mostOutputIcmpExtensions(res) + "]"
+	return ret
+}
+
+// mostOutputIcmpExtensions lists the ICMP extensions of each hop, once per
+// responding address
+func mostOutputIcmpExtensions(res *result.TracerouteResult) string {
+	exts := make([]string, 0)
+	for _, hop := range res.Hops {
+		seen := make(map[string]bool)
+		for _, ans := range hop.Responses {
+			for _, ext := range ans.IcmpExtensions {
+				s := ext.String()
+				if s == "" || seen[ans.From.String()+s] {
+					continue
+				}
+				seen[ans.From.String()+s] = true
+				exts = append(exts, fmt.Sprintf("%d:%s", hop.HopNumber, s))
+			}
+		}
+	}
+	return strings.Join(exts, " ")
 }
 
 func mostOutputCert(res *result.CertResult) string {
@@ -140,6 +188,10 @@ func mostOutputCert(res *result.CertResult) string {
 }
 
 func mostOutputHttp(res *result.HttpResult) string {
+	s := make([]string, 0)
+	for _, resp := range res.Responses {
+		s = append(s, mostOutputHttpResponse(&resp))
+	}
 	return some.SomeOutputHttp(res) +
 		fmt.Sprintf("\t\"%s\"\t%s\t%d\t%d\t%d",
 			res.Error,
@@ -147,7 +199,42 @@ func mostOutputHttp(res *result.HttpResult) string {
 			res.ResultCode,
 			res.HeaderSize,
 			res.BodySize,
-		)
+		) +
+		"\t[" + strings.Join(s, " ") + "]"
+}
+
+func mostOutputHttpResponse(resp *result.HttpResponse) string {
+	start := "N/A"
+	if !resp.Time.IsZero() {
+		start = resp.Time.UTC().Format(time.RFC3339)
+	}
+	ret := fmt.Sprintf("<%d/%d\t%s\t%v\t%s\tHTTP/%s\t%d\t%d\t%s",
+		resp.SubID,
+		resp.SubMax,
+		start,
+		resp.DestinationAddr,
+		resp.Method,
+		resp.Version,
+		resp.HeaderSize,
+		resp.BodySize,
+		some.SomeOutputHttpResponse(resp),
+	)
+
+	// headers are shown sorted by name, values in the order received
+	headers := make([]string, 0, len(resp.Header))
+	for _, name := range slices.Sorted(maps.Keys(resp.Header)) {
+		for _, value := range resp.Header[name] {
+			headers = append(headers, fmt.Sprintf("%q", name+": "+value))
+		}
+	}
+	ret += "\t{" + strings.Join(headers, " ") + "}"
+
+	chunks := make([]string, 0, len(resp.ReadTiming))
+	for _, rt := range resp.ReadTiming {
+		chunks = append(chunks, fmt.Sprintf("%d:%f", rt.Offset, rt.Time))
+	}
+	ret += "\t{" + strings.Join(chunks, " ") + "}>"
+	return ret
 }
 
 func mostOutputNtp(res *result.NtpResult) string {
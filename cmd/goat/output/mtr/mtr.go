@@ -0,0 +1,247 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+/*
+  Defines the "mtr" output formatter. It aggregates traceroutes per probe
+  and destination into a report similar to what mtr produces: loss and RTT
+  statistics per hop, with the addresses seen.
+*/
+
+package mtr
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robert-kisteleki/goat/cmd/goat/output"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+var verbose bool
+var total uint
+var asnTable *result.AsnTable
+var reports map[reportKey]*report
+
+type reportKey struct {
+	probe       uint
+	destination string
+}
+
+type report struct {
+	results uint
+	first   time.Time
+	last    time.Time
+	hops    map[uint]*hopStats
+}
+
+type hopStats struct {
+	sent     uint                // packets sent (responses, errors and timeouts)
+	rtt      result.RttStats     //
+	last     float64             // last RTT, from the latest result with a reply
+	lastTime time.Time           //
+	addrs    map[netip.Addr]uint // replies per address
+	seen     string              // addresses seen in the first result with replies
+	varies   bool                // other results saw other addresses
+}
+
+func init() {
+	output.Register("mtr", supports, setup, start, process, finish)
+}
+
+func supports(outtype string) bool {
+	return outtype == "trace"
+}
+
+func setup(isverbose bool, options []string) {
+	verbose = isverbose
+
+	var err error
+	asnTable, err = output.LoadAsnTable(options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func start() {
+	reports = make(map[reportKey]*report)
+}
+
+func process(res any) {
+	total++
+
+	var trace *result.TracerouteResult
+	if t, ok := res.(*result.Result); ok {
+		trace, _ = (*t).(*result.TracerouteResult)
+	}
+	if trace == nil {
+		fmt.Printf("This output formatter only works for traceroute results\n")
+		return
+	}
+
+	key := reportKey{trace.ProbeID, trace.RouteKey().Destination}
+	rep, ok := reports[key]
+	if !ok {
+		rep = &report{hops: make(map[uint]*hopStats)}
+		reports[key] = rep
+	}
+	when := trace.GetTimeStamp()
+	rep.results++
+	if rep.first.IsZero() || when.Before(rep.first) {
+		rep.first = when
+	}
+	if when.After(rep.last) {
+		rep.last = when
+	}
+
+	for _, hop := range trace.Hops {
+		stats, ok := rep.hops[hop.HopNumber]
+		if !ok {
+			stats = &hopStats{addrs: make(map[netip.Addr]uint)}
+			rep.hops[hop.HopNumber] = stats
+		}
+		addHop(stats, &hop, when)
+	}
+}
+
+// addHop counts every response (and a failure to send) as a packet sent;
+// late replies are duplicates of timeouts, so they are not counted at all
+func addHop(stats *hopStats, hop *result.TracerouteHop, when time.Time) {
+	if hop.SendError != nil {
+		stats.sent++
+	}
+	addrs := make([]string, 0)
+	last := 0.0
+	for _, resp := range hop.Responses {
+		if resp.Late != nil {
+			continue
+		}
+		stats.sent++
+		if resp.Timeout || resp.Error != nil || !resp.From.IsValid() {
+			continue
+		}
+		stats.rtt.Add(resp.Rtt)
+		stats.addrs[resp.From]++
+		last = resp.Rtt
+		if !slices.Contains(addrs, resp.From.String()) {
+			addrs = append(addrs, resp.From.String())
+		}
+	}
+	if len(addrs) == 0 {
+		return
+	}
+
+	slices.Sort(addrs)
+	seen := strings.Join(addrs, " ")
+	switch {
+	case stats.seen == "":
+		stats.seen = seen
+	case stats.seen != seen:
+		stats.varies = true
+	}
+	if !when.Before(stats.lastTime) {
+		stats.last = last
+		stats.lastTime = when
+	}
+}
+
+func finish() {
+	keys := make([]reportKey, 0, len(reports))
+	for key := range reports {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b reportKey) int {
+		return cmp.Or(cmp.Compare(a.probe, b.probe), strings.Compare(a.destination, b.destination))
+	})
+
+	for i, key := range keys {
+		if i > 0 {
+			fmt.Println()
+		}
+		printReport(key, reports[key])
+	}
+
+	if verbose {
+		fmt.Printf("# %d results, %d reports\n", total, len(keys))
+	}
+}
+
+func printReport(key reportKey, rep *report) {
+	fmt.Printf("PROBE %d to %s: %d results from %s to %s\n",
+		key.probe,
+		key.destination,
+		rep.results,
+		rep.first.UTC().Format(time.RFC3339),
+		rep.last.UTC().Format(time.RFC3339),
+	)
+	fmt.Printf("%-36s %6s %5s %8s %8s %8s %8s %8s\n",
+		"HOP  ADDRESS", "LOSS%", "SNT", "LAST", "AVG", "BEST", "WRST", "STDEV")
+
+	numbers := make([]uint, 0, len(rep.hops))
+	for number := range rep.hops {
+		numbers = append(numbers, number)
+	}
+	slices.Sort(numbers)
+	for _, number := range numbers {
+		stats := rep.hops[number]
+		marker := "|--"
+		if stats.varies {
+			marker = "|~~"
+		}
+		addrs := sortedAddrs(stats.addrs)
+		host := "???"
+		if len(addrs) > 0 {
+			host = addrString(addrs[0])
+		}
+		loss := 100.0
+		if stats.sent > 0 {
+			loss = 100 * float64(stats.sent-stats.rtt.Count) / float64(stats.sent)
+		}
+		fmt.Printf("%3d.%s %-28s %5.1f%% %5d", number, marker, host, loss, stats.sent)
+		if stats.rtt.Count > 0 {
+			fmt.Printf(" %8.3f %8.3f %8.3f %8.3f %8.3f",
+				stats.last,
+				stats.rtt.Average(),
+				stats.rtt.Min,
+				stats.rtt.Max,
+				stats.rtt.StdDev(),
+			)
+		}
+		fmt.Println()
+
+		// other addresses with their share of the replies
+		for _, addr := range addrs[min(1, len(addrs)):] {
+			fmt.Printf("       %s (%.1f%%)\n",
+				addrString(addr),
+				100*float64(stats.addrs[addr])/float64(stats.rtt.Count),
+			)
+		}
+	}
+}
+
+// sortedAddrs orders the addresses by the number of replies
+func sortedAddrs(addrs map[netip.Addr]uint) []netip.Addr {
+	list := make([]netip.Addr, 0, len(addrs))
+	for addr := range addrs {
+		list = append(list, addr)
+	}
+	slices.SortFunc(list, func(a, b netip.Addr) int {
+		return cmp.Or(cmp.Compare(addrs[b], addrs[a]), a.Compare(b))
+	})
+	return list
+}
+
+func addrString(addr netip.Addr) string {
+	if asnTable == nil {
+		return addr.String()
+	}
+	return fmt.Sprintf("%s [%s]", addr, asnTable.Classify(addr))
+}
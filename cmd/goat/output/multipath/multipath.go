@@ -0,0 +1,130 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+/*
+  Defines the "multipath" output formatter. It compares the paths taken by
+  traceroutes with different Paris IDs from the same probe to the same
+  destination, to discover load balancing on the way.
+*/
+
+package multipath
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/robert-kisteleki/goat/cmd/goat/output"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+var verbose bool
+var total uint
+var balancedOnly bool
+var analyser result.MultipathAnalyser
+
+func init() {
+	output.Register("multipath", supports, setup, start, process, finish)
+}
+
+func supports(outtype string) bool {
+	return outtype == "trace"
+}
+
+func setup(isverbose bool, options []string) {
+	verbose = isverbose
+	for _, opt := range options {
+		if opt == "lbonly" {
+			balancedOnly = true
+		}
+	}
+}
+
+func start() {
+	analyser = result.NewMultipathAnalyser()
+}
+
+func process(res any) {
+	total++
+
+	var trace *result.TracerouteResult
+	if t, ok := res.(*result.Result); ok {
+		trace, _ = (*t).(*result.TracerouteResult)
+	}
+	if trace == nil {
+		fmt.Printf("This output formatter only works for traceroute results\n")
+		return
+	}
+	analyser.Add(trace)
+}
+
+func finish() {
+	balanced := 0
+	shown := 0
+	for _, mp := range analyser.Results() {
+		if mp.LoadBalanced() {
+			balanced++
+		} else if balancedOnly {
+			continue
+		}
+		if shown > 0 {
+			fmt.Println()
+		}
+		shown++
+		printMultipath(mp)
+	}
+
+	if verbose {
+		fmt.Printf("# %d results, %d probe and destination pairs, %d with load balancing\n",
+			total, analyser.Len(), balanced)
+	}
+}
+
+func printMultipath(mp *result.Multipath) {
+	fmt.Printf("PROBE %d to %s: %d results, %d Paris IDs, %d paths, width %d\n",
+		mp.Key.ProbeID,
+		mp.Key.Destination,
+		mp.Traces,
+		len(mp.ParisIDs),
+		len(mp.Paths),
+		mp.Width,
+	)
+	for i, path := range mp.Paths {
+		fmt.Printf("path\t%d\t%d\t%s\t%s\n", i+1, path.Count, uintList(path.ParisIDs), path)
+	}
+	for _, hop := range mp.Balanced {
+		fmt.Printf("lb\t%d\t%d\t%s\n", hop.HopNumber, len(hop.Addrs), addrList(hop.Addrs))
+	}
+	for _, diamond := range mp.Diamonds {
+		convergence := "-"
+		if !diamond.Unconverged {
+			convergence = fmt.Sprintf("%d:%s", diamond.Convergence.HopNumber, diamond.Convergence.Addrs[0])
+		}
+		fmt.Printf("diamond\t%d:%s\t%s\t%d\t%d\n",
+			diamond.Divergence.HopNumber,
+			diamond.Divergence.Addrs[0],
+			convergence,
+			diamond.Width,
+			diamond.Length,
+		)
+	}
+}
+
+func uintList(list []uint) string {
+	items := make([]string, 0, len(list))
+	for _, item := range list {
+		items = append(items, fmt.Sprint(item))
+	}
+	return strings.Join(items, ",")
+}
+
+func addrList(list []netip.Addr) string {
+	items := make([]string, 0, len(list))
+	for _, addr := range list {
+		items = append(items, addr.String())
+	}
+	return strings.Join(items, " ")
+}
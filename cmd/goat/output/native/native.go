@@ -14,6 +14,7 @@ package native
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/robert-kisteleki/goat/cmd/goat/output"
@@ -22,6 +23,7 @@ import (
 
 var verbose bool
 var total uint
+var asnTable *result.AsnTable
 
 func init() {
 	output.Register("native", supports, setup, start, process, finish)
@@ -36,6 +38,13 @@ func supports(outtype string) bool {
 
 func setup(isverbose bool, options []string) {
 	verbose = isverbose
+
+	var err error
+	asnTable, err = output.LoadAsnTable(options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
 }
 
 func start() {
@@ -128,6 +137,12 @@ func nativeOutputTraceroute(res *result.TracerouteResult) {
 						fmt.Printf("\n     ")
 					}
 					fmt.Printf("%s (%s)", ans.From, ans.From)
+					if asnTable != nil {
+						fmt.Printf(" [%s]", asnTable.Classify(ans.From))
+					}
+					for _, ext := range ans.IcmpExtensions {
+						fmt.Printf(" %s", ext.String())
+					}
 				}
 				if ans.Late != nil {
 					fmt.Printf(" LATE")
@@ -143,6 +158,9 @@ func nativeOutputTraceroute(res *result.TracerouteResult) {
 			}
 		}
 	}
+	if asnTable != nil {
+		fmt.Printf("AS path: %s\n", res.AsPath(asnTable))
+	}
 }
 
 func printRecord(ans result.DnsAnswer) {
@@ -155,6 +173,28 @@ func printRecord(ans result.DnsAnswer) {
 	)
 }
 
+// printEdns shows the EDNS options (other than NSID) the way dig does
+func printEdns(edns *result.DnsEdns) {
+	if edns.ClientSubnet != nil {
+		fmt.Printf("; CLIENT-SUBNET: %v/%d\n", edns.ClientSubnet.Prefix, edns.ClientSubnet.Scope)
+	}
+	if edns.Cookie != nil {
+		fmt.Printf("; COOKIE: %x%x\n", edns.Cookie.Client, edns.Cookie.Server)
+	}
+	if edns.Keepalive != nil {
+		fmt.Printf("; TCP-KEEPALIVE: %.1f secs\n", edns.Keepalive.Seconds())
+	}
+	if edns.Padding != nil {
+		fmt.Printf("; PAD: (%d bytes)\n", *edns.Padding)
+	}
+	for _, ede := range edns.ExtendedErrors {
+		fmt.Printf("; EDE: %d (%s): (%s)\n", ede.InfoCode, ede, ede.ExtraText)
+	}
+	for _, opt := range edns.Options {
+		fmt.Printf("; OPT=%d: %x\n", opt.Code, opt.Data)
+	}
+}
+
 func nativeOutputDns(res *result.DnsResult) {
 	fmt.Printf("; Probe %d, source %v\n", res.ProbeID, res.FromAddr)
 
@@ -206,6 +246,9 @@ func nativeOutputDns(res *result.DnsResult) {
 						}
 						fmt.Printf("; NSID: %s (\"%s\")\n", strings.Join(hex, " "), resp.Edsn0Nsid)
 					}
+					if resp.Edns != nil {
+						printEdns(resp.Edns)
+					}
 				}
 			}
 		}
@@ -0,0 +1,128 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+/*
+  Defines the "routechange" output formatter. It compares each traceroute
+  to the previous one from the same probe to the same destination with the
+  same Paris ID, and shows the changes as they are found, so it also works
+  on the result stream.
+*/
+
+package routechange
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robert-kisteleki/goat/cmd/goat/output"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+var verbose bool
+var total uint
+var changes uint
+var detector result.RouteChangeDetector
+
+func init() {
+	output.Register("routechange", supports, setup, start, process, finish)
+}
+
+func supports(outtype string) bool {
+	return outtype == "trace"
+}
+
+func setup(isverbose bool, options []string) {
+	verbose = isverbose
+	detector = result.NewRouteChangeDetector()
+	for _, opt := range options {
+		key, value, _ := strings.Cut(opt, ":")
+		if key == "rtt" {
+			threshold, err := strconv.ParseFloat(value, 64)
+			if err != nil || threshold < 0 {
+				fmt.Fprintf(os.Stderr, "ERROR: invalid RTT threshold %s\n", value)
+				os.Exit(1)
+			}
+			detector.RttThreshold(threshold)
+		}
+	}
+
+	table, err := output.LoadAsnTable(options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	if table != nil {
+		detector.AsnTable(table)
+	}
+}
+
+func start() {
+}
+
+func process(res any) {
+	total++
+
+	var trace *result.TracerouteResult
+	if t, ok := res.(*result.Result); ok {
+		trace, _ = (*t).(*result.TracerouteResult)
+	}
+	if trace == nil {
+		fmt.Printf("This output formatter only works for traceroute results\n")
+		return
+	}
+
+	change := detector.Add(trace)
+	if change == nil {
+		return
+	}
+	changes++
+	fmt.Println(changeString(change))
+}
+
+func changeString(change *result.RouteChange) string {
+	ret := fmt.Sprintf("%s\t%d\t%s\t%d\t%s\t%s",
+		change.After.UTC().Format(time.RFC3339),
+		change.Key.ProbeID,
+		change.Key.Destination,
+		change.Key.ParisID,
+		change.Before.UTC().Format(time.RFC3339),
+		strings.Join(change.Kinds(), ","),
+	)
+	if change.PathChanged() {
+		hops := make([]string, 0, len(change.Hops))
+		for _, hop := range change.Hops {
+			hops = append(hops, hop.String())
+		}
+		ret += "\thops " + strings.Join(hops, " ")
+	}
+	if change.AsChanged {
+		ret += fmt.Sprintf("\tas %s>%s", asnsString(change.AsPathBefore), asnsString(change.AsPathAfter))
+	}
+	if change.RttShifted {
+		ret += fmt.Sprintf("\trtt %.3f>%.3f", change.RttBefore, change.RttAfter)
+	}
+	if change.ReachabilityChanged() {
+		ret += fmt.Sprintf("\treached %v>%v", change.ReachedBefore, change.ReachedAfter)
+	}
+	return ret
+}
+
+func asnsString(path result.AsPath) string {
+	list := make([]string, 0)
+	for _, asn := range path.Asns() {
+		list = append(list, fmt.Sprintf("AS%d", asn))
+	}
+	return strings.Join(list, ",")
+}
+
+func finish() {
+	if verbose {
+		fmt.Printf("# %d results, %d series, %d changes\n", total, detector.Len(), changes)
+	}
+}
@@ -13,6 +13,7 @@ package some
 import (
 	"fmt"
 	"net/netip"
+	"os"
 	"slices"
 	"time"
 
@@ -26,6 +27,8 @@ var total uint
 var connectLastResults map[uint]*result.ConnectionResult
 var connectAllResults []*result.ConnectionResult
 var connectTableOutput bool
+var connectLiveOutput bool
+var asnTable *result.AsnTable
 
 func init() {
 	output.Register("some", supports, setup, start, process, finish)
@@ -35,7 +38,7 @@ func supports(outtype string) bool {
 	if slices.Contains([]string{"ping", "trace", "dns", "tls", "ntp", "http"}, outtype) ||
 		outtype == "connection" || outtype == "uptime" ||
 		outtype == "probe" || outtype == "anchor" || outtype == "msm" ||
-		outtype == "status" {
+		outtype == "status" || outtype == "credits" {
 		return true
 	}
 	return false
@@ -44,11 +47,20 @@ func supports(outtype string) bool {
 func setup(isverbose bool, options []string) {
 	verbose = isverbose
 	for _, opt := range options {
-		if opt == "table" {
+		switch opt {
+		case "table":
 			connectTableOutput = true
-			break
+		case "live":
+			connectLiveOutput = true
 		}
 	}
+
+	var err error
+	asnTable, err = output.LoadAsnTable(options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
 }
 
 func start() {
@@ -67,6 +79,9 @@ func process(res any) {
 			out = SomeOutputDns(rt)
 		case *result.TracerouteResult:
 			out = SomeOutputTraceroute(rt)
+			if asnTable != nil {
+				out += "\t" + rt.AsPath(asnTable).String()
+			}
 		case *result.CertResult:
 			out = SomeOutputCert(rt)
 		case *result.HttpResult:
@@ -74,8 +89,13 @@ func process(res any) {
 		case *result.NtpResult:
 			out = SomeOutputNtp(rt)
 		case *result.ConnectionResult:
-			// no output here, we need to collect all connection results first
-			addConnectionEvent(rt)
+			if connectLiveOutput {
+				// show events as they come, e.g. when streaming
+				out = SomeOutputConnection(rt)
+			} else {
+				// no output here, we need to collect all connection results first
+				addConnectionEvent(rt)
+			}
 		case *result.UptimeResult:
 			out = SomeOutputUptime(rt)
 		}
@@ -87,6 +107,10 @@ func process(res any) {
 		out = t.Measurement.ShortString()
 	case goat.AsyncStatusCheckResult:
 		out = t.Status.ShortString()
+	case *goat.Credits:
+		out = t.ShortString()
+	case goat.AsyncCreditItemResult:
+		out = t.Item.ShortString()
 	default:
 		out = fmt.Sprintf("No output formatter defined for object type '%T'\n", t)
 	}
@@ -189,8 +213,35 @@ func SomeOutputCert(res *result.CertResult) string {
 
 // SomeOutputHttp returns the "some" output for an HTTP result
 func SomeOutputHttp(res *result.HttpResult) string {
+	first := "N/A"
+	if len(res.Responses) > 0 {
+		first = SomeOutputHttpResponse(&res.Responses[0])
+	}
 	return res.BaseString() +
-		fmt.Sprintf("\t%s", res.Uri)
+		fmt.Sprintf("\t%s\t%d\t%s", res.Uri, len(res.Responses), first)
+}
+
+// SomeOutputHttpResponse shows the result code and the timing of a response
+// as one field
+func SomeOutputHttpResponse(resp *result.HttpResponse) string {
+	switch {
+	case resp.DnsError != "":
+		return "DNSERR: " + resp.DnsError
+	case resp.Error != "":
+		return "ERROR: " + resp.Error
+	}
+	ret := fmt.Sprintf("%d %f", resp.ResultCode, resp.ReplyTime)
+	if resp.HasExtendedTiming() {
+		ret += fmt.Sprintf(" %f/%f/%f",
+			resp.TimeToResolve,
+			resp.TimeToConnect,
+			resp.TimeToFirstByte,
+		)
+	}
+	if len(resp.ReadTiming) > 0 {
+		ret += fmt.Sprintf(" %f", resp.TimeToRead())
+	}
+	return ret
 }
 
 // SomeOutputNtp returns the "some" output for an NTP result
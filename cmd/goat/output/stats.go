@@ -0,0 +1,33 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package output
+
+import (
+	"fmt"
+	"sort"
+)
+
+// PrintTopN prints the most frequent keys and their counts
+func PrintTopN(data map[string]uint, max uint, as string) {
+	type valPlusCount struct {
+		val   string
+		count uint
+	}
+
+	vpc := make([]valPlusCount, 0)
+	for key, val := range data {
+		if key == "N/A" {
+			vpc = append(vpc, valPlusCount{"(N/A)", val})
+		} else {
+			vpc = append(vpc, valPlusCount{key, val})
+		}
+	}
+	sort.Slice(vpc, func(i, j int) bool { return vpc[i].count > vpc[j].count })
+	for i := 0; i < 10 && i < len(vpc); i++ {
+		fmt.Printf(" %s%s:%d", as, vpc[i].val, vpc[i].count)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// magic bytes of the supported compression formats
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicBzip2 = []byte("BZh")
+	magicXz    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	magicZstd  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// OpenResultFile opens a file with results for reading. Compressed files
+// (gzip, bzip2, xz and zstd) are recognised by their content and are
+// decompressed transparently. If the file name is "-" then it reads from stdin.
+func OpenResultFile(filename string) (io.ReadCloser, error) {
+	var file *os.File
+	if filename == "-" {
+		file = os.Stdin
+	} else {
+		var err error
+		file, err = os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	read, err := Decompress(file)
+	if err != nil {
+		if file != os.Stdin {
+			file.Close()
+		}
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return &readCloser{read, func() error {
+		if closer, ok := read.(io.Closer); ok {
+			closer.Close()
+		}
+		if file == os.Stdin {
+			return nil
+		}
+		return file.Close()
+	}}, nil
+}
+
+// Decompress returns a reader that decompresses the input if it's
+// compressed (by gzip, bzip2, xz or zstd), or returns it as it is
+func Decompress(input io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(input)
+	magic, err := buffered.Peek(6)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, magicGzip):
+		return gzip.NewReader(buffered)
+	case bytes.HasPrefix(magic, magicBzip2):
+		return bzip2.NewReader(buffered), nil
+	case bytes.HasPrefix(magic, magicXz):
+		return xz.NewReader(buffered)
+	case bytes.HasPrefix(magic, magicZstd):
+		zr, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return buffered, nil
+	}
+}
+
+// CreateResultFile creates a file to save results to (see Save()). The
+// output is compressed according to the extension of the file name:
+// .gz, .xz and .zst are supported. Closing it flushes the compressor too.
+func CreateResultFile(filename string) (io.WriteCloser, error) {
+	var compressor func(io.Writer) (io.WriteCloser, error)
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gz", ".gzip":
+		compressor = func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }
+	case ".xz":
+		compressor = func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) }
+	case ".zst", ".zstd":
+		compressor = func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }
+	case ".bz2":
+		return nil, fmt.Errorf("writing bzip2 compressed files is not supported")
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	if compressor == nil {
+		return file, nil
+	}
+
+	buffered := bufio.NewWriter(file)
+	write, err := compressor(buffered)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &writeCloser{write, func() error {
+		err := write.Close()
+		if ferr := buffered.Flush(); err == nil {
+			err = ferr
+		}
+		if ferr := file.Close(); err == nil {
+			err = ferr
+		}
+		return err
+	}}, nil
+}
+
+// readCloser is a reader with a custom close function
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (file *readCloser) Close() error {
+	return file.close()
+}
+
+// writeCloser is a writer with a custom close function
+type writeCloser struct {
+	io.Writer
+	close func() error
+}
+
+func (file *writeCloser) Close() error {
+	return file.close()
+}
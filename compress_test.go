@@ -0,0 +1,98 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robert-kisteleki/goat"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+const compressLines = `{"fw":5080,"type":"ping","msm_id":1001,"prb_id":1,"timestamp":1700000000,"result":[]}
+{"fw":5080,"type":"ping","msm_id":1001,"prb_id":2,"timestamp":1700000001,"result":[]}
+`
+
+// Test if compressed files are written and read back transparently
+func TestCompressedFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"plain.jsonl", "out.gz", "out.xz", "out.zst"} {
+		filename := filepath.Join(dir, name)
+		file, err := goat.CreateResultFile(filename)
+		if err != nil {
+			t.Fatalf("%s: creating failed: %v", name, err)
+		}
+
+		// save via a filter, to see that it works with Save() too
+		filter := goat.NewResultsFilter()
+		filter.FilterFile(writeTemp(t, dir, []byte(compressLines)))
+		filter.Save(file)
+		results := make(chan result.AsyncResult)
+		go filter.GetResults(false, results)
+		for res := range results {
+			if res.Error != nil {
+				t.Fatalf("%s: reading failed: %v", name, res.Error)
+			}
+		}
+		if err := file.Close(); err != nil {
+			t.Fatalf("%s: closing failed: %v", name, err)
+		}
+
+		raw, _ := os.ReadFile(filename)
+		if name != "plain.jsonl" && bytes.Contains(raw, []byte("msm_id")) {
+			t.Errorf("%s: output is not compressed", name)
+		}
+
+		read, err := goat.OpenResultFile(filename)
+		if err != nil {
+			t.Fatalf("%s: opening failed: %v", name, err)
+		}
+		data, err := io.ReadAll(read)
+		read.Close()
+		if err != nil || string(data) != compressLines {
+			t.Errorf("%s: unexpected content (%v): %q", name, err, data)
+		}
+	}
+
+	if _, err := goat.CreateResultFile(filepath.Join(dir, "out.bz2")); err == nil {
+		t.Error("writing bzip2 should not be supported")
+	}
+}
+
+// Test if bzip2 input is recognised
+func TestDecompressBzip2(t *testing.T) {
+	compressed, _ := base64.StdEncoding.DecodeString(bzip2Lines)
+	read, err := goat.Decompress(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("decompressing failed: %v", err)
+	}
+	data, err := io.ReadAll(read)
+	if err != nil || string(data) != compressLines {
+		t.Errorf("unexpected content (%v): %q", err, data)
+	}
+}
+
+func writeTemp(t *testing.T, dir string, data []byte) string {
+	file, err := os.CreateTemp(dir, "input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	return file.Name()
+}
+
+// bzip2 -c of compressLines, base64 encoded
+const bzip2Lines = "QlpoOTFBWSZTWbgmkhQAAFHbgAwQEARy0AAKt6deqiAAYCqppomEMh5QGTTahjTEYRpgAALVHdNOr4h+bKOaSAyZ1ZdK8iSBJFWkg7YixFgVRTetUM2zUmtRXtmhc4N27BsvVsVES5BFoUXrCCji3UfxdyRThQkLgmkhQA=="
@@ -8,32 +8,26 @@ package goat
 
 const version = "v0.8.0"
 
-var (
-	uaString      = "goat " + version
-	apiBaseURL    = "https://atlas.ripe.net/api/v2/"
-	streamBaseURL = "wss://atlas-stream.ripe.net/stream/"
-)
-
-// GetUserAgent returns the user agent as a string
+// GetUserAgent returns the user agent (of the default client) as a string
 func UserAgent() string {
-	return uaString
+	return defaultClient.UserAgent()
 }
 
 // SetAPIBase allows the caller to modify the API to talk to
 // This is really only useful to developers who have access to compatible APIs
+// This affects the default client only; see also NewClient()
 func SetAPIBase(newAPIBaseURL string) {
-	// TODO: check sanity of new API base URL
-	apiBaseURL = newAPIBaseURL
+	defaultClient.SetAPIBase(newAPIBaseURL)
 }
 
 // SetStreamBase allows the caller to modify the stream to talk to
 // This is really only useful to developers who have access to compatible APIs
+// This affects the default client only; see also NewClient()
 func SetStreamBase(newStreamBaseURL string) {
-	// TODO: check sanity of new API base URL
-	streamBaseURL = newStreamBaseURL
+	defaultClient.SetStreamBase(newStreamBaseURL)
 }
 
 // GetStreamBase retrieves the currently configured stream base URL
 func GetStreamBase() string {
-	return streamBaseURL
+	return defaultClient.StreamBase()
 }
@@ -0,0 +1,381 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// Credits object: the balance and its trends, as it comes from the API
+type Credits struct {
+	CurrentBalance            int      `json:"current_balance"`
+	EstimatedDailyIncome      int      `json:"estimated_daily_income"`
+	EstimatedDailyExpenditure int      `json:"estimated_daily_expenditure"`
+	EstimatedDailyBalance     int      `json:"estimated_daily_balance"`
+	CalculationTime           *uniTime `json:"calculation_time"`
+	EstimatedRunoutSeconds    *int     `json:"estimated_runout_seconds"`
+	PastDayResults            int      `json:"past_day_measurement_results"`
+	PastDayCreditsSpent       int      `json:"past_day_credits_spent"`
+	LastDebited               *string  `json:"last_date_debited"`
+	LastCredited              *string  `json:"last_date_credited"`
+}
+
+// CreditItem is one income or expense item, as it comes from the API
+type CreditItem struct {
+	Timestamp   *uniTime `json:"timestamp"`
+	Amount      int      `json:"amount"`
+	Type        string   `json:"type"`
+	Description string   `json:"description"`
+	Probe       *uint    `json:"probe"`
+	Measurement *uint    `json:"measurement"`
+}
+
+// a credit item or an error
+type AsyncCreditItemResult struct {
+	Item  CreditItem
+	Error error
+}
+
+// the API paginates; this describes one such page
+type creditItemListingPage struct {
+	Count    uint         `json:"count"`
+	Next     string       `json:"next"`
+	Previous string       `json:"previous"`
+	Items    []CreditItem `json:"results"`
+}
+
+// ShortString produces a short textual description of the credits
+func (credits *Credits) ShortString() string {
+	return fmt.Sprintf("%d\t%d\t%d\t%d",
+		credits.CurrentBalance,
+		credits.EstimatedDailyIncome,
+		credits.EstimatedDailyExpenditure,
+		credits.EstimatedDailyBalance,
+	)
+}
+
+// LongString produces a longer textual description of the credits
+func (credits *Credits) LongString() string {
+	text := credits.ShortString()
+	text += valueOrNA("", false, credits.EstimatedRunoutSeconds)
+	text += fmt.Sprintf("\t%d\t%d", credits.PastDayResults, credits.PastDayCreditsSpent)
+	text += valueOrNA("", false, credits.LastCredited)
+	text += valueOrNA("", false, credits.LastDebited)
+	text += valueOrNA("", false, credits.CalculationTime)
+	return text
+}
+
+// ShortString produces a short textual description of the credit item
+func (item *CreditItem) ShortString() string {
+	return valueOrNA("", false, item.Timestamp)[1:] +
+		fmt.Sprintf("\t%d\t%s", item.Amount, item.Type)
+}
+
+// LongString produces a longer textual description of the credit item
+func (item *CreditItem) LongString() string {
+	text := item.ShortString()
+	text += valueOrNA("", false, item.Probe)
+	text += valueOrNA("", false, item.Measurement)
+	text += fmt.Sprintf("\t\"%s\"", item.Description)
+	return text
+}
+
+// GetCredits retrieves the credit balance and trends using the default client
+func GetCredits(
+	verbose bool,
+	key *uuid.UUID,
+) (
+	*Credits,
+	error,
+) {
+	return defaultClient.GetCredits(verbose, key)
+}
+
+// GetCreditsContext is like GetCredits but the API call can be cancelled via the context
+func GetCreditsContext(
+	ctx context.Context,
+	verbose bool,
+	key *uuid.UUID,
+) (
+	*Credits,
+	error,
+) {
+	return defaultClient.GetCreditsContext(ctx, verbose, key)
+}
+
+// GetCredits retrieves the credit balance and trends using this client
+func (client *Client) GetCredits(
+	verbose bool,
+	key *uuid.UUID,
+) (
+	*Credits,
+	error,
+) {
+	return client.GetCreditsContext(context.Background(), verbose, key)
+}
+
+// GetCreditsContext is like GetCredits but the API call can be cancelled via the context
+func (client *Client) GetCreditsContext(
+	ctx context.Context,
+	verbose bool,
+	key *uuid.UUID,
+) (
+	*Credits,
+	error,
+) {
+	var credits *Credits
+
+	query := client.apiBaseURL + "credits/?format[datetime]=iso-8601"
+
+	resp, err := client.apiGetRequest(ctx, verbose, query, client.apiKeyFor(key, ApiKeyGetCredits))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, parseAPIError(resp)
+	}
+
+	// grab and store the actual content
+	err = json.NewDecoder(resp.Body).Decode(&credits)
+	if err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+// TransferCredits transfers credits to another account (identified by the
+// e-mail address of the user) using the default client
+func TransferCredits(
+	verbose bool,
+	key *uuid.UUID,
+	recipient string,
+	amount uint,
+	comment string,
+) error {
+	return defaultClient.TransferCredits(verbose, key, recipient, amount, comment)
+}
+
+// TransferCreditsContext is like TransferCredits but the API call can be cancelled via the context
+func TransferCreditsContext(
+	ctx context.Context,
+	verbose bool,
+	key *uuid.UUID,
+	recipient string,
+	amount uint,
+	comment string,
+) error {
+	return defaultClient.TransferCreditsContext(ctx, verbose, key, recipient, amount, comment)
+}
+
+// TransferCredits transfers credits to another account (identified by the
+// e-mail address of the user) using this client
+func (client *Client) TransferCredits(
+	verbose bool,
+	key *uuid.UUID,
+	recipient string,
+	amount uint,
+	comment string,
+) error {
+	return client.TransferCreditsContext(context.Background(), verbose, key, recipient, amount, comment)
+}
+
+// TransferCreditsContext is like TransferCredits but the API call can be cancelled via the context
+func (client *Client) TransferCreditsContext(
+	ctx context.Context,
+	verbose bool,
+	key *uuid.UUID,
+	recipient string,
+	amount uint,
+	comment string,
+) error {
+	type creditTransfer struct {
+		Recipient string `json:"recipient"`
+		Amount    uint   `json:"amount"`
+		Comment   string `json:"comment,omitempty"`
+	}
+
+	if recipient == "" {
+		return fmt.Errorf("recipient must be specified")
+	}
+	if amount == 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+
+	post, err := json.Marshal(creditTransfer{recipient, amount, comment})
+	if err != nil {
+		return err
+	}
+
+	query := client.apiBaseURL + "credits/transfers/"
+	resp, err := client.apiRequest(ctx, verbose, "POST", query, client.apiKeyFor(key, ApiKeyTransferCredits), post)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return parseAPIError(resp)
+	}
+
+	return nil
+}
+
+// CreditItemFilter struct holds specified filters and other options
+// for listing income or expense items
+type CreditItemFilter struct {
+	client  *Client
+	params  url.Values
+	limit   uint
+	verbose bool
+	key     *uuid.UUID
+}
+
+// NewCreditItemFilter prepares a new credit item filter object using the default client
+func NewCreditItemFilter() CreditItemFilter {
+	return defaultClient.NewCreditItemFilter()
+}
+
+// NewCreditItemFilter prepares a new credit item filter object using this client
+func (client *Client) NewCreditItemFilter() CreditItemFilter {
+	filter := CreditItemFilter{}
+	filter.client = client
+	filter.params = url.Values{}
+	filter.params.Add("format[datetime]", "iso-8601")
+	return filter
+}
+
+// Verbose sets verbosity
+func (filter *CreditItemFilter) Verbose(verbose bool) {
+	filter.verbose = verbose
+}
+
+// Limit limits the number of items retrieved
+func (filter *CreditItemFilter) Limit(limit uint) {
+	filter.limit = limit
+}
+
+// ApiKey sets the API key to be used
+// This key should have the required permission (get credits)
+func (filter *CreditItemFilter) ApiKey(key *uuid.UUID) {
+	filter.key = key
+}
+
+// GetIncomeItems returns income items
+// Results (or an error) appear on a channel
+func (filter *CreditItemFilter) GetIncomeItems(
+	items chan AsyncCreditItemResult,
+) {
+	filter.GetIncomeItemsContext(context.Background(), items)
+}
+
+// GetIncomeItemsContext is like GetIncomeItems, but it stops and closes
+// the channel when the context is cancelled
+func (filter *CreditItemFilter) GetIncomeItemsContext(
+	ctx context.Context,
+	items chan AsyncCreditItemResult,
+) {
+	filter.getItems(ctx, "credits/income-items/", items)
+}
+
+// GetExpenseItems returns expense items
+// Results (or an error) appear on a channel
+func (filter *CreditItemFilter) GetExpenseItems(
+	items chan AsyncCreditItemResult,
+) {
+	filter.GetExpenseItemsContext(context.Background(), items)
+}
+
+// GetExpenseItemsContext is like GetExpenseItems, but it stops and closes
+// the channel when the context is cancelled
+func (filter *CreditItemFilter) GetExpenseItemsContext(
+	ctx context.Context,
+	items chan AsyncCreditItemResult,
+) {
+	filter.getItems(ctx, "credits/expense-items/", items)
+}
+
+// IncomeItems is an iterator over income items. Breaking out of the loop
+// stops fetching more pages.
+func (filter *CreditItemFilter) IncomeItems(ctx context.Context) iter.Seq2[CreditItem, error] {
+	return iterate(ctx, filter.GetIncomeItemsContext, splitCreditItem)
+}
+
+// ExpenseItems is an iterator over expense items. Breaking out of the loop
+// stops fetching more pages.
+func (filter *CreditItemFilter) ExpenseItems(ctx context.Context) iter.Seq2[CreditItem, error] {
+	return iterate(ctx, filter.GetExpenseItemsContext, splitCreditItem)
+}
+
+func splitCreditItem(item AsyncCreditItemResult) (CreditItem, error) {
+	return item.Item, item.Error
+}
+
+// getItems does the real work of fetching (paginated) items
+func (filter *CreditItemFilter) getItems(
+	ctx context.Context,
+	path string,
+	items chan AsyncCreditItemResult,
+) {
+	defer close(items)
+
+	client := clientOrDefault(filter.client)
+	key := client.apiKeyFor(filter.key, ApiKeyGetCredits)
+	query := client.apiBaseURL + path + "?" + filter.params.Encode()
+
+	resp, err := client.apiGetRequest(ctx, filter.verbose, query, key)
+
+	// results are paginated with next= (and previous=)
+	var total uint = 0
+	for {
+		if err != nil {
+			sendContext(ctx, items, AsyncCreditItemResult{CreditItem{}, err})
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			sendContext(ctx, items, AsyncCreditItemResult{CreditItem{}, parseAPIError(resp)})
+			return
+		}
+
+		// grab and store the actual content
+		var page creditItemListingPage
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		if err != nil {
+			sendContext(ctx, items, AsyncCreditItemResult{CreditItem{}, err})
+			return
+		}
+
+		// return items while observing the limit
+		for _, item := range page.Items {
+			if !sendContext(ctx, items, AsyncCreditItemResult{item, nil}) {
+				return
+			}
+			total++
+			if filter.limit > 0 && total >= filter.limit {
+				return
+			}
+		}
+
+		// no next page => we're done
+		if page.Next == "" {
+			break
+		}
+
+		// just follow the next link
+		resp, err = client.apiGetRequest(ctx, filter.verbose, page.Next, key)
+	}
+}
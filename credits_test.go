@@ -0,0 +1,98 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Test if credits, (paginated) items and transfers work
+func TestCredits(t *testing.T) {
+	var transfer map[string]any
+	var auth string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		switch {
+		case r.URL.Path == "/credits/":
+			fmt.Fprint(w, `{"current_balance":1000,"estimated_daily_income":20,"estimated_daily_expenditure":30,`+
+				`"estimated_daily_balance":-10,"calculation_time":"2024-01-01T00:00:00Z","estimated_runout_seconds":8640000}`)
+		case r.URL.Path == "/credits/income-items/" && r.URL.Query().Get("page") == "":
+			fmt.Fprintf(w, `{"count":3,"next":"%s/credits/income-items/?page=2","previous":"","results":[`+
+				`{"timestamp":"2024-01-01T00:00:00Z","amount":10,"type":"probe","probe":1},`+
+				`{"timestamp":"2024-01-02T00:00:00Z","amount":11,"type":"probe","probe":1}]}`, server.URL)
+		case r.URL.Path == "/credits/income-items/":
+			fmt.Fprint(w, `{"count":3,"next":"","previous":"","results":[`+
+				`{"timestamp":"2024-01-03T00:00:00Z","amount":12,"type":"anchor"}]}`)
+		case r.URL.Path == "/credits/transfers/" && r.Method == "POST":
+			_ = json.NewDecoder(r.Body).Decode(&transfer)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"status":404,"title":"Not Found","detail":"nope"}}`)
+		}
+	}))
+	defer server.Close()
+
+	key := uuid.New()
+	client := NewClient()
+	client.SetAPIBase(server.URL + "/")
+	client.ApiKey(ApiKeyGetCredits, &key)
+
+	credits, err := client.GetCredits(false, nil)
+	if err != nil {
+		t.Fatalf("getting credits failed: %v", err)
+	}
+	if credits.CurrentBalance != 1000 || credits.EstimatedDailyBalance != -10 ||
+		credits.EstimatedRunoutSeconds == nil || *credits.EstimatedRunoutSeconds != 8640000 {
+		t.Errorf("credits were not parsed properly: %+v", credits)
+	}
+	if auth != "Key "+key.String() {
+		t.Errorf("get_credits API key was not used")
+	}
+
+	filter := client.NewCreditItemFilter()
+	items := make(chan AsyncCreditItemResult)
+	go filter.GetIncomeItems(items)
+	sum := 0
+	n := 0
+	for item := range items {
+		if item.Error != nil {
+			t.Fatalf("getting income items failed: %v", item.Error)
+		}
+		sum += item.Item.Amount
+		n++
+	}
+	if n != 3 || sum != 33 {
+		t.Errorf("income items were not retrieved properly: %d items, %d total", n, sum)
+	}
+
+	filter = client.NewCreditItemFilter()
+	items = make(chan AsyncCreditItemResult)
+	go filter.GetExpenseItems(items)
+	item := <-items
+	if item.Error == nil {
+		t.Error("API error was not reported")
+	}
+
+	if err := client.TransferCredits(false, nil, "", 100, ""); err == nil {
+		t.Error("transfer without recipient was accepted")
+	}
+	if err := client.TransferCredits(false, nil, "someone@example.com", 100, "thanks"); err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	if transfer["recipient"] != "someone@example.com" || transfer["amount"] != 100.0 || transfer["comment"] != "thanks" {
+		t.Errorf("transfer was not sent properly: %v", transfer)
+	}
+}
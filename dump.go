@@ -0,0 +1,221 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat
+
+import (
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dumpFields are the top level fields of a result that can be checked
+// without parsing the whole result
+type dumpFields struct {
+	msmID     uint
+	probeID   uint
+	timestamp int64
+	typ       string
+	af        uint
+	dstAddr   string
+	dstName   string
+}
+
+// Dump turns on dump ingestion mode, meant for files like the public daily
+// dumps, which mix results of many measurements and types: each line is
+// checked against the filters cheaply before it's fully parsed, and it's
+// parsed according to its own type
+func (filter *ResultsFilter) Dump(dump bool) {
+	filter.dump = dump
+}
+
+// FilterMeasurementIDs filters for results of these measurements (useful
+// when reading files that contain results of multiple measurements)
+func (filter *ResultsFilter) FilterMeasurementIDs(list []uint) {
+	filter.msmIDs = list
+}
+
+// FilterType filters for results of this type (ping, traceroute, ...)
+func (filter *ResultsFilter) FilterType(typ string) {
+	filter.resultType = strings.ToLower(typ)
+}
+
+// FilterAddressFamily filters for results with this address family (4 or 6)
+func (filter *ResultsFilter) FilterAddressFamily(af uint) {
+	filter.af = af
+}
+
+// FilterDestination filters for results with this destination, which can
+// be an address or a name
+func (filter *ResultsFilter) FilterDestination(destination string) {
+	filter.destination = destination
+}
+
+// prefilter tells if a line should be fully parsed, and with what type
+// hint: in dump mode it's the type of the line, otherwise it's typehint
+func (filter *ResultsFilter) prefilter(line string, typehint string) (bool, string) {
+	if !filter.dump && len(filter.msmIDs) == 0 && filter.resultType == "" &&
+		filter.af == 0 && filter.destination == "" {
+		return true, typehint
+	}
+
+	fields, ok := scanDumpFields(line)
+	if !ok {
+		// let the parser report the problem
+		return true, ""
+	}
+	if !filter.dump {
+		return filter.matchDumpFields(fields), typehint
+	}
+	return filter.matchDumpFields(fields), fields.typ
+}
+
+// matchDumpFields checks all applicable filters
+func (filter *ResultsFilter) matchDumpFields(fields dumpFields) bool {
+	if len(filter.msmIDs) > 0 && !slices.Contains(filter.msmIDs, fields.msmID) {
+		return false
+	}
+	if filter.resultType != "" && fields.typ != filter.resultType {
+		return false
+	}
+	if filter.af != 0 && fields.af != filter.af {
+		return false
+	}
+	if filter.destination != "" && !matchDestination(filter.destination, fields) {
+		return false
+	}
+	if len(filter.probes) > 0 && !slices.Contains(filter.probes, fields.probeID) {
+		return false
+	}
+	ts := time.Unix(fields.timestamp, 0)
+	if (filter.start != nil && ts.Before(*filter.start)) || (filter.stop != nil && ts.After(*filter.stop)) {
+		return false
+	}
+	return true
+}
+
+// matchDestination matches the destination name or address
+func matchDestination(destination string, fields dumpFields) bool {
+	if strings.EqualFold(destination, fields.dstName) {
+		return true
+	}
+	want, err := netip.ParseAddr(destination)
+	if err != nil {
+		return false
+	}
+	have, err := netip.ParseAddr(fields.dstAddr)
+	return err == nil && have == want
+}
+
+// scanDumpFields extracts some of the top level fields of a result without
+// parsing all of it. It returns false if the line doesn't look like JSON.
+func scanDumpFields(line string) (fields dumpFields, ok bool) {
+	depth := 0
+	expectKey := false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; c {
+		case '{', '[':
+			depth++
+			expectKey = depth == 1 && c == '{'
+		case '}', ']':
+			depth--
+			if depth < 0 {
+				return fields, false
+			}
+		case ',':
+			expectKey = depth == 1
+		case '"':
+			end := scanString(line, i)
+			if end < 0 {
+				return fields, false
+			}
+			if depth != 1 || !expectKey {
+				i = end
+				continue
+			}
+			key := line[i+1 : end]
+
+			j := skipSpace(line, end+1)
+			if j >= len(line) || line[j] != ':' {
+				return fields, false
+			}
+			j = skipSpace(line, j+1)
+			if j >= len(line) {
+				return fields, false
+			}
+
+			// only scalars are interesting, objects and arrays are skipped
+			// by the main loop
+			switch line[j] {
+			case '{', '[':
+				i = j - 1
+			case '"':
+				vend := scanString(line, j)
+				if vend < 0 {
+					return fields, false
+				}
+				fields.set(key, line[j+1:vend])
+				i = vend
+			default:
+				vend := j
+				for vend < len(line) && !strings.ContainsRune(",}] \t", rune(line[vend])) {
+					vend++
+				}
+				fields.set(key, line[j:vend])
+				i = vend - 1
+			}
+			expectKey = false
+		}
+	}
+	return fields, depth == 0
+}
+
+// set a field, if it's interesting
+func (fields *dumpFields) set(key, value string) {
+	number := func() uint64 {
+		n, _ := strconv.ParseUint(value, 10, 64)
+		return n
+	}
+	switch key {
+	case "msm_id":
+		fields.msmID = uint(number())
+	case "prb_id":
+		fields.probeID = uint(number())
+	case "timestamp":
+		fields.timestamp = int64(number())
+	case "type":
+		fields.typ = value
+	case "af":
+		fields.af = uint(number())
+	case "dst_addr":
+		fields.dstAddr = value
+	case "dst_name":
+		fields.dstName = value
+	}
+}
+
+// scanString returns the position of the closing quote of a string
+// starting at pos, or -1 if there's none
+func scanString(line string, pos int) int {
+	for i := pos + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func skipSpace(line string, pos int) int {
+	for pos < len(line) && strings.ContainsRune(" \t\r\n", rune(line[pos])) {
+		pos++
+	}
+	return pos
+}
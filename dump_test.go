@@ -0,0 +1,84 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat_test
+
+import (
+	"testing"
+
+	"github.com/robert-kisteleki/goat"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+// mixed results, as in a daily dump; nested fields should not confuse the prefilter
+const dumpLines = `{"fw":5080,"type":"ping","msm_id":1001,"prb_id":1,"af":4,"dst_addr":"192.0.2.1","dst_name":"a.example","timestamp":1700000000,"result":[{"rtt":1.5}]}
+{"fw":5080,"type":"traceroute","msm_id":5001,"prb_id":2,"af":6,"dst_addr":"2001:db8::1","dst_name":"b.example","timestamp":1700000001,"paris_id":1,"result":[{"hop":1,"result":[{"from":"2001:db8::2","rtt":1.0,"ttl":64,"icmpext":{"version":2,"rfc4884":1,"obj":[{"class":1,"type":1,"mpls":[{"label":1,"exp":0,"s":1,"ttl":1}]}]}}]}]}
+{"fw":5080,"type":"ping","msm_id":1002,"prb_id":3,"af":4,"dst_addr":"192.0.2.2","dst_name":"c \"quoted\" example","timestamp":1700000002,"result":[]}
+{"fw":5080,"type":"traceroute","msm_id":5001,"prb_id":4,"af":4,"dst_addr":"192.0.2.1","dst_name":"a.example","timestamp":1700000003,"paris_id":2,"result":[{"hop":1,"result":[{"x":"*"}]}]}
+{"fw":5080, "type" : "ping", "msm_id" : 1001, "prb_id" : 5, "af" : 6, "dst_addr" : "2001:DB8::1", "timestamp" : 1700000004, "result" : []}
+`
+
+// Test if dump files are filtered and parsed properly
+func TestDump(t *testing.T) {
+	dir := t.TempDir()
+	file := writeTemp(t, dir, []byte(dumpLines))
+
+	tests := []struct {
+		name   string
+		setup  func(*goat.ResultsFilter)
+		probes []uint
+	}{
+		{"all", func(f *goat.ResultsFilter) {}, []uint{1, 2, 3, 4, 5}},
+		{"msm", func(f *goat.ResultsFilter) { f.FilterMeasurementIDs([]uint{1001, 1002}) }, []uint{1, 3, 5}},
+		{"type", func(f *goat.ResultsFilter) { f.FilterType("traceroute") }, []uint{2, 4}},
+		{"af", func(f *goat.ResultsFilter) { f.FilterAddressFamily(6) }, []uint{2, 5}},
+		{"dst addr", func(f *goat.ResultsFilter) { f.FilterDestination("2001:db8::1") }, []uint{2, 5}},
+		{"dst name", func(f *goat.ResultsFilter) { f.FilterDestination("A.example") }, []uint{1, 4}},
+		{"combined", func(f *goat.ResultsFilter) {
+			f.FilterType("ping")
+			f.FilterAddressFamily(4)
+			f.FilterProbeIDs([]uint{3, 4})
+		}, []uint{3}},
+	}
+
+	for _, test := range tests {
+		filter := goat.NewResultsFilter()
+		filter.FilterFile(file)
+		filter.Dump(true)
+		test.setup(&filter)
+
+		results := make(chan result.AsyncResult)
+		go filter.GetResults(false, results)
+		probes := make([]uint, 0)
+		for res := range results {
+			if res.Error != nil {
+				t.Fatalf("%s: unexpected error: %v", test.name, res.Error)
+			}
+			// each result has to be parsed as its own type
+			switch r := (*res.Result).(type) {
+			case *result.PingResult:
+				if r.TypeName() != "ping" {
+					t.Errorf("%s: %s parsed as ping", test.name, r.TypeName())
+				}
+			case *result.TracerouteResult:
+				if r.TypeName() != "traceroute" {
+					t.Errorf("%s: %s parsed as traceroute", test.name, r.TypeName())
+				}
+			}
+			probes = append(probes, (*res.Result).GetProbeID())
+		}
+		if len(probes) != len(test.probes) {
+			t.Errorf("%s: expected probes %v, got %v", test.name, test.probes, probes)
+			continue
+		}
+		for i := range probes {
+			if probes[i] != test.probes[i] {
+				t.Errorf("%s: expected probes %v, got %v", test.name, test.probes, probes)
+				break
+			}
+		}
+	}
+}
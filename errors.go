@@ -0,0 +1,133 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors, API errors can be checked against these with errors.Is()
+var (
+	ErrBadRequest   = errors.New("bad request")       // 400
+	ErrValidation   = errors.New("validation failed") // 400 with details about what was wrong
+	ErrUnauthorized = errors.New("unauthorized")      // 401, missing or bad API key
+	ErrForbidden    = errors.New("forbidden")         // 403, e.g. API key without the needed permission
+	ErrNotFound     = errors.New("not found")         // 404
+	ErrRateLimited  = errors.New("rate limited")      // 429
+	ErrServer       = errors.New("server error")      // 5xx
+)
+
+// APIError is returned when the API responds with an error
+// Use errors.As() to get to the details, or errors.Is() to check for
+// a particular kind of error (ErrNotFound, ErrValidation, ...)
+type APIError struct {
+	StatusCode int            // HTTP status code
+	Code       int            // API specific error code, if any
+	Title      string         // short description, e.g. "Bad Request"
+	Detail     string         // longer description
+	Errors     []ErrorMessage // details, possibly per field (see Source.Pointer)
+}
+
+// Error makes APIError an error
+func (apierr *APIError) Error() string {
+	text := fmt.Sprintf("%d", apierr.StatusCode)
+	if apierr.Title != "" {
+		text += " " + apierr.Title
+	}
+	details := make([]string, 0)
+	if apierr.Detail != "" {
+		details = append(details, apierr.Detail)
+	}
+	for _, e := range apierr.Errors {
+		if e.Source.Pointer != "" {
+			details = append(details, e.Source.Pointer+": "+e.Detail)
+		} else {
+			details = append(details, e.Detail)
+		}
+	}
+	if len(details) > 0 {
+		text += ": " + strings.Join(details, ", ")
+	}
+	return text
+}
+
+// Is allows matching with the sentinel errors
+func (apierr *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return apierr.StatusCode == http.StatusBadRequest
+	case ErrValidation:
+		return apierr.StatusCode == http.StatusBadRequest && len(apierr.Errors) > 0
+	case ErrUnauthorized:
+		return apierr.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return apierr.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return apierr.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return apierr.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return apierr.StatusCode >= 500
+	}
+	return false
+}
+
+// ErrorsFor returns the detailed error messages where the source pointer
+// starts with the specified prefix, e.g. "/probes" or "/definitions/0/target"
+func (apierr *APIError) ErrorsFor(pointer string) []ErrorMessage {
+	found := make([]ErrorMessage, 0)
+	for _, e := range apierr.Errors {
+		if strings.HasPrefix(e.Source.Pointer, pointer) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// something went wrong; see if the error page can be parsed
+// it could be a single error or a bunch of them
+func parseAPIError(resp *http.Response) error {
+	apierr := &APIError{StatusCode: resp.StatusCode}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var decoded MultiErrorResponse
+	if json.Unmarshal(data, &decoded) != nil {
+		// not something we understand, but the status is still useful
+		apierr.Title = http.StatusText(resp.StatusCode)
+		apierr.Detail = strings.TrimSpace(string(data))
+		return apierr
+	}
+
+	// the error may be described on the top level or in "error"
+	for _, detail := range []ErrorDetail{decoded.ErrorDetail, decoded.Error} {
+		if apierr.Title == "" {
+			apierr.Title = detail.Title
+		}
+		if apierr.Detail == "" {
+			apierr.Detail = detail.Detail
+		}
+		if apierr.Code == 0 {
+			apierr.Code = detail.Code
+		}
+		apierr.Errors = append(apierr.Errors, detail.Errors...)
+	}
+	apierr.Errors = append(apierr.Errors, decoded.Errors...)
+	if apierr.Title == "" {
+		apierr.Title = http.StatusText(resp.StatusCode)
+	}
+
+	return apierr
+}
@@ -0,0 +1,97 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test if API errors are parsed into APIError, and if they can be matched
+func TestAPIErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/probes/1/":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"status":404,"code":104,"title":"Not Found","detail":"No such probe"}}`)
+		case "/measurements/":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"status":400,"code":102,"title":"Bad Request","detail":"The provided parameters were not valid.",`+
+				`"errors":[{"source":{"pointer":"/probes/0/requested"},"detail":"Not enough probes available"},`+
+				`{"source":{"pointer":"/definitions/0/target"},"detail":"Bad target"}]}}`)
+		case "/measurements/1/":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"detail":"You do not have permission","status":403,"title":"Forbidden"}`)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, `<html>oops</html>`)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.SetAPIBase(server.URL + "/")
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+
+	// not found, via a filter
+	filter := client.NewProbeFilter()
+	filter.FilterID(1)
+	probes := make(chan AsyncProbeResult)
+	go filter.GetProbes(probes)
+	res := <-probes
+	for range probes {
+	}
+	var apierr *APIError
+	if !errors.As(res.Error, &apierr) {
+		t.Fatalf("not an APIError: %T %v", res.Error, res.Error)
+	}
+	if apierr.StatusCode != 404 || apierr.Code != 104 || apierr.Detail != "No such probe" {
+		t.Errorf("APIError was not parsed properly: %+v", apierr)
+	}
+	if !errors.Is(res.Error, ErrNotFound) || errors.Is(res.Error, ErrUnauthorized) {
+		t.Error("APIError does not match the proper sentinel errors")
+	}
+
+	// validation errors when scheduling
+	spec := client.NewMeasurementSpec()
+	_ = spec.AddProbesCountry("NL", 5000)
+	_ = spec.AddPing("test", "ping.ripe.net", 4, nil, nil)
+	_, err := spec.Schedule()
+	if !errors.Is(err, ErrValidation) || !errors.Is(err, ErrBadRequest) {
+		t.Errorf("validation error was not recognised: %v", err)
+	}
+	if errors.As(err, &apierr) {
+		if len(apierr.Errors) != 2 {
+			t.Errorf("field errors were not parsed properly: %+v", apierr.Errors)
+		}
+		if probeErrors := apierr.ErrorsFor("/probes"); len(probeErrors) != 1 ||
+			probeErrors[0].Detail != "Not enough probes available" {
+			t.Errorf("probe spec errors were not found: %+v", probeErrors)
+		}
+	} else {
+		t.Errorf("not an APIError: %T %v", err, err)
+	}
+
+	// top level error, when stopping
+	err = spec.Stop(1)
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("forbidden error was not recognised: %v", err)
+	}
+	if err.Error() != "403 Forbidden: You do not have permission" {
+		t.Errorf("unexpected error text: %v", err)
+	}
+
+	// something that's not even JSON
+	anchorFilter := client.NewAnchorFilter()
+	_, err = anchorFilter.GetAnchorCount()
+	if !errors.Is(err, ErrServer) {
+		t.Errorf("server error was not recognised: %v", err)
+	}
+}
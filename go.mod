@@ -8,7 +8,9 @@ require (
 	github.com/go-ini/ini v1.67.0
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.3
+	github.com/klauspost/compress v1.17.11
 	github.com/miekg/dns v1.1.68
+	github.com/ulikunitz/xz v0.5.12
 )
 
 require (
@@ -0,0 +1,42 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goattest
+
+import (
+	"bytes"
+	"embed"
+)
+
+//go:embed demo/*
+var demo embed.FS
+
+// LoadDemo loads a small built-in data set: a couple of dozen probes, a few
+// anchors, a ping (1001) and a traceroute (5001) measurement with results
+func (fake *Server) LoadDemo() error {
+	for _, fixture := range []struct {
+		file string
+		list *[]object
+	}{
+		{"demo/probes.json", &fake.probes},
+		{"demo/anchors.json", &fake.anchors},
+		{"demo/measurements.json", &fake.measurements},
+	} {
+		data, err := demo.ReadFile(fixture.file)
+		if err != nil {
+			return err
+		}
+		if err := fake.loadObjectsFrom(fixture.list, data); err != nil {
+			return err
+		}
+	}
+
+	data, err := demo.ReadFile("demo/results.jsonl")
+	if err != nil {
+		return err
+	}
+	return fake.readResults(bytes.NewReader(data))
+}
@@ -0,0 +1,184 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goattest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// query parameters that are not filters
+var nonFilters = []string{"page", "page_size", "sort", "format", "format[datetime]", "fields", "optional_fields"}
+
+// matchObject checks if an object matches all the filters in the query
+func matchObject(obj object, query url.Values) bool {
+	for param, values := range query {
+		if slices.Contains(nonFilters, param) {
+			continue
+		}
+		field, op, _ := strings.Cut(param, "__")
+		value, ok := obj[field]
+		if !ok {
+			// unknown filters are ignored
+			continue
+		}
+		for _, want := range values {
+			if !matchValue(value, op, want) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchValue checks one field against one filter
+func matchValue(value any, op string, want string) bool {
+	// objects like the status are matched on their ID
+	if obj, ok := value.(object); ok {
+		value = obj["id"]
+	}
+
+	// lists (e.g. tags) match if all the wanted items are in them
+	if list, ok := value.([]any); ok {
+		for _, item := range strings.Split(want, ",") {
+			found := false
+			for _, elem := range list {
+				if obj, ok := elem.(object); ok {
+					elem = obj["slug"]
+				}
+				if scalarString(elem) == item {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+
+	have := scalarString(value)
+	switch op {
+	case "":
+		return have == want
+	case "in":
+		return slices.Contains(strings.Split(want, ","), have)
+	case "contains":
+		return strings.Contains(have, want)
+	case "startswith":
+		return strings.HasPrefix(have, want)
+	case "endswith":
+		return strings.HasSuffix(have, want)
+	case "gt", "gte", "lt", "lte":
+		h, ok1 := toFloat(value)
+		w, err := strconv.ParseFloat(want, 64)
+		if !ok1 || err != nil {
+			return false
+		}
+		switch op {
+		case "gt":
+			return h > w
+		case "gte":
+			return h >= w
+		case "lt":
+			return h < w
+		default:
+			return h <= w
+		}
+	}
+	// unknown operators are ignored
+	return true
+}
+
+// scalarString turns a JSON value into something comparable to a query value
+func scalarString(value any) string {
+	switch t := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// toFloat turns a number, or a timestamp, into a float
+func toFloat(value any) (float64, bool) {
+	switch t := value.(type) {
+	case json.Number:
+		f, err := t.Float64()
+		return f, err == nil
+	case float64:
+		return t, true
+	case int:
+		return float64(t), true
+	case uint:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case string:
+		if ts, err := time.Parse(time.RFC3339, t); err == nil {
+			return float64(ts.Unix()), true
+		}
+	}
+	return 0, false
+}
+
+func toInt(value any) (int64, bool) {
+	f, ok := toFloat(value)
+	return int64(f), ok
+}
+
+// sortObjects sorts by a field, "-" prefix means descending
+func sortObjects(list []object, by string) {
+	if by == "" {
+		by = "id"
+	}
+	field, descending := strings.CutPrefix(by, "-")
+	sort.SliceStable(list, func(i, j int) bool {
+		c := compareValues(list[i][field], list[j][field])
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// compareValues compares numerically if possible, as strings otherwise
+func compareValues(a, b any) int {
+	fa, oka := toFloat(a)
+	fb, okb := toFloat(b)
+	if oka && okb {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(scalarString(a), scalarString(b))
+}
+
+// findByID looks up an object by its ID
+func findByID(list []object, id uint) object {
+	for _, obj := range list {
+		if n, ok := toInt(obj["id"]); ok && uint(n) == id {
+			return obj
+		}
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goattest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/robert-kisteleki/goat"
+)
+
+// serve everything under measurements/
+func (fake *Server) serveMeasurements(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) == 0 {
+		switch r.Method {
+		case "GET":
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			fake.serveListing(w, r, fake.measurements)
+		case "POST":
+			fake.createMeasurements(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+		return
+	}
+
+	if parts[0] == "my" {
+		if r.Header.Get("Authorization") == "" {
+			writeError(w, http.StatusForbidden, "Authentication credentials were not provided.")
+			return
+		}
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		fake.serveListing(w, r, fake.measurements)
+		return
+	}
+
+	id64, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	id := uint(id64)
+
+	switch {
+	case len(parts) == 1 && r.Method == "GET":
+		fake.serveObjects(w, r, &fake.measurements, parts)
+	case len(parts) == 1 && r.Method == "DELETE":
+		fake.stopMeasurement(w, id)
+	case len(parts) == 2 && parts[1] == "participation-requests" && r.Method == "POST":
+		fake.participationRequest(w, r, id)
+	case len(parts) == 2 && parts[1] == "results" && r.Method == "GET":
+		fake.serveResults(w, r, id, false)
+	case len(parts) == 2 && parts[1] == "latest" && r.Method == "GET":
+		fake.serveResults(w, r, id, true)
+	case len(parts) == 2 && parts[1] == "status-check" && r.Method == "GET":
+		fake.serveStatusCheck(w, id)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// createMeasurements checks the specification and creates measurements
+func (fake *Server) createMeasurements(w http.ResponseWriter, r *http.Request) {
+	type definition struct {
+		Type        string    `json:"type"`
+		AF          uint      `json:"af"`
+		Target      *string   `json:"target"`
+		Description string    `json:"description"`
+		Interval    *uint     `json:"interval"`
+		Tags        *[]string `json:"tags"`
+	}
+	type probeSet struct {
+		Type      string `json:"type"`
+		Value     string `json:"value"`
+		Requested int    `json:"requested"`
+	}
+	var spec struct {
+		Definitions []definition `json:"definitions"`
+		Probes      []probeSet   `json:"probes"`
+		OneOff      bool         `json:"is_oneoff"`
+		Start       *int64       `json:"start_time"`
+		Stop        *int64       `json:"stop_time"`
+	}
+
+	if r.Header.Get("Authorization") == "" {
+		writeError(w, http.StatusForbidden, "Authentication credentials were not provided.")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("JSON parse error - %v", err))
+		return
+	}
+
+	// validate, collecting per-field errors like the API does
+	problems := make([]object, 0)
+	problem := func(pointer, detail string) {
+		problems = append(problems, object{"source": object{"pointer": pointer}, "detail": detail})
+	}
+	if len(spec.Definitions) == 0 {
+		problem("/definitions", "This field is required.")
+	}
+	if len(spec.Probes) == 0 {
+		problem("/probes", "This field is required.")
+	}
+	for i, def := range spec.Definitions {
+		if !slices.Contains(goat.MeasurementTypes, def.Type) {
+			problem(fmt.Sprintf("/definitions/%d/type", i), "Not a valid measurement type.")
+		}
+		if def.AF != 4 && def.AF != 6 {
+			problem(fmt.Sprintf("/definitions/%d/af", i), "Address family must be 4 or 6.")
+		}
+		if def.Description == "" {
+			problem(fmt.Sprintf("/definitions/%d/description", i), "This field is required.")
+		}
+		if def.Type != "dns" && (def.Target == nil || *def.Target == "") {
+			problem(fmt.Sprintf("/definitions/%d/target", i), "This field is required.")
+		}
+	}
+	requested := 0
+	for i, probes := range spec.Probes {
+		if probes.Requested == 0 || probes.Requested < -1 {
+			problem(fmt.Sprintf("/probes/%d/requested", i), "Ensure this value is greater than 0.")
+		}
+		requested += probes.Requested
+	}
+	if len(problems) > 0 {
+		writeError(w, http.StatusBadRequest, "The provided parameters were not valid.", problems...)
+		return
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	ids := make([]uint, 0)
+	for _, def := range spec.Definitions {
+		ts := now()
+		if spec.Start != nil {
+			ts = *spec.Start
+		}
+		msm := object{
+			"id":               fake.nextMsmID,
+			"type":             def.Type,
+			"af":               def.AF,
+			"description":      def.Description,
+			"is_oneoff":        spec.OneOff,
+			"is_public":        true,
+			"creation_time":    now(),
+			"start_time":       ts,
+			"stop_time":        nil,
+			"status":           object{"id": goat.MeasurementStatusScheduled, "name": "Scheduled", "when": now()},
+			"probes_requested": requested,
+			"probes_scheduled": requested,
+			"probes":           []any{},
+			"tags":             []any{},
+		}
+		if def.Target != nil {
+			msm["target"] = *def.Target
+		}
+		if spec.Stop != nil {
+			msm["stop_time"] = *spec.Stop
+		}
+		if def.Interval != nil {
+			msm["interval"] = *def.Interval
+		}
+		if def.Tags != nil {
+			tags := make([]any, 0)
+			for _, tag := range *def.Tags {
+				tags = append(tags, tag)
+			}
+			msm["tags"] = tags
+		}
+		fake.measurements = append(fake.measurements, msm)
+		ids = append(ids, fake.nextMsmID)
+		fake.nextMsmID++
+	}
+
+	writeJSON(w, http.StatusCreated, object{"measurements": ids})
+}
+
+// stopMeasurement marks a measurement as stopped
+func (fake *Server) stopMeasurement(w http.ResponseWriter, id uint) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	msm := findByID(fake.measurements, id)
+	if msm == nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	msm["status"] = object{"id": goat.MeasurementStatusStopped, "name": "Stopped", "when": now()}
+	msm["stop_time"] = now()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// participationRequest accepts probe additions or removals
+func (fake *Server) participationRequest(w http.ResponseWriter, r *http.Request, id uint) {
+	var requests []struct {
+		Action    string `json:"action"`
+		Requested int    `json:"requested"`
+		Type      string `json:"type"`
+		Value     string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("JSON parse error - %v", err))
+		return
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if findByID(fake.measurements, id) == nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	ids := make([]uint, 0)
+	for i, req := range requests {
+		if req.Action != "add" && req.Action != "remove" {
+			writeError(w, http.StatusBadRequest, "The provided parameters were not valid.",
+				object{"source": object{"pointer": fmt.Sprintf("/%d/action", i)}, "detail": "Not a valid action."})
+			return
+		}
+		ids = append(ids, fake.nextRequest)
+		fake.nextRequest++
+	}
+	writeJSON(w, http.StatusCreated, object{"request_ids": ids})
+}
+
+// serveResults serves (filtered) results or the latest ones per probe
+func (fake *Server) serveResults(w http.ResponseWriter, r *http.Request, id uint, latest bool) {
+	query := r.URL.Query()
+	var start, stop int64 = 0, 1 << 62
+	if s := query.Get("start"); s != "" {
+		start, _ = strconv.ParseInt(s, 10, 64)
+	}
+	if s := query.Get("stop"); s != "" {
+		stop, _ = strconv.ParseInt(s, 10, 64)
+	}
+	probes := make([]uint, 0)
+	if s := query.Get("probe_ids"); s != "" {
+		for _, p := range strings.Split(s, ",") {
+			n, err := strconv.ParseUint(p, 10, 32)
+			if err == nil {
+				probes = append(probes, uint(n))
+			}
+		}
+	}
+
+	fake.mu.Lock()
+	known := findByID(fake.measurements, id) != nil
+	matched := make([]storedResult, 0)
+	for _, res := range fake.results {
+		if res.msmID != id {
+			continue
+		}
+		known = true
+		if res.timestamp < start || res.timestamp > stop ||
+			(len(probes) > 0 && !slices.Contains(probes, res.probeID)) {
+			continue
+		}
+		matched = append(matched, res)
+	}
+	fake.mu.Unlock()
+
+	if !known {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if latest {
+		last := make(map[uint]int)
+		for i, res := range matched {
+			if j, ok := last[res.probeID]; !ok || matched[j].timestamp <= res.timestamp {
+				last[res.probeID] = i
+			}
+		}
+		latestResults := make([]storedResult, 0)
+		for i, res := range matched {
+			if last[res.probeID] == i {
+				latestResults = append(latestResults, res)
+			}
+		}
+		matched = latestResults
+	}
+
+	slices.SortStableFunc(matched, func(a, b storedResult) int {
+		return int(a.timestamp - b.timestamp)
+	})
+
+	if query.Get("format") == "txt" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		for _, res := range matched {
+			fmt.Fprintln(w, res.line)
+		}
+		return
+	}
+
+	lines := make([]json.RawMessage, 0)
+	for _, res := range matched {
+		lines = append(lines, json.RawMessage(res.line))
+	}
+	writeJSON(w, http.StatusOK, lines)
+}
+
+// serveStatusCheck gives a status check without alerts
+func (fake *Server) serveStatusCheck(w http.ResponseWriter, id uint) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if findByID(fake.measurements, id) == nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, object{"global_alert": false, "total_alerts": 0, "probes": object{}})
+}
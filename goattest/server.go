@@ -0,0 +1,434 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+/*
+  Package goattest provides an in-process fake RIPE Atlas API (and stream)
+  for tests and demos. It serves paginated probe, anchor and measurement
+  listings from fixtures, accepts new measurements, serves results from
+  JSONL data and speaks the result streaming protocol over a websocket.
+
+  Filtering of listings is generic: a query parameter matches the field of
+  the same name in the fixture objects, optionally with the usual suffixes
+  (__in, __gt, __gte, __lt, __lte, __contains, __startswith, __endswith).
+  Parameters that don't correspond to any field are ignored.
+*/
+
+package goattest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robert-kisteleki/goat"
+)
+
+// an object as it appears in listings
+type object = map[string]any
+
+// a result line along with the fields we need to filter on
+type storedResult struct {
+	line        string
+	msmID       uint
+	probeID     uint
+	timestamp   int64
+	typ         string
+	from        string
+	source      string
+	destination string
+}
+
+// Server is a fake Atlas API and stream
+type Server struct {
+	server *httptest.Server
+
+	mu           sync.Mutex
+	probes       []object
+	anchors      []object
+	measurements []object
+	results      []storedResult
+	nextMsmID    uint
+	nextRequest  uint
+	subscribers  map[*subscriber]bool
+
+	// PageSize is the default page size for listings
+	PageSize int
+}
+
+// New starts a fake Atlas on a local port; use Close() when done
+func New() *Server {
+	return NewWithListener(nil)
+}
+
+// NewWithListener starts a fake Atlas using the specified listener,
+// e.g. to run it on a well known port. A nil listener means a local port.
+func NewWithListener(listener net.Listener) *Server {
+	fake := &Server{
+		nextMsmID:   90000001,
+		nextRequest: 1,
+		subscribers: make(map[*subscriber]bool),
+		PageSize:    50,
+	}
+
+	fake.server = httptest.NewUnstartedServer(fake)
+	if listener != nil {
+		fake.server.Listener.Close()
+		fake.server.Listener = listener
+	}
+	fake.server.Start()
+	return fake
+}
+
+// Close shuts down the fake, including stream connections
+func (fake *Server) Close() {
+	fake.mu.Lock()
+	for sub := range fake.subscribers {
+		sub.conn.Close()
+	}
+	fake.mu.Unlock()
+	fake.server.Close()
+}
+
+// URL returns the base URL of the fake
+func (fake *Server) URL() string {
+	return fake.server.URL
+}
+
+// APIBase returns the URL to use with SetAPIBase()
+func (fake *Server) APIBase() string {
+	return fake.server.URL + "/api/v2/"
+}
+
+// StreamBase returns the URL to use with SetStreamBase()
+func (fake *Server) StreamBase() string {
+	return "ws" + strings.TrimPrefix(fake.server.URL, "http") + "/stream/"
+}
+
+// Client returns a new goat client that talks to this fake
+func (fake *Server) Client() *goat.Client {
+	client := goat.NewClient()
+	client.SetAPIBase(fake.APIBase())
+	client.SetStreamBase(fake.StreamBase())
+	return client
+}
+
+// AddProbes adds probes to the fixtures. Items can be anything that
+// marshals to a JSON object, e.g. raw JSON, maps or structs.
+func (fake *Server) AddProbes(items ...any) error {
+	return fake.addObjects(&fake.probes, items)
+}
+
+// AddAnchors adds anchors to the fixtures; see AddProbes()
+func (fake *Server) AddAnchors(items ...any) error {
+	return fake.addObjects(&fake.anchors, items)
+}
+
+// AddMeasurements adds measurements to the fixtures; see AddProbes()
+func (fake *Server) AddMeasurements(items ...any) error {
+	return fake.addObjects(&fake.measurements, items)
+}
+
+// LoadProbes loads probe fixtures from a file containing a JSON array
+func (fake *Server) LoadProbes(filename string) error {
+	return fake.loadObjects(&fake.probes, filename)
+}
+
+// LoadAnchors loads anchor fixtures from a file containing a JSON array
+func (fake *Server) LoadAnchors(filename string) error {
+	return fake.loadObjects(&fake.anchors, filename)
+}
+
+// LoadMeasurements loads measurement fixtures from a file containing a JSON array
+func (fake *Server) LoadMeasurements(filename string) error {
+	return fake.loadObjects(&fake.measurements, filename)
+}
+
+// AddResults adds results, one JSON object per item, to the fixtures.
+// The measurement they belong to is taken from the msm_id field.
+func (fake *Server) AddResults(lines ...string) error {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	for _, line := range lines {
+		res, err := parseResult(line)
+		if err != nil {
+			return err
+		}
+		fake.results = append(fake.results, res)
+	}
+	return nil
+}
+
+// LoadResults loads results from a JSONL file (one result per line)
+func (fake *Server) LoadResults(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return fake.readResults(file)
+}
+
+// MeasurementStatus returns the status ID of a measurement (see
+// goat.MeasurementStatus*), and whether the measurement exists at all
+func (fake *Server) MeasurementStatus(id uint) (uint, bool) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	msm := findByID(fake.measurements, id)
+	if msm == nil {
+		return 0, false
+	}
+	if status, ok := msm["status"].(object); ok {
+		n, _ := toInt(status["id"])
+		return uint(n), true
+	}
+	return 0, true
+}
+
+func (fake *Server) readResults(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lines := make([]string, 0)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fake.AddResults(lines...)
+}
+
+func (fake *Server) addObjects(list *[]object, items []any) error {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	for _, item := range items {
+		var data []byte
+		switch t := item.(type) {
+		case string:
+			data = []byte(t)
+		case []byte:
+			data = t
+		case json.RawMessage:
+			data = t
+		default:
+			var err error
+			data, err = json.Marshal(item)
+			if err != nil {
+				return err
+			}
+		}
+		obj, err := decodeObject(data)
+		if err != nil {
+			return err
+		}
+		*list = append(*list, obj)
+	}
+	return nil
+}
+
+func (fake *Server) loadObjects(list *[]object, filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return fake.loadObjectsFrom(list, data)
+}
+
+func (fake *Server) loadObjectsFrom(list *[]object, data []byte) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	anys := make([]any, len(items))
+	for i, item := range items {
+		anys[i] = item
+	}
+	return fake.addObjects(list, anys)
+}
+
+// ServeHTTP makes the fake an http.Handler
+func (fake *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+
+	if strings.HasPrefix(path, "/stream/") {
+		fake.serveStream(w, r)
+		return
+	}
+
+	if !strings.HasPrefix(path, "/api/v2/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/v2/"), "/"), "/")
+
+	switch {
+	case parts[0] == "probes":
+		fake.serveObjects(w, r, &fake.probes, parts[1:])
+	case parts[0] == "anchors":
+		fake.serveObjects(w, r, &fake.anchors, parts[1:])
+	case parts[0] == "measurements":
+		fake.serveMeasurements(w, r, parts[1:])
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// serve a listing, or one object by ID; the list is only read under the
+// lock, as fixtures can be added while the server is running
+func (fake *Server) serveObjects(w http.ResponseWriter, r *http.Request, fixtures *[]object, parts []string) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	list := *fixtures
+
+	switch len(parts) {
+	case 0:
+		fake.serveListing(w, r, list)
+	case 1:
+		var id uint
+		if _, err := fmt.Sscan(parts[0], &id); err != nil {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		obj := findByID(list, id)
+		if obj == nil {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, obj)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// serve a filtered, sorted and paginated listing
+func (fake *Server) serveListing(w http.ResponseWriter, r *http.Request, list []object) {
+	query := r.URL.Query()
+
+	matched := make([]object, 0)
+	for _, obj := range list {
+		if matchObject(obj, query) {
+			matched = append(matched, obj)
+		}
+	}
+	sortObjects(matched, query.Get("sort"))
+
+	pageSize := fake.PageSize
+	if ps := query.Get("page_size"); ps != "" {
+		_, _ = fmt.Sscan(ps, &pageSize)
+	}
+	page := 1
+	if p := query.Get("page"); p != "" {
+		_, _ = fmt.Sscan(p, &page)
+	}
+
+	results := make([]object, 0)
+	next := ""
+	if pageSize > 0 {
+		from := min((page-1)*pageSize, len(matched))
+		to := min(from+pageSize, len(matched))
+		results = matched[from:to]
+		if to < len(matched) {
+			nextQuery := r.URL.Query()
+			nextQuery.Set("page", fmt.Sprint(page+1))
+			next = fmt.Sprintf("%s%s?%s", fake.server.URL, r.URL.Path, nextQuery.Encode())
+		}
+	}
+
+	writeJSON(w, http.StatusOK, object{
+		"count":    len(matched),
+		"next":     nullable(next),
+		"previous": nil,
+		"results":  results,
+	})
+}
+
+func nullable(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// errors look like what the API would send
+func writeError(w http.ResponseWriter, status int, detail string, errors ...object) {
+	body := object{
+		"status": status,
+		"title":  http.StatusText(status),
+		"detail": detail,
+	}
+	if len(errors) > 0 {
+		body["errors"] = errors
+	}
+	writeJSON(w, status, object{"error": body})
+}
+
+// decode a JSON object keeping numbers as json.Number
+func decodeObject(data []byte) (object, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var obj object
+	if err := decoder.Decode(&obj); err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("not a JSON object: %s", string(data))
+	}
+	return obj, nil
+}
+
+func parseResult(line string) (storedResult, error) {
+	var fields struct {
+		MsmID       uint   `json:"msm_id"`
+		ProbeID     uint   `json:"prb_id"`
+		Timestamp   int64  `json:"timestamp"`
+		Type        string `json:"type"`
+		From        string `json:"from"`
+		Source      string `json:"src_addr"`
+		Destination string `json:"dst_addr"`
+	}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return storedResult{}, fmt.Errorf("cannot parse result: %v", err)
+	}
+	return storedResult{
+		line:        line,
+		msmID:       fields.MsmID,
+		probeID:     fields.ProbeID,
+		timestamp:   fields.Timestamp,
+		typ:         fields.Type,
+		from:        fields.From,
+		source:      fields.Source,
+		destination: fields.Destination,
+	}, nil
+}
+
+func now() int64 {
+	return time.Now().Unix()
+}
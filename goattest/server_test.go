@@ -0,0 +1,197 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goattest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/robert-kisteleki/goat"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+func newDemo(t *testing.T) *Server {
+	fake := New()
+	t.Cleanup(fake.Close)
+	if err := fake.LoadDemo(); err != nil {
+		t.Fatalf("loading demo data failed: %v", err)
+	}
+	return fake
+}
+
+// Test if listings are filtered and paginated
+func TestProbeListing(t *testing.T) {
+	fake := newDemo(t)
+	fake.PageSize = 5
+	client := fake.Client()
+
+	filter := client.NewProbeFilter()
+	filter.FilterCountry("NL")
+	filter.Limit(100)
+	probes := make(chan goat.AsyncProbeResult)
+	go filter.GetProbes(probes)
+	ids := make([]uint, 0)
+	for probe := range probes {
+		if probe.Error != nil {
+			t.Fatalf("listing probes failed: %v", probe.Error)
+		}
+		if probe.Probe.CountryCode != "NL" {
+			t.Errorf("probe %d is not in NL", probe.Probe.ID)
+		}
+		ids = append(ids, probe.Probe.ID)
+	}
+	if len(ids) != 4 {
+		t.Errorf("expected 4 probes, got %v", ids)
+	}
+
+	filter = client.NewProbeFilter()
+	filter.FilterIDin([]uint{1, 2, 3, 100})
+	count, err := filter.GetProbeCount()
+	if err != nil || count != 3 {
+		t.Errorf("probe count is wrong: %d %v", count, err)
+	}
+
+	// more than one page
+	filter = client.NewProbeFilter()
+	filter.Limit(12)
+	probes = make(chan goat.AsyncProbeResult)
+	go filter.GetProbes(probes)
+	n := 0
+	for probe := range probes {
+		if probe.Error != nil {
+			t.Fatalf("listing probes failed: %v", probe.Error)
+		}
+		n++
+	}
+	if n != 12 {
+		t.Errorf("expected 12 probes, got %d", n)
+	}
+
+	probe, err := client.GetProbe(false, 10)
+	if err != nil || probe.ID != 10 {
+		t.Errorf("getting one probe failed: %v", err)
+	}
+	_, err = client.GetProbe(false, 1000)
+	if !errors.Is(err, goat.ErrNotFound) {
+		t.Errorf("getting a nonexistent probe did not fail properly: %v", err)
+	}
+}
+
+// Test if fixtures can be added while the server is in use (run with -race)
+func TestConcurrentFixtures(t *testing.T) {
+	fake := newDemo(t)
+	client := fake.Client()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := uint(0); i < 20; i++ {
+			if err := fake.AddProbes(map[string]any{"id": 5000 + i}); err != nil {
+				t.Errorf("adding a probe failed: %v", err)
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		if _, err := client.GetProbe(false, 10); err != nil {
+			t.Errorf("getting a probe failed: %v", err)
+		}
+	}
+	<-done
+
+	if _, err := client.GetProbe(false, 5019); err != nil {
+		t.Errorf("added probe is not served: %v", err)
+	}
+}
+
+// Test if measurements can be scheduled and stopped
+func TestMeasurementLifecycle(t *testing.T) {
+	fake := newDemo(t)
+	client := fake.Client()
+
+	spec := client.NewMeasurementSpec()
+	_ = spec.AddProbesCountry("NL", 3)
+	_ = spec.AddPing("ping test", "example.com", 4, nil, nil)
+	_ = spec.AddTrace("trace test", "example.com", 4, nil, nil)
+	if _, err := spec.Schedule(); !errors.Is(err, goat.ErrForbidden) {
+		t.Errorf("scheduling without an API key did not fail properly: %v", err)
+	}
+
+	key := uuid.New()
+	spec.ApiKey(&key)
+	ids, err := spec.Schedule()
+	if err != nil {
+		t.Fatalf("scheduling failed: %v", err)
+	}
+	if len(ids) != 2 || ids[0] == ids[1] {
+		t.Fatalf("unexpected measurement IDs: %v", ids)
+	}
+	if status, ok := fake.MeasurementStatus(ids[0]); !ok || status != goat.MeasurementStatusScheduled {
+		t.Errorf("new measurement is not scheduled: %d %v", status, ok)
+	}
+
+	msm, err := client.GetMeasurement(false, ids[1], nil)
+	if err != nil || msm.Type != "traceroute" || msm.Target != "example.com" {
+		t.Errorf("new measurement was not stored properly: %+v %v", msm, err)
+	}
+
+	if err := spec.Stop(ids[0]); err != nil {
+		t.Fatalf("stopping failed: %v", err)
+	}
+	if status, _ := fake.MeasurementStatus(ids[0]); status != goat.MeasurementStatusStopped {
+		t.Errorf("measurement was not stopped: %d", status)
+	}
+
+	if err := spec.Stop(123); !errors.Is(err, goat.ErrNotFound) {
+		t.Errorf("stopping a nonexistent measurement did not fail properly: %v", err)
+	}
+}
+
+// Test if results and latest results can be downloaded
+func TestResults(t *testing.T) {
+	fake := newDemo(t)
+	client := fake.Client()
+
+	fetch := func(filter goat.ResultsFilter) []result.Result {
+		results := make(chan result.AsyncResult)
+		go filter.GetResults(false, results)
+		list := make([]result.Result, 0)
+		for res := range results {
+			if res.Error != nil {
+				t.Fatalf("getting results failed: %v", res.Error)
+			}
+			list = append(list, *res.Result)
+		}
+		return list
+	}
+
+	filter := client.NewResultsFilter()
+	filter.FilterID(1001)
+	filter.FilterProbeIDs([]uint{1, 2})
+	list := fetch(filter)
+	if len(list) != 8 {
+		t.Errorf("expected 8 results, got %d", len(list))
+	}
+	for _, res := range list {
+		if res.TypeName() != "ping" || (res.GetProbeID() != 1 && res.GetProbeID() != 2) {
+			t.Errorf("unexpected result: %v", res)
+		}
+	}
+
+	filter = client.NewResultsFilter()
+	filter.FilterID(5001)
+	filter.FilterLatest()
+	list = fetch(filter)
+	if len(list) != 21 {
+		t.Errorf("expected 21 latest results, got %d", len(list))
+	}
+	for _, res := range list {
+		if res.TypeName() != "traceroute" || res.GetTimeStamp().Unix() < 1700001800 {
+			t.Errorf("result is not the latest: %v", res)
+		}
+	}
+}
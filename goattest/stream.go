@@ -0,0 +1,245 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goattest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// a websocket client of the fake stream
+type subscriber struct {
+	conn          *websocket.Conn
+	write         sync.Mutex
+	subscriptions []subscription
+}
+
+// subscription parameters as sent by the client
+type subscription struct {
+	StreamType         string `json:"streamType"`
+	Measurement        uint   `json:"msm"`
+	Probe              uint   `json:"prb"`
+	SourceAddress      string `json:"sourceAddress"`
+	DestinationAddress string `json:"destinationAddress"`
+	Type               string `json:"type"`
+	SendBacklog        bool   `json:"sendBacklog"`
+	StartTime          int64  `json:"startTime"`
+	StopTime           int64  `json:"stopTime"`
+	Speed              uint   `json:"speed"`
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// send a message of the stream protocol
+func (sub *subscriber) send(msgType string, payload any) error {
+	// not using WriteJSON as that adds a newline, unlike the real stream
+	msg, err := json.Marshal([]any{msgType, payload})
+	if err != nil {
+		return err
+	}
+	sub.write.Lock()
+	defer sub.write.Unlock()
+	return sub.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+// matches checks if a result belongs to a subscription
+func (s subscription) matches(res storedResult) bool {
+	return s.StreamType == "result" &&
+		(s.Measurement == 0 || s.Measurement == res.msmID) &&
+		(s.Probe == 0 || s.Probe == res.probeID) &&
+		(s.SourceAddress == "" || s.SourceAddress == res.from || s.SourceAddress == res.source) &&
+		(s.DestinationAddress == "" || s.DestinationAddress == res.destination) &&
+		(s.Type == "" || s.Type == res.typ) &&
+		(s.StartTime == 0 || s.StartTime <= res.timestamp) &&
+		(s.StopTime == 0 || s.StopTime >= res.timestamp)
+}
+
+// serveStream handles one websocket connection
+func (fake *Server) serveStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	sub := &subscriber{conn: conn}
+
+	fake.mu.Lock()
+	fake.subscribers[sub] = true
+	fake.mu.Unlock()
+
+	defer func() {
+		fake.mu.Lock()
+		delete(fake.subscribers, sub)
+		fake.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		var msg []json.RawMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		var msgType string
+		if len(msg) != 2 || json.Unmarshal(msg[0], &msgType) != nil {
+			_ = sub.send("atlas_error", "malformed message")
+			continue
+		}
+
+		switch msgType {
+		case "atlas_subscribe":
+			var params subscription
+			if err := json.Unmarshal(msg[1], &params); err != nil ||
+				(params.StreamType != "result" && params.StreamType != "probestatus") {
+				_ = sub.send("atlas_error", fmt.Sprintf("invalid subscription: %s", string(msg[1])))
+				continue
+			}
+			fake.subscribe(sub, params, msg[1])
+		case "atlas_unsubscribe":
+			var params subscription
+			if err := json.Unmarshal(msg[1], &params); err != nil {
+				_ = sub.send("atlas_error", fmt.Sprintf("invalid subscription: %s", string(msg[1])))
+				continue
+			}
+			fake.unsubscribe(sub, params, msg[1])
+		default:
+			_ = sub.send("atlas_error", fmt.Sprintf("unknown message type: %s", msgType))
+		}
+	}
+}
+
+// subscribe confirms a subscription and sends the backlog if asked to
+func (fake *Server) subscribe(sub *subscriber, params subscription, raw json.RawMessage) {
+	// hold the lock so that published results don't overtake the backlog
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	sub.subscriptions = append(sub.subscriptions, params)
+	if sub.send("atlas_subscribed", raw) != nil {
+		return
+	}
+	if params.StreamType != "result" {
+		return
+	}
+	if params.StartTime != 0 {
+		replay := make([]storedResult, 0)
+		for _, res := range fake.results {
+			if params.matches(res) {
+				replay = append(replay, res)
+			}
+		}
+		slices.SortStableFunc(replay, func(a, b storedResult) int {
+			return int(a.timestamp - b.timestamp)
+		})
+		go sub.replay(replay, params.Speed)
+		return
+	}
+	if !params.SendBacklog {
+		return
+	}
+	for _, res := range fake.results {
+		if params.matches(res) {
+			if sub.send("atlas_result", json.RawMessage(res.line)) != nil {
+				return
+			}
+		}
+	}
+	_ = sub.send("atlas_backlog_sent", raw)
+}
+
+// replay sends past results, keeping their (accelerated) timing
+func (sub *subscriber) replay(results []storedResult, speed uint) {
+	if speed == 0 {
+		speed = 1
+	}
+	for i, res := range results {
+		if i > 0 {
+			gap := time.Duration(res.timestamp-results[i-1].timestamp) * time.Second
+			time.Sleep(gap / time.Duration(speed))
+		}
+		if sub.send("atlas_result", json.RawMessage(res.line)) != nil {
+			return
+		}
+	}
+}
+
+// unsubscribe removes a subscription, if there was such
+func (fake *Server) unsubscribe(sub *subscriber, params subscription, raw json.RawMessage) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	// the backlog flag doesn't make a subscription different
+	params.SendBacklog = false
+	sub.subscriptions = slices.DeleteFunc(sub.subscriptions, func(s subscription) bool {
+		s.SendBacklog = false
+		return s == params
+	})
+	_ = sub.send("atlas_unsubscribed", raw)
+}
+
+// Disconnect drops all stream connections, e.g. to test reconnection
+func (fake *Server) Disconnect() {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	for sub := range fake.subscribers {
+		sub.conn.Close()
+	}
+}
+
+// Publish adds results to the fixtures, and sends them to stream
+// subscribers whose subscriptions match
+func (fake *Server) Publish(lines ...string) error {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	for _, line := range lines {
+		res, err := parseResult(line)
+		if err != nil {
+			return err
+		}
+		fake.results = append(fake.results, res)
+
+		for sub := range fake.subscribers {
+			for _, s := range sub.subscriptions {
+				if s.matches(res) {
+					_ = sub.send("atlas_result", json.RawMessage(res.line))
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// PublishProbeStatus sends probe connection events (JSON objects with
+// prb_id, event, timestamp etc.) to probestatus stream subscribers
+func (fake *Server) PublishProbeStatus(events ...string) error {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	for _, event := range events {
+		if !json.Valid([]byte(event)) {
+			return fmt.Errorf("invalid event: %s", event)
+		}
+		for sub := range fake.subscribers {
+			for _, s := range sub.subscriptions {
+				if s.StreamType == "probestatus" {
+					_ = sub.send("atlas_result", json.RawMessage(event))
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goattest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/robert-kisteleki/goat/result"
+)
+
+// Test if the stream sends the backlog and published results
+func TestStream(t *testing.T) {
+	fake := newDemo(t)
+	client := fake.Client()
+
+	filter := client.NewResultsFilter()
+	filter.FilterID(1001)
+	filter.Stream(true)
+	filter.SendBacklog(true)
+	filter.StreamTimeout(5 * time.Second)
+	filter.Limit(86)
+	results := make(chan result.AsyncResult)
+	go filter.GetResults(false, results)
+
+	n := 0
+	for res := range results {
+		if res.Error != nil {
+			t.Fatalf("streaming failed: %v", res.Error)
+		}
+		n++
+		if n == 84 {
+			// the backlog is done, now publish some new ones
+			for _, id := range []uint{1001, 5001, 1001} {
+				line := fmt.Sprintf(`{"fw":5080,"type":"ping","msm_id":%d,"prb_id":99,"timestamp":%d,"result":[]}`,
+					id, time.Now().Unix())
+				if err := fake.Publish(line); err != nil {
+					t.Fatalf("publishing failed: %v", err)
+				}
+			}
+		}
+		if n > 84 && (*res.Result).GetProbeID() != 99 {
+			t.Errorf("unexpected result: %v", *res.Result)
+		}
+	}
+	if n != 86 {
+		t.Errorf("expected 86 results, got %d", n)
+	}
+}
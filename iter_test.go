@@ -0,0 +1,124 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/robert-kisteleki/goat/goattest"
+)
+
+// Test the iterator variants of listings, results and streams
+func TestIterators(t *testing.T) {
+	fake := goattest.New()
+	defer fake.Close()
+	if err := fake.LoadDemo(); err != nil {
+		t.Fatalf("loading demo data failed: %v", err)
+	}
+	fake.PageSize = 5
+	client := fake.Client()
+	ctx := context.Background()
+
+	// all of them
+	probes := client.NewProbeFilter()
+	probes.Limit(100)
+	n := 0
+	for probe, err := range probes.Probes(ctx) {
+		if err != nil {
+			t.Fatalf("listing probes failed: %v", err)
+		}
+		if probe.ID == 0 {
+			t.Errorf("unexpected probe: %+v", probe)
+		}
+		n++
+	}
+	if n != 24 {
+		t.Errorf("expected 24 probes, got %d", n)
+	}
+
+	// breaking out early
+	n = 0
+	for _, err := range probes.Probes(ctx) {
+		if err != nil {
+			t.Fatalf("listing probes failed: %v", err)
+		}
+		n++
+		if n == 7 {
+			break
+		}
+	}
+
+	anchors := client.NewAnchorFilter()
+	anchors.Limit(100)
+	n = 0
+	for _, err := range anchors.Anchors(ctx) {
+		if err != nil {
+			t.Fatalf("listing anchors failed: %v", err)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("expected 3 anchors, got %d", n)
+	}
+
+	msms := client.NewMeasurementFilter()
+	msms.Limit(100)
+	n = 0
+	for msm, err := range msms.Measurements(ctx) {
+		if err != nil {
+			t.Fatalf("listing measurements failed: %v", err)
+		}
+		if msm.ID == 0 {
+			t.Errorf("unexpected measurement: %+v", msm)
+		}
+		n++
+	}
+	if n == 0 {
+		t.Error("no measurements were listed")
+	}
+
+	results := client.NewResultsFilter()
+	results.FilterID(1001)
+	n = 0
+	for res, err := range results.Results(ctx) {
+		if err != nil {
+			t.Fatalf("fetching results failed: %v", err)
+		}
+		if res.GetMeasurementID() != 1001 {
+			t.Errorf("unexpected result: %v", res)
+		}
+		n++
+	}
+	if n == 0 {
+		t.Error("no results were fetched")
+	}
+
+	// breaking out of a stream should close it
+	stream := client.NewResultsFilter()
+	stream.FilterID(1001)
+	stream.Stream(true)
+	stream.StreamTimeout(0)
+	done := make(chan bool)
+	go func() {
+		for res, err := range stream.Results(ctx) {
+			if err != nil || res.GetProbeID() != 1 {
+				t.Errorf("unexpected stream result: %v, %v", res, err)
+			}
+			break
+		}
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	_ = fake.Publish(`{"fw":5080,"type":"ping","msm_id":1001,"prb_id":1,"timestamp":1700000000,"result":[]}`)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("breaking out of the stream did not stop it")
+	}
+}
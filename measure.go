@@ -7,7 +7,7 @@
 package goat
 
 import (
-	"bytes"
+	"context"
 	"crypto/sha512"
 	"encoding/hex"
 	"encoding/json"
@@ -22,6 +22,7 @@ import (
 
 // Measurement specification object, to be passed to the API
 type MeasurementSpec struct {
+	client  *Client
 	apiSpec measurementSpec
 	verbose bool
 	key     *uuid.UUID
@@ -215,8 +216,15 @@ var DnsTypes = []string{"A", "AAAA", "ANY", "CNAME", "DNSKEY", "DS", "MX", "NS",
 var HttpMethods = []string{"GET", "HEAD", "POST"}
 var HttpVersions = []string{"1.0", "1.1"}
 
+// NewMeasurementSpec prepares a new measurement specification using the default client
 func NewMeasurementSpec() (spec *MeasurementSpec) {
+	return defaultClient.NewMeasurementSpec()
+}
+
+// NewMeasurementSpec prepares a new measurement specification using this client
+func (client *Client) NewMeasurementSpec() (spec *MeasurementSpec) {
 	spec = new(MeasurementSpec)
+	spec.client = client
 	spec.apiSpec.Definitons = make([]measurementTargetDefinition, 0)
 	spec.apiSpec.Probes = make([]measurementProbeDefinition, 0)
 	return spec
@@ -717,35 +725,23 @@ func (filter *MeasurementSpec) ApiKey(key *uuid.UUID) {
 	filter.key = key
 }
 
+// Schedule submits the measurement specification to the API
+// Returns the list of measurement IDs created
 func (spec *MeasurementSpec) Schedule() (msmlist []uint, err error) {
+	return spec.ScheduleContext(context.Background())
+}
+
+// ScheduleContext is like Schedule but the API call can be cancelled via the context
+func (spec *MeasurementSpec) ScheduleContext(ctx context.Context) (msmlist []uint, err error) {
 	post, err := spec.GetApiJson()
 	if err != nil {
 		return nil, err
 	}
 
-	query := apiBaseURL + "measurements/"
-	req, err := http.NewRequest("POST", query, bytes.NewBuffer(post))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", uaString)
-	if spec.key != nil {
-		req.Header.Set("Authorization", "Key "+spec.key.String())
-	}
-
-	if spec.verbose {
-		msg := fmt.Sprintf("# API call: POST %s with content '%s'", req.URL, string(post))
-		if spec.key != nil {
-			msg += fmt.Sprintf(" (using API key %s...)", spec.key.String()[:8])
-		}
-		fmt.Println(msg)
-	}
-
-	client := &http.Client{}
-	client.Timeout = time.Second * 15
-	resp, err := client.Do(req)
+	client := clientOrDefault(spec.client)
+	query := client.apiBaseURL + "measurements/"
+	key := client.apiKeyFor(spec.key, ApiKeyCreateMeasurements)
+	resp, err := client.apiRequest(ctx, spec.verbose, "POST", query, key, post)
 	if err != nil {
 		return nil, err
 	}
@@ -764,7 +760,8 @@ func (spec *MeasurementSpec) Schedule() (msmlist []uint, err error) {
 		return msmlist.Measurements, nil
 	}
 
-	return nil, fmt.Errorf("unknown error")
+	// some other kind of success is unexpected
+	return nil, &APIError{StatusCode: resp.StatusCode, Title: http.StatusText(resp.StatusCode), Detail: "unexpected response"}
 }
 
 func (spec *MeasurementSpec) GetApiJson() ([]byte, error) {
@@ -779,30 +776,17 @@ func (spec *MeasurementSpec) GetApiJson() ([]byte, error) {
 	return json.Marshal(spec.apiSpec)
 }
 
+// Stop stops a measurement
 func (spec *MeasurementSpec) Stop(msmID uint) error {
-	query := fmt.Sprintf("%smeasurements/%d/", apiBaseURL, msmID)
-	req, err := http.NewRequest("DELETE", query, nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", uaString)
-	if spec.key != nil {
-		req.Header.Set("Authorization", "Key "+spec.key.String())
-	}
-
-	if spec.verbose {
-		msg := fmt.Sprintf("# API call: DELETE %s ", req.URL)
-		if spec.key != nil {
-			msg += fmt.Sprintf(" (using API key %s...)", spec.key.String()[:8])
-		}
-		fmt.Println(msg)
-	}
+	return spec.StopContext(context.Background(), msmID)
+}
 
-	client := &http.Client{}
-	client.Timeout = time.Second * 15
-	resp, err := client.Do(req)
+// StopContext is like Stop but the API call can be cancelled via the context
+func (spec *MeasurementSpec) StopContext(ctx context.Context, msmID uint) error {
+	client := clientOrDefault(spec.client)
+	query := fmt.Sprintf("%smeasurements/%d/", client.apiBaseURL, msmID)
+	key := client.apiKeyFor(spec.key, ApiKeyStopMeasurements)
+	resp, err := client.apiRequest(ctx, spec.verbose, "DELETE", query, key, nil)
 	if err != nil {
 		return err
 	}
@@ -819,6 +803,12 @@ func (spec *MeasurementSpec) Stop(msmID uint) error {
 // the actual probe specification on what to add or remove comes
 // in the form of measurementProbeDefinition objects in the specification
 func (spec *MeasurementSpec) ParticipationRequest(msmID uint, add bool) ([]uint, error) {
+	return spec.ParticipationRequestContext(context.Background(), msmID, add)
+}
+
+// ParticipationRequestContext is like ParticipationRequest but the API call
+// can be cancelled via the context
+func (spec *MeasurementSpec) ParticipationRequestContext(ctx context.Context, msmID uint, add bool) ([]uint, error) {
 	type measurementParticipationRequest struct {
 		Action    string    `json:"action"` // "add" or "remove"
 		Requested uint      `json:"requested"`
@@ -856,34 +846,15 @@ func (spec *MeasurementSpec) ParticipationRequest(msmID uint, add bool) ([]uint,
 		plist = append(plist, mpr)
 	}
 
-	query := fmt.Sprintf("%smeasurements/%d/participation-requests/", apiBaseURL, msmID)
+	client := clientOrDefault(spec.client)
+	query := fmt.Sprintf("%smeasurements/%d/participation-requests/", client.apiBaseURL, msmID)
 	post, err := json.Marshal(plist)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", query, bytes.NewBuffer(post))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", uaString)
-	if spec.key != nil {
-		req.Header.Set("Authorization", "Key "+spec.key.String())
-	}
-
-	if spec.verbose {
-		msg := fmt.Sprintf("# API call: POST %s with content '%s'", req.URL, string(post))
-		if spec.key != nil {
-			msg += fmt.Sprintf(" (using API key %s...)", spec.key.String()[:8])
-		}
-		fmt.Println(msg)
-	}
-
-	client := &http.Client{}
-	client.Timeout = time.Second * 15
-	resp, err := client.Do(req)
+	key := client.apiKeyFor(spec.key, ApiKeyUpdateMeasurements)
+	resp, err := client.apiRequest(ctx, spec.verbose, "POST", query, key, post)
 	if err != nil {
 		return nil, err
 	}
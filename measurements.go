@@ -7,9 +7,10 @@
 package goat
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"iter"
 	"net/netip"
 	"net/url"
 	"regexp"
@@ -172,6 +173,7 @@ type measurementListingPage struct {
 
 // MeasurementFilter struct holds specified filters and other options
 type MeasurementFilter struct {
+	client  *Client
 	params  url.Values
 	id      uint
 	limit   uint
@@ -180,9 +182,15 @@ type MeasurementFilter struct {
 	my      bool
 }
 
-// NewMeasurementFilter prepares a new measurement filter object
+// NewMeasurementFilter prepares a new measurement filter object using the default client
 func NewMeasurementFilter() MeasurementFilter {
+	return defaultClient.NewMeasurementFilter()
+}
+
+// NewMeasurementFilter prepares a new measurement filter object using this client
+func (client *Client) NewMeasurementFilter() MeasurementFilter {
 	filter := MeasurementFilter{}
+	filter.client = client
 	filter.params = url.Values{}
 	filter.params.Add("optional_fields", "probes")
 	filter.params.Add("format[datetime]", "iso-8601")
@@ -446,17 +454,30 @@ func (filter *MeasurementFilter) verifyFilters() error {
 	}
 
 	// 'my' needs and API key
-	if filter.my && filter.key == nil {
+	if filter.my && filter.apiKey() == nil {
 		return fmt.Errorf("'my' needs an API key")
 	}
 
 	return nil
 }
 
+// the API key to use: either the one set for this filter or the one from the client
+func (filter *MeasurementFilter) apiKey() *uuid.UUID {
+	return clientOrDefault(filter.client).apiKeyFor(filter.key, ApiKeyListMeasurements)
+}
+
 // GetMeasurementCount returns the count of measurements by filtering
 func (filter *MeasurementFilter) GetMeasurementCount() (
 	count uint,
 	err error,
+) {
+	return filter.GetMeasurementCountContext(context.Background())
+}
+
+// GetMeasurementCountContext is like GetMeasurementCount but the API call can be cancelled via the context
+func (filter *MeasurementFilter) GetMeasurementCountContext(ctx context.Context) (
+	count uint,
+	err error,
 ) {
 	// sanity checks - late in the process, but not too late
 	err = filter.verifyFilters()
@@ -465,37 +486,23 @@ func (filter *MeasurementFilter) GetMeasurementCount() (
 	}
 
 	// counting needs application of the specified filters
-	query := apiBaseURL + "measurements/"
+	client := clientOrDefault(filter.client)
+	query := client.apiBaseURL + "measurements/"
 	if filter.my {
 		query += "my/"
 	}
 	filter.params.Add("page_size", "0")
 	query += "?" + filter.params.Encode()
 
-	req, err := http.NewRequest("GET", query, nil)
+	resp, err := client.apiGetRequest(ctx, filter.verbose, query, filter.apiKey())
 	if err != nil {
 		return 0, err
 	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", uaString)
-	if filter.key != nil {
-		req.Header.Set("Authorization", "Key "+filter.key.String())
-	}
+	defer resp.Body.Close()
 
-	// results are paginated with next= (and previous=)
-	if filter.verbose {
-		msg := fmt.Sprintf("# API call: GET %s", req.URL)
-		if filter.key != nil {
-			msg += fmt.Sprintf(" (using API key %s...)", filter.key.String()[:8])
-		}
-		fmt.Println(msg)
-	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, err
+	if resp.StatusCode != 200 {
+		return 0, parseAPIError(resp)
 	}
-	defer resp.Body.Close()
 
 	// grab and store the actual content
 	var page measurementListingPage
@@ -512,46 +519,58 @@ func (filter *MeasurementFilter) GetMeasurementCount() (
 // Results (or an error) appear on a channel
 func (filter *MeasurementFilter) GetMeasurements(
 	measurements chan AsyncMeasurementResult,
+) {
+	filter.GetMeasurementsContext(context.Background(), measurements)
+}
+
+// GetMeasurementsContext is like GetMeasurements, but it stops and closes the channel
+// when the context is cancelled
+func (filter *MeasurementFilter) GetMeasurementsContext(
+	ctx context.Context,
+	measurements chan AsyncMeasurementResult,
 ) {
 	defer close(measurements)
 
+	client := clientOrDefault(filter.client)
+
 	// special case: a specific ID was "filtered"
 	if filter.id != 0 {
-		msm, err := GetMeasurement(filter.verbose, filter.id, filter.key)
+		msm, err := client.GetMeasurementContext(ctx, filter.verbose, filter.id, filter.apiKey())
 		if err != nil {
-			measurements <- AsyncMeasurementResult{Measurement{}, err}
+			sendContext(ctx, measurements, AsyncMeasurementResult{Measurement{}, err})
 			return
 		}
-		measurements <- AsyncMeasurementResult{*msm, nil}
+		sendContext(ctx, measurements, AsyncMeasurementResult{*msm, nil})
 		return
 	}
 
 	// sanity checks - late in the process, but not too late
 	err := filter.verifyFilters()
 	if err != nil {
-		measurements <- AsyncMeasurementResult{Measurement{}, err}
+		sendContext(ctx, measurements, AsyncMeasurementResult{Measurement{}, err})
 		return
 	}
 
-	query := apiBaseURL + "measurements/"
+	key := filter.apiKey()
+	query := client.apiBaseURL + "measurements/"
 	if filter.my {
 		query += "my/"
 	}
 	query += "?" + filter.params.Encode()
 
-	resp, err := apiGetRequest(filter.verbose, query, filter.key)
+	resp, err := client.apiGetRequest(ctx, filter.verbose, query, key)
 
 	var total uint = 0
 	// results are paginated with next= (and previous=)
 	for {
 		if err != nil {
-			measurements <- AsyncMeasurementResult{Measurement{}, err}
+			sendContext(ctx, measurements, AsyncMeasurementResult{Measurement{}, err})
 			return
 		}
 		defer resp.Body.Close()
 
 		if resp.StatusCode != 200 {
-			measurements <- AsyncMeasurementResult{Measurement{}, parseAPIError(resp)}
+			sendContext(ctx, measurements, AsyncMeasurementResult{Measurement{}, parseAPIError(resp)})
 			return
 		}
 
@@ -559,13 +578,15 @@ func (filter *MeasurementFilter) GetMeasurements(
 		var page measurementListingPage
 		err = json.NewDecoder(resp.Body).Decode(&page)
 		if err != nil {
-			measurements <- AsyncMeasurementResult{Measurement{}, err}
+			sendContext(ctx, measurements, AsyncMeasurementResult{Measurement{}, err})
 			return
 		}
 
 		// return items while observing the limit
 		for _, msm := range page.Measurements {
-			measurements <- AsyncMeasurementResult{msm, nil}
+			if !sendContext(ctx, measurements, AsyncMeasurementResult{msm, nil}) {
+				return
+			}
 			total++
 			if total >= filter.limit {
 				return
@@ -578,11 +599,19 @@ func (filter *MeasurementFilter) GetMeasurements(
 		}
 
 		// just follow the next link
-		resp, err = apiGetRequest(filter.verbose, page.Next, filter.key)
+		resp, err = client.apiGetRequest(ctx, filter.verbose, page.Next, key)
 	}
 }
 
-// GetMeasurement retrieves data for a single measurement, by ID
+// Measurements is an iterator over the measurements matching the filter.
+// Breaking out of the loop stops fetching more pages.
+func (filter *MeasurementFilter) Measurements(ctx context.Context) iter.Seq2[Measurement, error] {
+	return iterate(ctx, filter.GetMeasurementsContext, func(item AsyncMeasurementResult) (Measurement, error) {
+		return item.Measurement, item.Error
+	})
+}
+
+// GetMeasurement retrieves data for a single measurement, by ID, using the default client
 // returns measurement, nil if a measurement was found
 // returns nil, nil if no such measurement was found
 // returns nil, err on error
@@ -593,12 +622,50 @@ func GetMeasurement(
 ) (
 	*Measurement,
 	error,
+) {
+	return defaultClient.GetMeasurement(verbose, id, key)
+}
+
+// GetMeasurementContext is like GetMeasurement but the API call can be cancelled via the context
+func GetMeasurementContext(
+	ctx context.Context,
+	verbose bool,
+	id uint,
+	key *uuid.UUID,
+) (
+	*Measurement,
+	error,
+) {
+	return defaultClient.GetMeasurementContext(ctx, verbose, id, key)
+}
+
+// GetMeasurement retrieves data for a single measurement, by ID, using this client
+func (client *Client) GetMeasurement(
+	verbose bool,
+	id uint,
+	key *uuid.UUID,
+) (
+	*Measurement,
+	error,
+) {
+	return client.GetMeasurementContext(context.Background(), verbose, id, key)
+}
+
+// GetMeasurementContext is like GetMeasurement but the API call can be cancelled via the context
+func (client *Client) GetMeasurementContext(
+	ctx context.Context,
+	verbose bool,
+	id uint,
+	key *uuid.UUID,
+) (
+	*Measurement,
+	error,
 ) {
 	var measurement *Measurement
 
-	query := fmt.Sprintf("%smeasurements/%d/", apiBaseURL, id)
+	query := fmt.Sprintf("%smeasurements/%d/", client.apiBaseURL, id)
 
-	resp, err := apiGetRequest(verbose, query, key)
+	resp, err := client.apiGetRequest(ctx, verbose, query, client.apiKeyFor(key, ApiKeyListMeasurements))
 	if err != nil {
 		return nil, err
 	}
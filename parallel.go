@@ -0,0 +1,299 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/robert-kisteleki/goat/result"
+)
+
+// limits for the size of the time slices in a parallel download
+const (
+	minParallelSlice = time.Hour
+	maxParallelSlice = 24 * time.Hour
+)
+
+// one time slice of a parallel download
+type resultSlice struct {
+	from  time.Time // inclusive
+	to    time.Time // inclusive
+	items []sliceItem
+	err   error
+	ready chan struct{}
+}
+
+// a result in a slice, parsed or not
+type sliceItem struct {
+	line      string
+	res       result.Result
+	err       error
+	skip      bool  // didn't match the filters
+	timestamp int64 // for ordering, also for lines that were not parsed
+}
+
+// Parallel turns on sharded downloading: the time window is split into
+// slices which are fetched by this many concurrent workers, failed slices
+// are retried on their own, and results are delivered in timestamp order.
+// 0 or 1 means a normal download.
+func (filter *ResultsFilter) Parallel(workers uint) {
+	filter.parallel = workers
+}
+
+// parallelDownload fetches results in time slices, concurrently
+func (filter *ResultsFilter) parallelDownload(
+	ctx context.Context,
+	verbose bool,
+	results chan result.AsyncResult,
+) error {
+	if err := filter.verifyFilters(); err != nil {
+		return err
+	}
+
+	from, to, err := filter.downloadWindow(ctx, verbose)
+	if err != nil {
+		return err
+	}
+
+	defer close(results)
+
+	workers := int(filter.parallel)
+	length := min(max(to.Sub(from)/time.Duration(workers*4), minParallelSlice), maxParallelSlice)
+	shards := make([]*resultSlice, 0)
+	for start := from; !start.After(to); start = start.Add(length) {
+		end := start.Add(length - time.Second)
+		if end.After(to) {
+			end = to
+		}
+		shards = append(shards, &resultSlice{from: start, to: end, ready: make(chan struct{})})
+	}
+	if verbose {
+		fmt.Printf("# Downloading results between %v and %v in %d slices with %d workers\n",
+			from.UTC(), to.UTC(), len(shards), workers)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// workers fetch slices as long as not too many are waiting to be delivered
+	jobs := make(chan *resultSlice)
+	window := make(chan struct{}, 2*workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for shard := range jobs {
+				shard.err = filter.fetchSlice(ctx, verbose, shard)
+				close(shard.ready)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, shard := range shards {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- shard:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// deliver slices in order
+	for _, shard := range shards {
+		select {
+		case <-shard.ready:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		if shard.err != nil {
+			err := fmt.Errorf("results between %v and %v could not be fetched: %w",
+				shard.from.UTC(), shard.to.UTC(), shard.err)
+			sendContext(ctx, results, result.AsyncResult{Result: nil, Error: err})
+		}
+		for _, item := range shard.items {
+			if item.skip {
+				if filter.saveAll {
+					filter.saveResult(ctx, item.line, verbose, results)
+				}
+				continue
+			}
+			filter.deliverResult(ctx, item.line, item.res, item.err, verbose, results)
+			if ctx.Err() != nil || filter.limitReached() {
+				break
+			}
+		}
+		shard.items = nil
+		<-window
+
+		if filter.limitReached() {
+			break
+		}
+	}
+
+	cancel()
+	wg.Wait()
+	return nil
+}
+
+// downloadWindow determines the time window to download; if the filter
+// doesn't say then it's the lifetime of the measurement (so far)
+func (filter *ResultsFilter) downloadWindow(
+	ctx context.Context,
+	verbose bool,
+) (time.Time, time.Time, error) {
+	if filter.start != nil && filter.stop != nil {
+		return *filter.start, *filter.stop, nil
+	}
+
+	client := clientOrDefault(filter.client)
+	msm, err := client.GetMeasurementContext(ctx, verbose, filter.id, nil)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	from := time.Time(msm.StartTime)
+	if filter.start != nil {
+		from = *filter.start
+	}
+	to := time.Now()
+	if filter.stop != nil {
+		to = *filter.stop
+	} else if msm.StopTime != nil && time.Time(*msm.StopTime).Before(to) {
+		to = time.Time(*msm.StopTime)
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("empty time window to download: %v - %v", from.UTC(), to.UTC())
+	}
+	return from, to, nil
+}
+
+// fetchSlice downloads and parses one slice. The request itself is retried
+// by the client's retry policy; this only retries if the response could not
+// be read completely.
+func (filter *ResultsFilter) fetchSlice(
+	ctx context.Context,
+	verbose bool,
+	shard *resultSlice,
+) error {
+	client := clientOrDefault(filter.client)
+
+	params := url.Values{}
+	for key, values := range filter.params {
+		params[key] = values
+	}
+	params.Set("start", fmt.Sprintf("%d", shard.from.Unix()))
+	params.Set("stop", fmt.Sprintf("%d", shard.to.Unix()))
+	query := fmt.Sprintf("%smeasurements/%d/results/?%s", client.apiBaseURL, filter.id, params.Encode())
+
+	attempts := max(client.retry.MaxAttempts, 1)
+	var err error
+	for attempt := 1; ; attempt++ {
+		shard.items, err = filter.fetchSliceOnce(ctx, verbose, query)
+		var readErr *sliceReadError
+		if err == nil || ctx.Err() != nil || attempt >= attempts || !errors.As(err, &readErr) {
+			return err
+		}
+		delay := client.retry.backoff(attempt)
+		if verbose {
+			fmt.Printf("# Fetching results between %v and %v failed (%v), retrying in %v (attempt %d of %d)\n",
+				shard.from.UTC(), shard.to.UTC(), err, delay, attempt, attempts)
+		}
+		if sleepContext(ctx, delay) != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// sliceReadError signals that the response body of a slice could not be read
+type sliceReadError struct {
+	err error
+}
+
+func (e *sliceReadError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sliceReadError) Unwrap() error {
+	return e.err
+}
+
+func (filter *ResultsFilter) fetchSliceOnce(
+	ctx context.Context,
+	verbose bool,
+	query string,
+) ([]sliceItem, error) {
+	client := clientOrDefault(filter.client)
+	resp, err := client.apiGetRequest(ctx, verbose, query, client.apiKeyFor(nil, ApiKeyListMeasurements))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, parseAPIError(resp)
+	}
+
+	items := make([]sliceItem, 0)
+	typehint := ""
+	last := int64(0)
+	read := bufio.NewScanner(bufio.NewReader(resp.Body))
+	read.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for read.Scan() {
+		line := read.Text()
+		item := sliceItem{line: line}
+		match, hint := filter.prefilter(line, typehint)
+		if !match {
+			item.skip = true
+		} else {
+			item.res, item.err = result.ParseWithTypeHint(line, hint)
+			if item.err == nil && typehint == "" && !filter.dump {
+				typehint = item.res.TypeName()
+			}
+		}
+		item.timestamp = itemTimestamp(item, last)
+		last = item.timestamp
+		items = append(items, item)
+	}
+	if err := read.Err(); err != nil {
+		return nil, &sliceReadError{err}
+	}
+
+	slices.SortStableFunc(items, func(a, b sliceItem) int {
+		return cmp.Compare(a.timestamp, b.timestamp)
+	})
+	return items, nil
+}
+
+// itemTimestamp returns the timestamp of the result on a line; lines that
+// could not be parsed stay next to the line before them
+func itemTimestamp(item sliceItem, last int64) int64 {
+	if item.res != nil {
+		return item.res.GetTimeStamp().Unix()
+	}
+	if fields, ok := scanDumpFields(item.line); ok && fields.timestamp != 0 {
+		return fields.timestamp
+	}
+	return last
+}
@@ -0,0 +1,105 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robert-kisteleki/goat"
+	"github.com/robert-kisteleki/goat/goattest"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+// Test if a parallel download delivers results in order and retries slices
+func TestParallelDownload(t *testing.T) {
+	fake := goattest.New()
+	defer fake.Close()
+
+	// a result every 30 minutes for 12 hours, added in random order
+	const base = 1700000000
+	lines := make([]string, 0)
+	for i := range 24 {
+		lines = append(lines, fmt.Sprintf(`{"fw":5080,"type":"ping","msm_id":1001,"prb_id":%d,"timestamp":%d,"result":[]}`,
+			i%5+1, base+i*1800))
+	}
+	rand.Shuffle(len(lines), func(i, j int) { lines[i], lines[j] = lines[j], lines[i] })
+	if err := fake.AddResults(lines...); err != nil {
+		t.Fatalf("adding results failed: %v", err)
+	}
+
+	// the slice starting at base+2h fails once, the one at base+5h always
+	var mu sync.Mutex
+	failures := map[string]int{}
+	target, _ := url.Parse(fake.URL())
+	forward := httputil.NewSingleHostReverseProxy(target)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := r.URL.Query().Get("start")
+		mu.Lock()
+		failures[start]++
+		count := failures[start]
+		mu.Unlock()
+		if (start == fmt.Sprint(base+2*3600) && count == 1) || start == fmt.Sprint(base+5*3600) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		forward.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	client := goat.NewClient()
+	client.SetAPIBase(proxy.URL + "/api/v2/")
+	client.SetRetryPolicy(goat.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+
+	filter := client.NewResultsFilter()
+	filter.FilterID(1001)
+	filter.FilterStart(time.Unix(base, 0))
+	filter.FilterStop(time.Unix(base+12*3600-1, 0))
+	filter.Parallel(3)
+	results := make(chan result.AsyncResult)
+	go filter.GetResults(false, results)
+
+	var last time.Time
+	count, errs := 0, 0
+	for res := range results {
+		if res.Error != nil {
+			errs++
+			continue
+		}
+		ts := (*res.Result).GetTimeStamp()
+		if ts.Before(last) {
+			t.Errorf("result at %v came after %v", ts, last)
+		}
+		last = ts
+		count++
+	}
+
+	// the always failing slice is 1h long, i.e. 2 results are missing
+	if count != 22 || errs != 1 {
+		t.Errorf("expected 22 results and 1 error, got %d and %d", count, errs)
+	}
+	if failures[fmt.Sprint(base+2*3600)] != 2 || failures[fmt.Sprint(base+5*3600)] != 2 {
+		t.Errorf("failing slices were not retried properly: %v", failures)
+	}
+
+	filter = client.NewResultsFilter()
+	filter.FilterID(1001)
+	filter.FilterLatest()
+	filter.Parallel(3)
+	results = make(chan result.AsyncResult)
+	go filter.GetResults(false, results)
+	if res := <-results; res.Error == nil {
+		t.Error("parallel download of latest results was accepted")
+	}
+}
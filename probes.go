@@ -7,8 +7,10 @@
 package goat
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"net/netip"
 	"net/url"
 	"regexp"
@@ -129,15 +131,22 @@ type probeListingPage struct {
 
 // ProbeFilter struct holds specified filters and other options
 type ProbeFilter struct {
+	client  *Client
 	params  url.Values
 	id      uint
 	limit   uint
 	verbose bool
 }
 
-// NewProbeFilter prepares a new probe filter object
+// NewProbeFilter prepares a new probe filter object using the default client
 func NewProbeFilter() *ProbeFilter {
+	return defaultClient.NewProbeFilter()
+}
+
+// NewProbeFilter prepares a new probe filter object using this client
+func (client *Client) NewProbeFilter() *ProbeFilter {
 	filter := ProbeFilter{}
+	filter.client = client
 	filter.params = url.Values{}
 	filter.params.Add("format[datetime]", "iso-8601")
 	return &filter
@@ -334,6 +343,14 @@ func (filter *ProbeFilter) verifyFilters() error {
 func (filter *ProbeFilter) GetProbeCount() (
 	count uint,
 	err error,
+) {
+	return filter.GetProbeCountContext(context.Background())
+}
+
+// GetProbeCountContext is like GetProbeCount but the API call can be cancelled via the context
+func (filter *ProbeFilter) GetProbeCountContext(ctx context.Context) (
+	count uint,
+	err error,
 ) {
 	// sanity checks - late in the process, but not too late
 	err = filter.verifyFilters()
@@ -343,14 +360,19 @@ func (filter *ProbeFilter) GetProbeCount() (
 
 	// counting needs application of the specified filters
 	filter.params.Add("page_size", "0")
-	query := apiBaseURL + "probes/?" + filter.params.Encode()
+	client := clientOrDefault(filter.client)
+	query := client.apiBaseURL + "probes/?" + filter.params.Encode()
 
-	resp, err := apiGetRequest(filter.verbose, query, nil)
+	resp, err := client.apiGetRequest(ctx, filter.verbose, query, client.apiKeyFor(nil, ApiKeyDefault))
 	if err != nil {
 		return 0, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != 200 {
+		return 0, parseAPIError(resp)
+	}
+
 	// grab and store the actual content
 	var page probeListingPage
 	err = json.NewDecoder(resp.Body).Decode(&page)
@@ -366,41 +388,54 @@ func (filter *ProbeFilter) GetProbeCount() (
 // Results (or an error) appear on a channel
 func (filter *ProbeFilter) GetProbes(
 	probes chan AsyncProbeResult,
+) {
+	filter.GetProbesContext(context.Background(), probes)
+}
+
+// GetProbesContext is like GetProbes, but it stops and closes the channel
+// when the context is cancelled
+func (filter *ProbeFilter) GetProbesContext(
+	ctx context.Context,
+	probes chan AsyncProbeResult,
 ) {
 	defer close(probes)
 
+	client := clientOrDefault(filter.client)
+
 	// special case: a specific ID was "filtered"
 	if filter.id != 0 {
-		probe, err := GetProbe(filter.verbose, filter.id)
+		probe, err := client.GetProbeContext(ctx, filter.verbose, filter.id)
 		if err != nil {
-			probes <- AsyncProbeResult{Probe{}, err}
+			sendContext(ctx, probes, AsyncProbeResult{Probe{}, err})
+			return
 		}
-		probes <- AsyncProbeResult{*probe, nil}
+		sendContext(ctx, probes, AsyncProbeResult{*probe, nil})
 		return
 	}
 
 	// sanity checks - late in the process, but not too late
 	err := filter.verifyFilters()
 	if err != nil {
-		probes <- AsyncProbeResult{Probe{}, err}
+		sendContext(ctx, probes, AsyncProbeResult{Probe{}, err})
 		return
 	}
 
-	query := apiBaseURL + "probes/?" + filter.params.Encode()
+	key := client.apiKeyFor(nil, ApiKeyDefault)
+	query := client.apiBaseURL + "probes/?" + filter.params.Encode()
 
-	resp, err := apiGetRequest(filter.verbose, query, nil)
+	resp, err := client.apiGetRequest(ctx, filter.verbose, query, key)
 
 	// results are paginated with next= (and previous=)
 	var total uint = 0
 	for {
 		if err != nil {
-			probes <- AsyncProbeResult{Probe{}, err}
+			sendContext(ctx, probes, AsyncProbeResult{Probe{}, err})
 			return
 		}
 		defer resp.Body.Close()
 
 		if resp.StatusCode != 200 {
-			probes <- AsyncProbeResult{Probe{}, parseAPIError(resp)}
+			sendContext(ctx, probes, AsyncProbeResult{Probe{}, parseAPIError(resp)})
 			return
 		}
 
@@ -408,12 +443,15 @@ func (filter *ProbeFilter) GetProbes(
 		var page probeListingPage
 		err = json.NewDecoder(resp.Body).Decode(&page)
 		if err != nil {
-			probes <- AsyncProbeResult{Probe{}, err}
+			sendContext(ctx, probes, AsyncProbeResult{Probe{}, err})
+			return
 		}
 
 		// return items while observing the limit
 		for _, probe := range page.Probes {
-			probes <- AsyncProbeResult{probe, nil}
+			if !sendContext(ctx, probes, AsyncProbeResult{probe, nil}) {
+				return
+			}
 			total++
 			if total >= filter.limit {
 				return
@@ -426,11 +464,19 @@ func (filter *ProbeFilter) GetProbes(
 		}
 
 		// just follow the next link
-		resp, err = apiGetRequest(filter.verbose, page.Next, nil)
+		resp, err = client.apiGetRequest(ctx, filter.verbose, page.Next, key)
 	}
 }
 
-// GetProbe retrieves data for a single probe, by ID
+// Probes is an iterator over the probes matching the filter. Breaking
+// out of the loop stops fetching more pages.
+func (filter *ProbeFilter) Probes(ctx context.Context) iter.Seq2[Probe, error] {
+	return iterate(ctx, filter.GetProbesContext, func(item AsyncProbeResult) (Probe, error) {
+		return item.Probe, item.Error
+	})
+}
+
+// GetProbe retrieves data for a single probe, by ID, using the default client
 // returns probe, nil if a probe was found
 // returns nil, nil if no such probe was found
 // returns nil, err on error
@@ -440,12 +486,47 @@ func GetProbe(
 ) (
 	*Probe,
 	error,
+) {
+	return defaultClient.GetProbe(verbose, id)
+}
+
+// GetProbeContext is like GetProbe but the API call can be cancelled via the context
+func GetProbeContext(
+	ctx context.Context,
+	verbose bool,
+	id uint,
+) (
+	*Probe,
+	error,
+) {
+	return defaultClient.GetProbeContext(ctx, verbose, id)
+}
+
+// GetProbe retrieves data for a single probe, by ID, using this client
+func (client *Client) GetProbe(
+	verbose bool,
+	id uint,
+) (
+	*Probe,
+	error,
+) {
+	return client.GetProbeContext(context.Background(), verbose, id)
+}
+
+// GetProbeContext is like GetProbe but the API call can be cancelled via the context
+func (client *Client) GetProbeContext(
+	ctx context.Context,
+	verbose bool,
+	id uint,
+) (
+	*Probe,
+	error,
 ) {
 	var probe *Probe
 
-	query := fmt.Sprintf("%sprobes/%d/", apiBaseURL, id)
+	query := fmt.Sprintf("%sprobes/%d/", client.apiBaseURL, id)
 
-	resp, err := apiGetRequest(verbose, query, nil)
+	resp, err := client.apiGetRequest(ctx, verbose, query, client.apiKeyFor(nil, ApiKeyDefault))
 	if err != nil {
 		return nil, err
 	}
@@ -7,7 +7,12 @@
 package goat
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 // Test if the filter validator does a decent job
@@ -52,3 +57,43 @@ func TestProbeFilterValidator(t *testing.T) {
 		t.Fatalf("Sort order is not filtered properly")
 	}
 }
+
+// Test if cancelling the context stops an (endless) paginated download
+func TestGetProbesContextCancel(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// every page has a next page
+		fmt.Fprintf(w, `{"count":1000000,"next":"%s/probes/","previous":"","results":[{"id":1},{"id":2}]}`, server.URL)
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.SetAPIBase(server.URL + "/")
+	filter := client.NewProbeFilter()
+	filter.Limit(1000000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	probes := make(chan AsyncProbeResult)
+	go filter.GetProbesContext(ctx, probes)
+
+	for i := 0; i < 5; i++ {
+		probe := <-probes
+		if probe.Error != nil {
+			t.Fatalf("unexpected error: %v", probe.Error)
+		}
+	}
+	cancel()
+
+	// the channel should be closed promptly; at most one in-flight item may still arrive
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-probes:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("channel was not closed after the context was cancelled")
+		}
+	}
+}
@@ -0,0 +1,251 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+// ProbeStatusFilter holds the filters for the probe (dis)connection
+// event stream. Filtering is done by goat: the country is resolved to a
+// set of probes when the stream starts, the ASN is checked in the events.
+type ProbeStatusFilter struct {
+	client  *Client
+	probes  []uint
+	country string
+	asn     uint
+	timeout time.Duration
+	limit   uint
+	fetched uint
+	verbose bool
+}
+
+// NewProbeStatusFilter prepares a new probe status filter using the default client
+func NewProbeStatusFilter() ProbeStatusFilter {
+	return defaultClient.NewProbeStatusFilter()
+}
+
+// NewProbeStatusFilter prepares a new probe status filter using this client
+func (client *Client) NewProbeStatusFilter() ProbeStatusFilter {
+	filter := ProbeStatusFilter{}
+	filter.client = client
+	filter.probes = make([]uint, 0)
+	filter.timeout = time.Second * 60
+	return filter
+}
+
+// FilterProbeIDs filters for events of these probes
+func (filter *ProbeStatusFilter) FilterProbeIDs(list []uint) {
+	filter.probes = list
+}
+
+// FilterCountry filters for events of probes in this country
+func (filter *ProbeStatusFilter) FilterCountry(cc string) {
+	filter.country = strings.ToUpper(cc)
+}
+
+// FilterASN filters for events where the probe is (or was) connected from this ASN
+func (filter *ProbeStatusFilter) FilterASN(asn uint) {
+	filter.asn = asn
+}
+
+// StreamTimeout sets the timeout for the websocket stream
+func (filter *ProbeStatusFilter) StreamTimeout(timeout time.Duration) {
+	filter.timeout = timeout
+}
+
+// Verbose turns on verbose mode, same as the verbose parameter of GetProbeStatus
+func (filter *ProbeStatusFilter) Verbose(verbose bool) {
+	filter.verbose = verbose
+}
+
+// Limit limits the number of events retrieved
+func (filter *ProbeStatusFilter) Limit(max uint) {
+	filter.limit = max
+}
+
+// GetProbeStatus streams probe connection events, as ConnectionResults,
+// via a channel
+func (filter *ProbeStatusFilter) GetProbeStatus(
+	verbose bool,
+	results chan result.AsyncResult,
+) {
+	filter.GetProbeStatusContext(context.Background(), verbose, results)
+}
+
+// GetProbeStatusContext is like GetProbeStatus, but it stops streaming and
+// closes the channel when the context is cancelled
+func (filter *ProbeStatusFilter) GetProbeStatusContext(
+	ctx context.Context,
+	verbose bool,
+	results chan result.AsyncResult,
+) {
+	verbose = verbose || filter.verbose
+	defer close(results)
+
+	client := clientOrDefault(filter.client)
+
+	// which probes are we interested in?
+	probes, err := filter.resolveProbes(ctx, verbose)
+	if err != nil {
+		sendContext(ctx, results, result.AsyncResult{Result: nil, Error: err})
+		return
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, client.streamBaseURL+"?client=goat_"+version, nil)
+	if err != nil {
+		sendContext(ctx, results, result.AsyncResult{Result: nil, Error: err})
+		return
+	}
+	defer conn.Close()
+
+	// closing the connection unblocks the reader if the context is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	subscription := []any{"atlas_subscribe", map[string]any{"streamType": "probestatus"}}
+	if err := conn.WriteJSON(subscription); err != nil {
+		sendContext(ctx, results, result.AsyncResult{Result: nil, Error: err})
+		return
+	}
+	if verbose {
+		fmt.Printf("# Stream parameters: %+v\n", subscription[1])
+	}
+
+	for {
+		if filter.timeout != 0 {
+			err = conn.SetReadDeadline(time.Now().Add(filter.timeout))
+			if err != nil && verbose {
+				fmt.Printf("# WARNING: error setting read deadline: %v\n", err)
+			}
+		}
+
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() == nil {
+				sendContext(ctx, results, result.AsyncResult{Result: nil, Error: &streamDisconnectError{err}})
+			}
+			return
+		}
+
+		var parts []json.RawMessage
+		var msgType string
+		if json.Unmarshal(msg, &parts) != nil || len(parts) != 2 || json.Unmarshal(parts[0], &msgType) != nil {
+			err := fmt.Errorf("unknown stream message received: %v", string(msg))
+			sendContext(ctx, results, result.AsyncResult{Result: nil, Error: err})
+			return
+		}
+
+		switch msgType {
+		case "atlas_result", "atlas_probestatus":
+			// cool, an event
+		case "atlas_subscribed":
+			continue
+		case "atlas_error":
+			fmt.Printf("# WARNING: error message received from stream: %v\n", string(msg))
+			continue
+		default:
+			err := fmt.Errorf("unknown stream message received: %v", string(msg))
+			sendContext(ctx, results, result.AsyncResult{Result: nil, Error: err})
+			return
+		}
+
+		res, err := parseConnectionEvent(parts[1])
+		if err != nil {
+			if !sendContext(ctx, results, result.AsyncResult{Result: nil, Error: err}) {
+				return
+			}
+			continue
+		}
+
+		event := res.(*result.ConnectionResult)
+		if (probes != nil && !slices.Contains(probes, event.ProbeID)) ||
+			(filter.asn != 0 && filter.asn != event.Asn) {
+			continue
+		}
+
+		if !sendContext(ctx, results, result.AsyncResult{Result: &res, Error: nil}) {
+			return
+		}
+		filter.fetched++
+		if filter.limit > 0 && filter.fetched >= filter.limit {
+			return
+		}
+	}
+}
+
+// ProbeStatus is an iterator over probe connection events. Breaking out
+// of the loop stops the stream.
+func (filter *ProbeStatusFilter) ProbeStatus(ctx context.Context) iter.Seq2[result.Result, error] {
+	list := func(ctx context.Context, results chan result.AsyncResult) {
+		filter.GetProbeStatusContext(ctx, filter.verbose, results)
+	}
+	return iterate(ctx, list, splitResult)
+}
+
+// resolveProbes determines the set of probes to watch; nil means all
+func (filter *ProbeStatusFilter) resolveProbes(ctx context.Context, verbose bool) ([]uint, error) {
+	if filter.country == "" {
+		if len(filter.probes) == 0 {
+			return nil, nil
+		}
+		return filter.probes, nil
+	}
+
+	probeFilter := clientOrDefault(filter.client).NewProbeFilter()
+	probeFilter.Verbose(verbose)
+	probeFilter.FilterCountry(filter.country)
+	probeFilter.Limit(math.MaxUint32)
+	list := make(chan AsyncProbeResult)
+	go probeFilter.GetProbesContext(ctx, list)
+
+	probes := make([]uint, 0)
+	for probe := range list {
+		if probe.Error != nil {
+			for range list {
+			}
+			return nil, fmt.Errorf("could not look up probes in %s: %w", filter.country, probe.Error)
+		}
+		// if there's a probe list too, both have to match
+		if len(filter.probes) == 0 || slices.Contains(filter.probes, probe.Probe.ID) {
+			probes = append(probes, probe.Probe.ID)
+		}
+	}
+
+	if verbose {
+		fmt.Printf("# Watching %d probes in %s\n", len(probes), filter.country)
+	}
+	return probes, nil
+}
+
+// parseConnectionEvent makes a ConnectionResult out of an event; these
+// don't necessarily have a type, so that's filled in if needed
+func parseConnectionEvent(data json.RawMessage) (result.Result, error) {
+	var event map[string]any
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+	if _, ok := event["type"]; !ok {
+		event["type"] = "connection"
+		fixed, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		data = fixed
+	}
+	return result.ParseWithTypeHint(string(data), "connection")
+}
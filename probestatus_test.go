@@ -0,0 +1,65 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/robert-kisteleki/goat/goattest"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+// Test if probe status events are filtered and delivered as connection results
+func TestProbeStatus(t *testing.T) {
+	fake := goattest.New()
+	defer fake.Close()
+	if err := fake.LoadDemo(); err != nil {
+		t.Fatalf("loading demo data failed: %v", err)
+	}
+
+	filter := fake.Client().NewProbeStatusFilter()
+	filter.FilterCountry("NL")
+	filter.FilterProbeIDs([]uint{1, 2, 7, 13})
+	filter.FilterASN(3333)
+	filter.StreamTimeout(5 * time.Second)
+	filter.Limit(2)
+	results := make(chan result.AsyncResult)
+	go filter.GetProbeStatus(false, results)
+
+	time.Sleep(200 * time.Millisecond)
+	event := func(probe, asn uint, what string) string {
+		return fmt.Sprintf(`{"prb_id":%d,"asn":%d,"prefix":"193.0.0.0/21","event":"%s","controller":"ctr-ams01","timestamp":1700000000}`,
+			probe, asn, what)
+	}
+	_ = fake.PublishProbeStatus(
+		event(2, 3333, "disconnect"),  // not in NL
+		event(19, 3333, "disconnect"), // not on the list
+		event(1, 3320, "disconnect"),  // different ASN
+		event(1, 3333, "disconnect"),
+		event(13, 3333, "connect"),
+	)
+
+	expect := []struct {
+		probe uint
+		event string
+	}{{1, "disconnect"}, {13, "connect"}}
+	for _, exp := range expect {
+		res, ok := <-results
+		if !ok || res.Error != nil {
+			t.Fatalf("unexpected stream error: %v", res.Error)
+		}
+		conn, ok := (*res.Result).(*result.ConnectionResult)
+		if !ok || conn.ProbeID != exp.probe || conn.Event != exp.event || conn.Controller != "ctr-ams01" {
+			t.Errorf("unexpected event: %+v", *res.Result)
+		}
+	}
+	if res, ok := <-results; ok {
+		t.Errorf("expected a closed channel after the limit, got %+v", res)
+	}
+}
@@ -0,0 +1,203 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/robert-kisteleki/goat/result"
+)
+
+// when backfilling, go back this much before the last result seen, to
+// catch results that were delayed; duplicates are dropped anyway
+const streamResumeOverlap = 5 * time.Minute
+
+// StreamReconnectError is a non-fatal error put on the results channel when
+// a (reconnecting) stream loses its connection and is about to reconnect
+type StreamReconnectError struct {
+	Attempt int           // how many reconnection attempts were made so far
+	Delay   time.Duration // how long we wait before reconnecting
+	Since   time.Time     // timestamp of the latest result seen, if any
+	Err     error         // the reason
+}
+
+func (e *StreamReconnectError) Error() string {
+	return fmt.Sprintf("stream lost (%v), reconnecting in %v (attempt %d)", e.Err, e.Delay.Round(time.Millisecond), e.Attempt)
+}
+
+func (e *StreamReconnectError) Unwrap() error {
+	return e.Err
+}
+
+// streamDisconnectError signals that the stream connection was lost
+type streamDisconnectError struct {
+	err error
+}
+
+func (e *streamDisconnectError) Error() string {
+	return "disconnected"
+}
+
+func (e *streamDisconnectError) Unwrap() error {
+	return e.err
+}
+
+// results are deduplicated by these
+type resultKey struct {
+	msm       uint
+	probe     uint
+	timestamp int64
+}
+
+// isDuplicate tells if we've already seen this result, and records it if not
+func (filter *ResultsFilter) isDuplicate(res result.Result) bool {
+	ts := res.GetTimeStamp()
+	key := resultKey{res.GetMeasurementID(), res.GetProbeID(), ts.Unix()}
+	if filter.seen[key] {
+		return true
+	}
+	filter.seen[key] = true
+	if ts.After(filter.lastSeen) {
+		filter.lastSeen = ts
+		// keep the memory bounded on long-lived streams as well
+		if filter.lastSeen.Sub(filter.pruned) >= streamResumeOverlap {
+			filter.pruneSeen()
+		}
+	}
+	return false
+}
+
+// forget results that are too old to show up again when backfilling
+func (filter *ResultsFilter) pruneSeen() {
+	filter.pruned = filter.lastSeen
+	horizon := filter.lastSeen.Add(-streamResumeOverlap).Unix()
+	for key := range filter.seen {
+		if key.timestamp < horizon {
+			delete(filter.seen, key)
+		}
+	}
+}
+
+// reconnectingStream keeps streaming, reconnecting (with backoff) and
+// backfilling missed results when the connection is lost
+func (filter *ResultsFilter) reconnectingStream(
+	ctx context.Context,
+	verbose bool,
+	results chan result.AsyncResult,
+) {
+	defer close(results)
+
+	client := clientOrDefault(filter.client)
+	filter.seen = make(map[resultKey]bool)
+	started := time.Now()
+	attempt := 0
+
+	for {
+		backlog := filter.backlog && attempt == 0
+
+		// a replay is simply resumed from where it was
+		replay := filter.start != nil
+		start := filter.start
+		if replay && filter.lastSeen.After(*start) {
+			start = &filter.lastSeen
+		}
+
+		conn, err := filter.connectStream(ctx, verbose, backlog, start)
+		if err == nil {
+			if attempt > 0 && !replay {
+				// fill the gap between the last result (or the start) and now
+				from := filter.lastSeen
+				if from.IsZero() {
+					from = started
+				}
+				from = from.Add(-streamResumeOverlap)
+				if err := filter.backfill(ctx, verbose, from, time.Now(), results); err != nil {
+					err = fmt.Errorf("backfill failed, results after %v may be missing: %w", from.UTC(), err)
+					if !sendContext(ctx, results, result.AsyncResult{Result: nil, Error: err}) {
+						conn.Close()
+						return
+					}
+				}
+				if ctx.Err() != nil || filter.limitReached() {
+					conn.Close()
+					return
+				}
+			}
+
+			fetched := filter.fetched
+			err = filter.receiveStream(ctx, verbose, conn, results)
+			if err == nil {
+				// done: cancelled or enough results
+				return
+			}
+			if filter.fetched > fetched {
+				// this connection was useful, start the backoff over
+				attempt = 0
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		attempt++
+		filter.pruneSeen()
+		delay := client.retry.backoff(attempt)
+		event := &StreamReconnectError{Attempt: attempt, Delay: delay, Since: filter.lastSeen, Err: err}
+		if !sendContext(ctx, results, result.AsyncResult{Result: nil, Error: event}) {
+			return
+		}
+		if verbose {
+			fmt.Printf("# %v\n", event)
+		}
+		if sleepContext(ctx, delay) != nil {
+			return
+		}
+	}
+}
+
+// backfill fetches results in a time window from the data API
+func (filter *ResultsFilter) backfill(
+	ctx context.Context,
+	verbose bool,
+	from time.Time,
+	to time.Time,
+	results chan result.AsyncResult,
+) error {
+	client := clientOrDefault(filter.client)
+
+	params := url.Values{}
+	for key, values := range filter.params {
+		params[key] = values
+	}
+	params.Set("start", fmt.Sprintf("%d", from.Unix()))
+	params.Set("stop", fmt.Sprintf("%d", to.Unix()))
+	query := fmt.Sprintf("%smeasurements/%d/results/?%s", client.apiBaseURL, filter.id, params.Encode())
+
+	if verbose {
+		fmt.Printf("# Backfilling results between %v and %v\n", from.UTC(), to.UTC())
+	}
+
+	resp, err := client.apiGetRequest(ctx, verbose, query, client.apiKeyFor(nil, ApiKeyListMeasurements))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return parseAPIError(resp)
+	}
+
+	read := bufio.NewScanner(bufio.NewReader(resp.Body))
+	for ctx.Err() == nil && !filter.limitReached() && read.Scan() {
+		filter.processResult(ctx, read.Text(), verbose, results)
+	}
+	return read.Err()
+}
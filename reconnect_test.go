@@ -0,0 +1,79 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/robert-kisteleki/goat"
+	"github.com/robert-kisteleki/goat/goattest"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+// Test if a reconnecting stream resumes, backfills and deduplicates
+func TestStreamReconnect(t *testing.T) {
+	fake := goattest.New()
+	defer fake.Close()
+
+	client := fake.Client()
+	client.SetRetryPolicy(goat.RetryPolicy{MaxAttempts: 1, BaseDelay: 10 * time.Millisecond})
+
+	filter := client.NewResultsFilter()
+	filter.FilterID(1001)
+	filter.Stream(true)
+	filter.Reconnect(true)
+	filter.StreamTimeout(5 * time.Second)
+	filter.Limit(4)
+	results := make(chan result.AsyncResult)
+	go filter.GetResults(false, results)
+
+	now := time.Now().Unix()
+	line := func(probe uint, ts int64) string {
+		return fmt.Sprintf(`{"fw":5080,"type":"ping","msm_id":1001,"prb_id":%d,"timestamp":%d,"result":[]}`, probe, ts)
+	}
+
+	// wait for the subscription
+	time.Sleep(100 * time.Millisecond)
+	_ = fake.Publish(line(1, now-10))
+	res := <-results
+	if res.Error != nil || (*res.Result).GetProbeID() != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	// these arrive while we're disconnected, so they have to be backfilled
+	_ = fake.AddResults(line(2, now-5), line(3, now-4))
+	fake.Disconnect()
+
+	res = <-results
+	var reconnect *goat.StreamReconnectError
+	if !errors.As(res.Error, &reconnect) || reconnect.Attempt != 1 || reconnect.Since.Unix() != now-10 {
+		t.Fatalf("expected a reconnect event, got %+v", res)
+	}
+
+	// the first one is backfilled again, but it should be dropped
+	for _, probe := range []uint{2, 3} {
+		res = <-results
+		if res.Error != nil || (*res.Result).GetProbeID() != probe {
+			t.Fatalf("unexpected backfilled result: %+v", res)
+		}
+	}
+
+	// and the stream goes on
+	time.Sleep(100 * time.Millisecond)
+	_ = fake.Publish(line(3, now-4), line(4, now))
+	res = <-results
+	if res.Error != nil || (*res.Result).GetProbeID() != 4 {
+		t.Fatalf("unexpected result after reconnecting: %+v", res)
+	}
+
+	if res, ok := <-results; ok {
+		t.Errorf("expected a closed channel after the limit, got %+v", res)
+	}
+}
@@ -0,0 +1,462 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// AsnTable maps prefixes to their origin ASNs, and finds the longest
+// matching prefix for addresses. It can also hold IXP peering LAN prefixes.
+type AsnTable struct {
+	prefixes map[netip.Prefix][]uint // origin ASNs per prefix
+	ixps     map[netip.Prefix]string // IXP name per prefix
+	lengths  [2][]int                // prefix lengths in use (IPv4, IPv6), longest first
+}
+
+// AsHopKind tells what kind of address a hop has
+type AsHopKind int
+
+const (
+	AsHopUnresponsive AsHopKind = iota // no (useful) response
+	AsHopPrivate                       // private, shared, loopback or link local address
+	AsHopIxp                           // address from an IXP peering LAN
+	AsHopUnannounced                   // public address not in the routing data
+	AsHopRouted                        // address with an origin AS
+)
+
+// AsHop is an address classified by the routing data
+type AsHop struct {
+	Kind AsHopKind //
+	Asns []uint    // origin ASNs, more than one for multi-origin prefixes
+	Ixp  string    // name of the IXP, if known
+}
+
+// AsPathElement is one step in an AS path: one or more consecutive
+// traceroute hops that map to the same thing
+type AsPathElement struct {
+	AsHop
+	FirstHop uint // first hop number
+	LastHop  uint // last hop number
+}
+
+// AsPath is the AS level view of a traceroute
+type AsPath []AsPathElement
+
+// special purpose IPv4 ranges that are not covered by netip's checks
+var specialPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+}
+
+// NewAsnTable returns an empty table
+func NewAsnTable() AsnTable {
+	return AsnTable{
+		prefixes: make(map[netip.Prefix][]uint),
+		ixps:     make(map[netip.Prefix]string),
+	}
+}
+
+// Len returns the number of prefixes in the table
+func (table *AsnTable) Len() int {
+	return len(table.prefixes)
+}
+
+// Add adds a prefix with its origin ASNs; origins of a prefix that is
+// already present are merged
+func (table *AsnTable) Add(prefix netip.Prefix, asns ...uint) {
+	prefix = prefix.Masked()
+	list := table.prefixes[prefix]
+	for _, asn := range asns {
+		if !slices.Contains(list, asn) {
+			list = append(list, asn)
+		}
+	}
+	table.prefixes[prefix] = list
+	table.addLength(prefix)
+}
+
+// AddIxp adds an IXP peering LAN prefix
+func (table *AsnTable) AddIxp(prefix netip.Prefix, name string) {
+	prefix = prefix.Masked()
+	table.ixps[prefix] = name
+	table.addLength(prefix)
+}
+
+func (table *AsnTable) addLength(prefix netip.Prefix) {
+	family := 0
+	if prefix.Addr().Is6() {
+		family = 1
+	}
+	if !slices.Contains(table.lengths[family], prefix.Bits()) {
+		table.lengths[family] = append(table.lengths[family], prefix.Bits())
+		slices.Sort(table.lengths[family])
+		slices.Reverse(table.lengths[family])
+	}
+}
+
+// Lookup returns the longest matching prefix for an address, and its
+// origin ASNs
+func (table *AsnTable) Lookup(addr netip.Addr) (netip.Prefix, []uint, bool) {
+	addr = addr.Unmap()
+	family := 0
+	if addr.Is6() {
+		family = 1
+	}
+	for _, bits := range table.lengths[family] {
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if asns, ok := table.prefixes[prefix]; ok {
+			return prefix, asns, true
+		}
+	}
+	return netip.Prefix{}, nil, false
+}
+
+// lookupIxp returns the name of the IXP if the address is in an IXP prefix
+func (table *AsnTable) lookupIxp(addr netip.Addr) (string, bool) {
+	family := 0
+	if addr.Is6() {
+		family = 1
+	}
+	for _, bits := range table.lengths[family] {
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if name, ok := table.ixps[prefix]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Classify tells what kind of address this is, and its origin ASNs if
+// it's routed
+func (table *AsnTable) Classify(addr netip.Addr) AsHop {
+	addr = addr.Unmap()
+	switch {
+	case !addr.IsValid():
+		return AsHop{Kind: AsHopUnresponsive}
+	case isPrivateAddr(addr):
+		return AsHop{Kind: AsHopPrivate}
+	}
+	if name, ok := table.lookupIxp(addr); ok {
+		return AsHop{Kind: AsHopIxp, Ixp: name}
+	}
+	if _, asns, ok := table.Lookup(addr); ok {
+		return AsHop{Kind: AsHopRouted, Asns: asns}
+	}
+	return AsHop{Kind: AsHopUnannounced}
+}
+
+// isPrivateAddr tells if an address is private, shared, loopback or link
+// local, i.e. not globally unique
+func isPrivateAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsUnspecified() ||
+		slices.ContainsFunc(specialPrefixes, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
+
+// Read loads routing data, either in CAIDA's pfx2as format or as an MRT
+// RIB dump (TABLE_DUMP_V2), which is recognised by its content
+func (table *AsnTable) Read(input io.Reader) error {
+	buffered := bufio.NewReader(input)
+	header, _ := buffered.Peek(6)
+	if len(header) == 6 && binary.BigEndian.Uint16(header[4:]) == mrtTableDumpV2 {
+		return table.ReadMRT(buffered)
+	}
+	return table.ReadPfx2as(buffered)
+}
+
+// ReadPfx2as loads routing data in CAIDA's pfx2as format: prefix, length
+// and origin ASNs per line, where multiple origins are separated by _ and
+// AS sets by commas
+func (table *AsnTable) ReadPfx2as(input io.Reader) error {
+	scanner := bufio.NewScanner(input)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return fmt.Errorf("pfx2as line %d: expected 3 fields, got %d", n, len(fields))
+		}
+		prefix, err := netip.ParsePrefix(fields[0] + "/" + fields[1])
+		if err != nil {
+			return fmt.Errorf("pfx2as line %d: %v", n, err)
+		}
+		asns := make([]uint, 0)
+		for _, item := range strings.FieldsFunc(fields[2], func(r rune) bool {
+			return r == '_' || r == ',' || r == '{' || r == '}'
+		}) {
+			asn, err := strconv.ParseUint(item, 10, 32)
+			if err != nil {
+				return fmt.Errorf("pfx2as line %d: invalid ASN %s", n, item)
+			}
+			asns = append(asns, uint(asn))
+		}
+		if len(asns) == 0 {
+			return fmt.Errorf("pfx2as line %d: no ASN in %s", n, fields[2])
+		}
+		table.Add(prefix, asns...)
+	}
+	return scanner.Err()
+}
+
+// ReadIxps loads IXP peering LAN prefixes: a prefix and optionally the
+// name of the IXP per line
+func (table *AsnTable) ReadIxps(input io.Reader) error {
+	scanner := bufio.NewScanner(input)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		prefix, err := netip.ParsePrefix(fields[0])
+		if err != nil {
+			return fmt.Errorf("IXP line %d: %v", n, err)
+		}
+		table.AddIxp(prefix, strings.Join(fields[1:], " "))
+	}
+	return scanner.Err()
+}
+
+// AsPath returns the AS level view of the traceroute. Each hop is
+// represented by its most frequent responding address; consecutive hops
+// of the same kind and origin are merged.
+func (trace *TracerouteResult) AsPath(table *AsnTable) AsPath {
+	path := make(AsPath, 0)
+	for _, hop := range trace.Hops {
+		as := table.Classify(hop.Address())
+		if last := len(path) - 1; last >= 0 && path[last].AsHop.equal(as) {
+			path[last].LastHop = hop.HopNumber
+			continue
+		}
+		path = append(path, AsPathElement{as, hop.HopNumber, hop.HopNumber})
+	}
+	return path
+}
+
+// Address returns the most frequent address that responded in a hop
+// (the first one in case of a tie), or an invalid address if none did
+func (hop *TracerouteHop) Address() netip.Addr {
+	counts := make(map[netip.Addr]int)
+	best := netip.Addr{}
+	for _, resp := range hop.Responses {
+		if resp.Timeout || resp.Error != nil || resp.Late != nil || !resp.From.IsValid() {
+			continue
+		}
+		counts[resp.From]++
+		if counts[resp.From] > counts[best] {
+			best = resp.From
+		}
+	}
+	return best
+}
+
+// Asns returns the ASes traversed, in order, ignoring hops that don't
+// map to an AS. For multi-origin prefixes the first origin is used.
+func (path AsPath) Asns() []uint {
+	asns := make([]uint, 0)
+	for _, elem := range path {
+		if elem.Kind != AsHopRouted || len(elem.Asns) == 0 {
+			continue
+		}
+		if len(asns) == 0 || asns[len(asns)-1] != elem.Asns[0] {
+			asns = append(asns, elem.Asns[0])
+		}
+	}
+	return asns
+}
+
+func (path AsPath) String() string {
+	list := make([]string, 0, len(path))
+	for _, elem := range path {
+		list = append(list, elem.String())
+	}
+	return strings.Join(list, " ")
+}
+
+func (as AsHop) String() string {
+	switch as.Kind {
+	case AsHopUnresponsive:
+		return "*"
+	case AsHopPrivate:
+		return "private"
+	case AsHopIxp:
+		if as.Ixp != "" {
+			return "IXP:" + as.Ixp
+		}
+		return "IXP"
+	case AsHopUnannounced:
+		return "AS?"
+	}
+	list := make([]string, 0, len(as.Asns))
+	for _, asn := range as.Asns {
+		list = append(list, fmt.Sprintf("AS%d", asn))
+	}
+	return strings.Join(list, "_")
+}
+
+func (as AsHop) equal(other AsHop) bool {
+	return as.Kind == other.Kind && as.Ixp == other.Ixp && slices.Equal(as.Asns, other.Asns)
+}
+
+//////////////////////////////////////////////////////
+// MRT (RFC 6396) RIB dumps
+
+const (
+	mrtTableDumpV2 = 13
+
+	mrtRibIPv4Unicast        = 2
+	mrtRibIPv6Unicast        = 4
+	mrtRibIPv4UnicastAddPath = 8
+	mrtRibIPv6UnicastAddPath = 10
+
+	bgpAttrAsPath   = 2
+	bgpAsSet        = 1
+	bgpAsSequence   = 2
+	bgpAttrExtended = 0x10
+)
+
+// ReadMRT loads the origin ASNs from an MRT RIB dump (TABLE_DUMP_V2). The
+// origins seen by all peers are kept for each prefix.
+func (table *AsnTable) ReadMRT(input io.Reader) error {
+	header := make([]byte, 12)
+	for {
+		if _, err := io.ReadFull(input, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("MRT header: %v", err)
+		}
+		typ := binary.BigEndian.Uint16(header[4:])
+		subtype := binary.BigEndian.Uint16(header[6:])
+		message := make([]byte, binary.BigEndian.Uint32(header[8:]))
+		if _, err := io.ReadFull(input, message); err != nil {
+			return fmt.Errorf("MRT message: %v", err)
+		}
+		if typ != mrtTableDumpV2 {
+			continue
+		}
+
+		var err error
+		switch subtype {
+		case mrtRibIPv4Unicast:
+			err = table.addMRTRib(message, 4, false)
+		case mrtRibIPv6Unicast:
+			err = table.addMRTRib(message, 16, false)
+		case mrtRibIPv4UnicastAddPath:
+			err = table.addMRTRib(message, 4, true)
+		case mrtRibIPv6UnicastAddPath:
+			err = table.addMRTRib(message, 16, true)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// addMRTRib processes a RIB entry: sequence number, prefix, and the
+// entries (attributes) from the peers
+func (table *AsnTable) addMRTRib(message []byte, addrlen int, addpath bool) error {
+	short := fmt.Errorf("MRT RIB entry is too short")
+	if len(message) < 5 {
+		return short
+	}
+	bits := int(message[4])
+	plen := (bits + 7) / 8
+	if bits > addrlen*8 || len(message) < 5+plen+2 {
+		return short
+	}
+	raw := make([]byte, addrlen)
+	copy(raw, message[5:5+plen])
+	addr, _ := netip.AddrFromSlice(raw)
+	prefix := netip.PrefixFrom(addr, bits)
+
+	data := message[5+plen:]
+	count := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+	for range count {
+		skip := 6 // peer index, originated time
+		if addpath {
+			skip += 4
+		}
+		if len(data) < skip+2 {
+			return short
+		}
+		attrlen := int(binary.BigEndian.Uint16(data[skip:]))
+		if len(data) < skip+2+attrlen {
+			return short
+		}
+		if origins := mrtOrigins(data[skip+2 : skip+2+attrlen]); len(origins) > 0 {
+			table.Add(prefix, origins...)
+		}
+		data = data[skip+2+attrlen:]
+	}
+	return nil
+}
+
+// mrtOrigins finds the origin AS(es) in BGP path attributes: the last AS
+// of the AS path, or all of the last segment if it's an AS set
+func mrtOrigins(attrs []byte) []uint {
+	for len(attrs) >= 3 {
+		flags, typ := attrs[0], attrs[1]
+		length, start := int(attrs[2]), 3
+		if flags&bgpAttrExtended != 0 {
+			if len(attrs) < 4 {
+				return nil
+			}
+			length, start = int(binary.BigEndian.Uint16(attrs[2:])), 4
+		}
+		if len(attrs) < start+length {
+			return nil
+		}
+		if typ == bgpAttrAsPath {
+			return asPathOrigins(attrs[start : start+length])
+		}
+		attrs = attrs[start+length:]
+	}
+	return nil
+}
+
+// asPathOrigins returns the origin(s) from an AS_PATH with 4 byte ASNs
+func asPathOrigins(path []byte) []uint {
+	var origins []uint
+	for len(path) >= 2 {
+		segtype, count := path[0], int(path[1])
+		if len(path) < 2+4*count || count == 0 {
+			break
+		}
+		asns := make([]uint, 0, count)
+		for i := range count {
+			asns = append(asns, uint(binary.BigEndian.Uint32(path[2+4*i:])))
+		}
+		switch segtype {
+		case bgpAsSequence:
+			origins = asns[count-1:]
+		case bgpAsSet:
+			origins = asns
+		}
+		path = path[2+4*count:]
+	}
+	return origins
+}
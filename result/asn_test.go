@@ -0,0 +1,160 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testPfx2as = `# comment
+192.0.2.0	24	64500
+192.0.2.128	25	64501
+198.51.100.0	24	64502_64503
+2001:db8::	32	64504
+2001:db8:1::	48	{64505,64506}
+`
+
+// Test if prefixes are loaded and matched properly
+func TestAsnTable(t *testing.T) {
+	table := NewAsnTable()
+	if err := table.Read(strings.NewReader(testPfx2as)); err != nil {
+		t.Fatalf("reading pfx2as failed: %v", err)
+	}
+	if table.Len() != 5 {
+		t.Errorf("expected 5 prefixes, got %d", table.Len())
+	}
+
+	tests := []struct {
+		addr   string
+		prefix string
+		asns   []uint
+	}{
+		{"192.0.2.1", "192.0.2.0/24", []uint{64500}},
+		{"192.0.2.200", "192.0.2.128/25", []uint{64501}},
+		{"::ffff:192.0.2.200", "192.0.2.128/25", []uint{64501}},
+		{"198.51.100.7", "198.51.100.0/24", []uint{64502, 64503}},
+		{"2001:db8:2::1", "2001:db8::/32", []uint{64504}},
+		{"2001:db8:1::1", "2001:db8:1::/48", []uint{64505, 64506}},
+		{"203.0.113.1", "", nil},
+	}
+	for _, test := range tests {
+		prefix, asns, ok := table.Lookup(netip.MustParseAddr(test.addr))
+		if ok != (test.prefix != "") || (ok && prefix.String() != test.prefix) || !slices.Equal(asns, test.asns) {
+			t.Errorf("%s: expected %s %v, got %v %v", test.addr, test.prefix, test.asns, prefix, asns)
+		}
+	}
+
+	for _, line := range []string{"192.0.2.0\t24\n", "192.0.2.0\t24\t_\n", "192.0.2.0\t24\t{}\n"} {
+		if err := table.ReadPfx2as(strings.NewReader(line)); err == nil {
+			t.Errorf("invalid pfx2as line was accepted: %q", line)
+		}
+	}
+}
+
+// mrtRecord makes a TABLE_DUMP_V2 record
+func mrtRecord(subtype uint16, message []byte) []byte {
+	record := binary.BigEndian.AppendUint32(nil, 1700000000)
+	record = binary.BigEndian.AppendUint16(record, mrtTableDumpV2)
+	record = binary.BigEndian.AppendUint16(record, subtype)
+	record = binary.BigEndian.AppendUint32(record, uint32(len(message)))
+	return append(record, message...)
+}
+
+// mrtRib makes a RIB entry with one entry per AS path
+func mrtRib(prefix netip.Prefix, paths ...[]byte) []byte {
+	message := binary.BigEndian.AppendUint32(nil, 1)
+	message = append(message, byte(prefix.Bits()))
+	message = append(message, prefix.Addr().AsSlice()[:(prefix.Bits()+7)/8]...)
+	message = binary.BigEndian.AppendUint16(message, uint16(len(paths)))
+	for i, path := range paths {
+		attrs := []byte{0x40, 1, 1, 0} // ORIGIN: IGP
+		attrs = append(attrs, 0x50, bgpAttrAsPath)
+		attrs = binary.BigEndian.AppendUint16(attrs, uint16(len(path)))
+		attrs = append(attrs, path...)
+		message = binary.BigEndian.AppendUint16(message, uint16(i))
+		message = binary.BigEndian.AppendUint32(message, 1700000000)
+		message = binary.BigEndian.AppendUint16(message, uint16(len(attrs)))
+		message = append(message, attrs...)
+	}
+	return message
+}
+
+func asSegment(segtype byte, asns ...uint32) []byte {
+	segment := []byte{segtype, byte(len(asns))}
+	for _, asn := range asns {
+		segment = binary.BigEndian.AppendUint32(segment, asn)
+	}
+	return segment
+}
+
+// Test if MRT RIB dumps are loaded
+func TestAsnTableMRT(t *testing.T) {
+	var dump bytes.Buffer
+	dump.Write(mrtRecord(1, []byte{0, 0, 0, 0, 0, 0, 0, 0})) // peer index table, ignored
+	dump.Write(mrtRecord(mrtRibIPv4Unicast, mrtRib(netip.MustParsePrefix("192.0.2.0/23"),
+		asSegment(bgpAsSequence, 64496, 64500),
+		append(asSegment(bgpAsSequence, 64497), asSegment(bgpAsSequence, 64498, 64501)...),
+	)))
+	dump.Write(mrtRecord(mrtRibIPv6Unicast, mrtRib(netip.MustParsePrefix("2001:db8::/32"),
+		append(asSegment(bgpAsSequence, 64496), asSegment(bgpAsSet, 64502, 64503)...),
+	)))
+
+	table := NewAsnTable()
+	if err := table.Read(&dump); err != nil {
+		t.Fatalf("reading MRT failed: %v", err)
+	}
+	if _, asns, _ := table.Lookup(netip.MustParseAddr("192.0.3.1")); !slices.Equal(asns, []uint{64500, 64501}) {
+		t.Errorf("IPv4 origins are wrong: %v", asns)
+	}
+	if _, asns, _ := table.Lookup(netip.MustParseAddr("2001:db8::1")); !slices.Equal(asns, []uint{64502, 64503}) {
+		t.Errorf("IPv6 origins are wrong: %v", asns)
+	}
+
+	truncated := mrtRecord(mrtRibIPv4Unicast, mrtRib(netip.MustParsePrefix("192.0.2.0/24"), asSegment(bgpAsSequence, 64500)))
+	if err := table.ReadMRT(bytes.NewReader(truncated[:len(truncated)-3])); err == nil {
+		t.Error("truncated MRT was accepted")
+	}
+}
+
+// Test if AS paths are derived from traceroutes
+func TestAsPath(t *testing.T) {
+	table := NewAsnTable()
+	table.ReadPfx2as(strings.NewReader(testPfx2as))
+	table.ReadIxps(strings.NewReader("# IXPs\n203.0.113.0/24\tTest-IX\n"))
+
+	var trace TracerouteResult
+	err := trace.Parse(`{"fw":5080,"type":"traceroute","msm_id":5001,"prb_id":1,"timestamp":1700000000,"af":4,"dst_addr":"198.51.100.7","paris_id":1,"result":[` +
+		`{"hop":1,"result":[{"from":"192.168.1.1","rtt":1.0,"size":28,"ttl":64},{"from":"192.168.1.1","rtt":1.0,"size":28,"ttl":64}]},` +
+		`{"hop":2,"result":[{"from":"100.64.0.1","rtt":2.0,"size":28,"ttl":63}]},` +
+		`{"hop":3,"result":[{"from":"192.0.2.1","rtt":3.0,"size":28,"ttl":62},{"from":"192.0.2.200","rtt":3.0,"size":28,"ttl":62},{"from":"192.0.2.1","rtt":3.0,"size":28,"ttl":62}]},` +
+		`{"hop":4,"result":[{"from":"192.0.2.2","rtt":3.0,"size":28,"ttl":61}]},` +
+		`{"hop":5,"result":[{"x":"*"},{"x":"*"},{"x":"*"}]},` +
+		`{"hop":6,"result":[{"from":"192.0.2.3","rtt":3.0,"size":28,"ttl":59}]},` +
+		`{"hop":7,"result":[{"from":"203.0.113.5","rtt":4.0,"size":28,"ttl":58}]},` +
+		`{"hop":8,"result":[{"from":"192.0.2.130","rtt":4.0,"size":28,"ttl":57}]},` +
+		`{"hop":9,"result":[{"from":"233.252.0.1","rtt":4.0,"size":28,"ttl":56}]},` +
+		`{"hop":10,"result":[{"x":"*"},{"from":"198.51.100.7","rtt":5.0,"size":28,"ttl":55}]}]}`)
+	if err != nil {
+		t.Fatalf("parsing failed: %v", err)
+	}
+
+	path := trace.AsPath(&table)
+	if path.String() != "private AS64500 * AS64500 IXP:Test-IX AS64501 AS? AS64502_AS64503" {
+		t.Errorf("unexpected AS path: %s", path)
+	}
+	if path[0].FirstHop != 1 || path[0].LastHop != 2 || path[1].FirstHop != 3 || path[1].LastHop != 4 {
+		t.Errorf("hops are merged wrong: %+v", path)
+	}
+	if asns := path.Asns(); !slices.Equal(asns, []uint{64500, 64501, 64502}) {
+		t.Errorf("unexpected ASNs: %v", asns)
+	}
+}
@@ -84,6 +84,10 @@ func (result *BaseResult) GetProbeID() uint {
 	return result.ProbeID
 }
 
+func (result *BaseResult) GetMeasurementID() uint {
+	return result.MeasurementID
+}
+
 func (result *BaseResult) GetFirmwareVersion() uint {
 	return uint(result.FirmwareVersion)
 }
@@ -111,3 +115,71 @@ func (fw *firmwareVersion) UnmarshalJSON(b []byte) error {
 	}
 	return nil
 }
+
+// rawBaseResult is the marshaled version of BaseResult, leaving out the
+// fields that are not set. It's embedded next to the API version of a
+// result (which embeds BaseResult one level deeper), so encoding/json uses
+// these fields instead of the ones in BaseResult.
+type rawBaseResult struct {
+	FirmwareVersion uint        `json:"fw,omitempty"`               //
+	CodeVersion     string      `json:"mver,omitempty"`             //
+	MeasurementID   uint        `json:"msm_id,omitempty"`           //
+	GroupID         uint        `json:"group_id,omitempty"`         //
+	ProbeID         uint        `json:"prb_id"`                     //
+	MeasurementName string      `json:"msm_name,omitempty"`         //
+	Type            string      `json:"type"`                       //
+	TimeStamp       uniTime     `json:"timestamp"`                  //
+	StoreTimeStamp  *uniTime    `json:"stored_timestamp,omitempty"` //
+	Bundle          uint        `json:"bundle,omitempty"`           //
+	LastTimeSync    int         `json:"lts,omitempty"`              //
+	DestinationName string      `json:"dst_name,omitempty"`         //
+	DestinationAddr *netip.Addr `json:"dst_addr,omitempty"`         //
+	SourceAddr      *netip.Addr `json:"src_addr,omitempty"`         //
+	FromAddr        *netip.Addr `json:"from,omitempty"`             //
+	AddressFamily   uint        `json:"af,omitempty"`               //
+	ResolveTime     *float64    `json:"ttr,omitempty"`              //
+}
+
+func (result *BaseResult) raw() rawBaseResult {
+	raw := rawBaseResult{
+		FirmwareVersion: uint(result.FirmwareVersion),
+		CodeVersion:     result.CodeVersion,
+		MeasurementID:   result.MeasurementID,
+		GroupID:         result.GroupID,
+		ProbeID:         result.ProbeID,
+		MeasurementName: result.MeasurementName,
+		Type:            result.Type,
+		TimeStamp:       result.TimeStamp,
+		Bundle:          result.Bundle,
+		LastTimeSync:    result.LastTimeSync,
+		DestinationName: result.DestinationName,
+		DestinationAddr: result.DestinationAddr,
+		SourceAddr:      addrOrNil(result.SourceAddr),
+		FromAddr:        addrOrNil(result.FromAddr),
+		AddressFamily:   result.AddressFamily,
+		ResolveTime:     result.ResolveTime,
+	}
+	if !time.Time(result.StoreTimeStamp).IsZero() {
+		raw.StoreTimeStamp = &result.StoreTimeStamp
+	}
+	if raw.DestinationAddr != nil && !raw.DestinationAddr.IsValid() {
+		raw.DestinationAddr = nil
+	}
+	return raw
+}
+
+// derefAddr returns the address, or an invalid one if there's none
+func derefAddr(addr *netip.Addr) netip.Addr {
+	if addr == nil {
+		return netip.Addr{}
+	}
+	return *addr
+}
+
+// addrOrNil returns a pointer to the address, or nil if it's not valid
+func addrOrNil(addr netip.Addr) *netip.Addr {
+	if !addr.IsValid() {
+		return nil
+	}
+	return &addr
+}
@@ -30,8 +30,8 @@ type CertResult struct {
 // CertAlert is an error that could be sent by the server
 // see RFC 5246 section 7.2
 type CertAlert struct {
-	Level       uint //
-	Description uint //
+	Level       uint `json:"level"`       //
+	Description uint `json:"description"` //
 }
 
 const (
@@ -87,20 +87,58 @@ func (cert *CertResult) Parse(from string) (err error) {
 	return nil
 }
 
+// MarshalJSON turns the result back into the format used by the API
+func (cert CertResult) MarshalJSON() ([]byte, error) {
+	icert := certResult{
+		BaseResult: cert.BaseResult,
+		Alert:      cert.Alert,
+		Error:      cert.Error,
+	}
+	if cert.Error == nil {
+		if cert.DnsError != "" {
+			icert.DnsError = &cert.DnsError
+		} else {
+			if cert.Method != "" {
+				icert.Method = &cert.Method
+			}
+			icert.ReplyTime = &cert.ReplyTime
+			icert.ConnectTime = &cert.ConnectTime
+			if cert.Alert == nil {
+				if cert.ServerCipher != "" {
+					icert.ServerCipher = &cert.ServerCipher
+				}
+				if cert.ProtocolVersion != "" {
+					icert.ProtocolVersion = &cert.ProtocolVersion
+				}
+				certs := make([]string, 0)
+				for _, c := range cert.Certificates {
+					certs = append(certs, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})))
+				}
+				icert.RawCertificates = &certs
+			}
+		}
+	}
+
+	return json.Marshal(struct {
+		rawBaseResult
+		certResult
+	}{cert.BaseResult.raw(), icert})
+}
+
 //////////////////////////////////////////////////////
 // API version of an sslcert result
 
 type certResult struct {
 	BaseResult
-	Alert           *CertAlert `json:"alert"`         //
-	Method          *string    `json:"method"`        //
-	ReplyTime       *float64   `json:"rt"`            //
-	ServerCipher    *string    `json:"server_cipher"` //
-	ConnectTime     *float64   `json:"ttc"`           //
-	ProtocolVersion *string    `json:"ver"`           //
-	Error           *string    `json:"err"`           //
-	RawCertificates *[]string  `json:"cert"`          //
-	DnsError        *string    `json:"dnserr"`        //
+	Alert           *CertAlert `json:"alert,omitempty"`         //
+	Method          *string    `json:"method,omitempty"`        //
+	ReplyTime       *float64   `json:"rt,omitempty"`            //
+	ServerCipher    *string    `json:"server_cipher,omitempty"` //
+	ConnectTime     *float64   `json:"ttc,omitempty"`           //
+	ProtocolVersion *string    `json:"ver,omitempty"`           //
+	Error           *string    `json:"err,omitempty"`           //
+	RawCertificates *[]string  `json:"cert,omitempty"`          //
+	DnsError        *string    `json:"dnserr,omitempty"`        //
 }
 
 func (result *certResult) Certificates() (list []x509.Certificate, err error) {
@@ -0,0 +1,189 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CertVerifier verifies the certificate chains of TLS results
+type CertVerifier struct {
+	roots      *x509.CertPool // nil means the system pool
+	serverName string         // overrides the destination name of results
+	issuers    []string       // expected issuers, any if empty
+	horizon    time.Duration  // expiry horizon
+}
+
+// CertVerdict is the outcome of verifying a certificate chain at the time
+// of the result
+type CertVerdict struct {
+	Fingerprint      string        // of the presented chain, see ChainFingerprint()
+	Subject          string        // common name of the leaf
+	Issuer           string        // common name (or organisation) of the issuer of the leaf
+	NotAfter         time.Time     // earliest expiry in the presented chain
+	ExpiresIn        time.Duration // time left until NotAfter (negative if expired)
+	Verified         bool          // the chain verifies to a trusted root, for the server name
+	Error            error         // why the chain doesn't verify
+	Expired          bool          // a certificate in the chain has expired
+	NotYetValid      bool          // a certificate in the chain is not yet valid
+	Expiring         bool          // the chain expires within the horizon
+	HostnameMismatch bool          // the leaf is not valid for the server name
+	SelfSigned       bool          // the leaf is self-signed
+	UnexpectedIssuer bool          // the issuer of the leaf is not one of the expected ones
+}
+
+// NewCertVerifier returns a verifier that uses the system's root
+// certificates and an expiry horizon of 30 days
+func NewCertVerifier() CertVerifier {
+	return CertVerifier{
+		issuers: make([]string, 0),
+		horizon: 30 * 24 * time.Hour,
+	}
+}
+
+// ReadCABundle reads PEM encoded root certificates to verify against
+// instead of the system's pool. It can be called multiple times.
+func (verifier *CertVerifier) ReadCABundle(input io.Reader) error {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return err
+	}
+	if verifier.roots == nil {
+		verifier.roots = x509.NewCertPool()
+	}
+	if !verifier.roots.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificates found in CA bundle")
+	}
+	return nil
+}
+
+// ServerName sets the name to check certificates against (the SNI used by
+// the measurement); by default it's the destination name of the result
+func (verifier *CertVerifier) ServerName(name string) {
+	verifier.serverName = name
+}
+
+// ExpectedIssuers sets the acceptable issuers of leaf certificates, as
+// common names or organisations
+func (verifier *CertVerifier) ExpectedIssuers(issuers []string) {
+	verifier.issuers = issuers
+}
+
+// ExpiryHorizon sets how close expiry should be to be reported
+func (verifier *CertVerifier) ExpiryHorizon(horizon time.Duration) {
+	verifier.horizon = horizon
+}
+
+// Verify verifies the chain of a result at the time of the result. It
+// returns an error if there's no chain at all.
+func (verifier *CertVerifier) Verify(res *CertResult) (CertVerdict, error) {
+	if len(res.Certificates) == 0 {
+		return CertVerdict{}, fmt.Errorf("no certificates in the result")
+	}
+
+	when := res.GetTimeStamp()
+	leaf := &res.Certificates[0]
+	verdict := CertVerdict{
+		Fingerprint: res.ChainFingerprint(),
+		Subject:     leaf.Subject.CommonName,
+		Issuer:      issuerName(leaf),
+	}
+
+	intermediates := x509.NewCertPool()
+	for i := range res.Certificates[1:] {
+		intermediates.AddCert(&res.Certificates[i+1])
+	}
+	name := verifier.serverName
+	if name == "" {
+		name = res.DestinationName
+	}
+	_, verdict.Error = leaf.Verify(x509.VerifyOptions{
+		DNSName:       name,
+		Roots:         verifier.roots,
+		Intermediates: intermediates,
+		CurrentTime:   when,
+	})
+	verdict.Verified = verdict.Error == nil
+
+	for _, cert := range res.Certificates {
+		if verdict.NotAfter.IsZero() || cert.NotAfter.Before(verdict.NotAfter) {
+			verdict.NotAfter = cert.NotAfter
+		}
+		verdict.NotYetValid = verdict.NotYetValid || when.Before(cert.NotBefore)
+	}
+	verdict.ExpiresIn = verdict.NotAfter.Sub(when)
+	verdict.Expired = verdict.ExpiresIn < 0
+	verdict.Expiring = !verdict.Expired && verdict.ExpiresIn < verifier.horizon
+
+	verdict.HostnameMismatch = name != "" && leaf.VerifyHostname(name) != nil
+	verdict.SelfSigned = bytes.Equal(leaf.RawIssuer, leaf.RawSubject) &&
+		leaf.CheckSignature(leaf.SignatureAlgorithm, leaf.RawTBSCertificate, leaf.Signature) == nil
+	if len(verifier.issuers) > 0 {
+		verdict.UnexpectedIssuer = !slices.ContainsFunc(verifier.issuers, func(issuer string) bool {
+			return strings.EqualFold(issuer, leaf.Issuer.CommonName) ||
+				slices.ContainsFunc(leaf.Issuer.Organization, func(org string) bool {
+					return strings.EqualFold(issuer, org)
+				})
+		})
+	}
+
+	return verdict, nil
+}
+
+// Problems lists the problems found as short keywords
+func (verdict *CertVerdict) Problems() []string {
+	problems := make([]string, 0)
+	for _, check := range []struct {
+		failed  bool
+		problem string
+	}{
+		{!verdict.Verified, "unverified"},
+		{verdict.Expired, "expired"},
+		{verdict.NotYetValid, "notyetvalid"},
+		{verdict.Expiring, "expiring"},
+		{verdict.HostnameMismatch, "mismatch"},
+		{verdict.SelfSigned, "selfsigned"},
+		{verdict.UnexpectedIssuer, "unexpectedissuer"},
+	} {
+		if check.failed {
+			problems = append(problems, check.problem)
+		}
+	}
+	return problems
+}
+
+// ChainFingerprint returns the SHA-256 fingerprint of the presented chain
+// (hex encoded), or an empty string if there's none
+func (cert *CertResult) ChainFingerprint() string {
+	if len(cert.Certificates) == 0 {
+		return ""
+	}
+	hash := sha256.New()
+	for _, c := range cert.Certificates {
+		hash.Write(c.Raw)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// issuerName returns a short name of the issuer of a certificate
+func issuerName(cert *x509.Certificate) string {
+	if cert.Issuer.CommonName != "" {
+		return cert.Issuer.CommonName
+	}
+	if len(cert.Issuer.Organization) > 0 {
+		return cert.Issuer.Organization[0]
+	}
+	return cert.Issuer.String()
+}
@@ -0,0 +1,141 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makeTestCert makes a certificate signed by the parent, or a self-signed one
+func makeTestCert(t *testing.T, name string, ca bool, from, until time.Time, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, crypto.Signer) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name, Organization: []string{"Test Org"}},
+		NotBefore:             from,
+		NotAfter:              until,
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if !ca {
+		template.DNSNames = []string{name}
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func toPEM(certs ...*x509.Certificate) []string {
+	list := make([]string, 0)
+	for _, cert := range certs {
+		list = append(list, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+	}
+	return list
+}
+
+func makeCertResult(t *testing.T, when time.Time, name string, certs ...*x509.Certificate) *CertResult {
+	encoded, _ := json.Marshal(toPEM(certs...))
+	var res CertResult
+	err := res.Parse(fmt.Sprintf(`{"fw":5080,"type":"sslcert","msm_id":4001,"prb_id":1,"timestamp":%d,"dst_name":"%s","method":"TLS","ver":"1.3","cert":%s}`,
+		when.Unix(), name, encoded))
+	if err != nil {
+		t.Fatalf("parsing failed: %v", err)
+	}
+	return &res
+}
+
+// Test if chains are verified and problems are reported
+func TestCertVerification(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	year := 365 * 24 * time.Hour
+	root, rootKey := makeTestCert(t, "Test Root", true, now.Add(-year), now.Add(10*year), nil, nil)
+	inter, interKey := makeTestCert(t, "Test CA", true, now.Add(-year), now.Add(5*year), root, rootKey)
+	leaf, _ := makeTestCert(t, "www.example.com", false, now.Add(-time.Hour), now.Add(90*24*time.Hour), inter, interKey)
+	soon, _ := makeTestCert(t, "www.example.com", false, now.Add(-time.Hour), now.Add(10*24*time.Hour), inter, interKey)
+	self, _ := makeTestCert(t, "www.example.com", false, now.Add(-time.Hour), now.Add(year), nil, nil)
+
+	verifier := NewCertVerifier()
+	if err := verifier.ReadCABundle(strings.NewReader(strings.Join(toPEM(root), ""))); err != nil {
+		t.Fatalf("reading CA bundle failed: %v", err)
+	}
+	strict := verifier
+	strict.ExpectedIssuers([]string{"Another CA"})
+	named := verifier
+	named.ServerName("www.example.com")
+
+	tests := []struct {
+		name     string
+		verifier CertVerifier
+		res      *CertResult
+		problems []string
+	}{
+		{"valid", verifier, makeCertResult(t, now, "www.example.com", leaf, inter), []string{}},
+		{"expiring", verifier, makeCertResult(t, now, "www.example.com", soon, inter), []string{"expiring"}},
+		{"expired", verifier, makeCertResult(t, now.Add(100*24*time.Hour), "www.example.com", leaf, inter), []string{"unverified", "expired"}},
+		{"not yet valid", verifier, makeCertResult(t, now.Add(-2*time.Hour), "www.example.com", leaf, inter), []string{"unverified", "notyetvalid"}},
+		{"mismatch", verifier, makeCertResult(t, now, "other.example.com", leaf, inter), []string{"unverified", "mismatch"}},
+		{"server name", named, makeCertResult(t, now, "192.0.2.1", leaf, inter), []string{}},
+		{"missing intermediate", verifier, makeCertResult(t, now, "www.example.com", leaf), []string{"unverified"}},
+		{"self-signed", verifier, makeCertResult(t, now, "www.example.com", self), []string{"unverified", "selfsigned"}},
+		{"unexpected issuer", strict, makeCertResult(t, now, "www.example.com", leaf, inter), []string{"unexpectedissuer"}},
+	}
+
+	for _, test := range tests {
+		verdict, err := test.verifier.Verify(test.res)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if problems := verdict.Problems(); !slices.Equal(problems, test.problems) {
+			t.Errorf("%s: expected %v, got %v (%v)", test.name, test.problems, problems, verdict.Error)
+		}
+		if verdict.Verified != (verdict.Error == nil) {
+			t.Errorf("%s: verified is %v but error is %v", test.name, verdict.Verified, verdict.Error)
+		}
+	}
+
+	verdict, _ := verifier.Verify(makeCertResult(t, now, "www.example.com", soon, inter))
+	if verdict.Subject != "www.example.com" || verdict.Issuer != "Test CA" ||
+		!verdict.NotAfter.Equal(soon.NotAfter) || verdict.ExpiresIn != 10*24*time.Hour {
+		t.Errorf("verdict details are wrong: %+v", verdict)
+	}
+	first := makeCertResult(t, now, "www.example.com", leaf, inter).ChainFingerprint()
+	if first == "" || first != makeCertResult(t, now, "x", leaf, inter).ChainFingerprint() ||
+		first == makeCertResult(t, now, "www.example.com", leaf).ChainFingerprint() {
+		t.Errorf("chain fingerprints are wrong")
+	}
+
+	if _, err := verifier.Verify(&CertResult{}); err == nil {
+		t.Error("a result without certificates was verified")
+	}
+	if err := verifier.ReadCABundle(strings.NewReader("nothing")); err == nil {
+		t.Error("an empty CA bundle was accepted")
+	}
+}
@@ -37,19 +37,33 @@ func (conn *ConnectionResult) Parse(from string) (err error) {
 	conn.Event = iconn.Event
 	conn.Controller = iconn.Controller
 	conn.Asn = iconn.Asn
-	conn.Prefix = iconn.Prefix
+	if iconn.Prefix != nil {
+		conn.Prefix = *iconn.Prefix
+	}
 
 	return nil
 }
 
+// MarshalJSON turns the result back into the format used by the API
+func (conn ConnectionResult) MarshalJSON() ([]byte, error) {
+	iconn := connectionResult{conn.BaseResult, conn.Event, conn.Controller, conn.Asn, nil}
+	if conn.Prefix.IsValid() {
+		iconn.Prefix = &conn.Prefix
+	}
+	return json.Marshal(struct {
+		rawBaseResult
+		connectionResult
+	}{conn.BaseResult.raw(), iconn})
+}
+
 //////////////////////////////////////////////////////
-// API version of an uptime result
+// API version of a connection result
 
 // this is the JSON structure as reported by the API
 type connectionResult struct {
 	BaseResult
-	Event      string       `json:"event"`      //
-	Controller string       `json:"controller"` //
-	Asn        uint         `json:"asn"`        //
-	Prefix     netip.Prefix `json:"prefix"`     //
+	Event      string        `json:"event"`                //
+	Controller string        `json:"controller,omitempty"` //
+	Asn        uint          `json:"asn,omitempty"`        //
+	Prefix     *netip.Prefix `json:"prefix,omitempty"`     //
 }
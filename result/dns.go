@@ -28,8 +28,8 @@ type DnsResult struct {
 
 // DnsResponse holds one response from one server/resolver, with all associated data
 // Various bits like counts and answers are stored here in a simple format which
-// is likely a good fit for many use cases; one could look at all the gory details in
-// the AnswerBuf (abuf) if more details are needed
+// is likely a good fit for many use cases; all the gory details are available in
+// QueryMsg and AnswerMsg (parsed from qbuf and abuf) if more details are needed
 type DnsResponse struct {
 	TimeStamp     time.Time      //
 	SourceAddr    netip.Addr     //
@@ -38,6 +38,9 @@ type DnsResponse struct {
 	AddressFamily uint           //
 	Protocol      string         //
 	RetryCount    uint           //
+	LastTimeSync  int            //
+	SubID         uint           //
+	SubMax        uint           //
 	QueryBuf      []byte         //
 	ResponseTime  float64        //
 	ResponseSize  uint           //
@@ -50,6 +53,15 @@ type DnsResponse struct {
 	AdditionalCount uint   //
 	Edsn0Nsid       []byte //
 
+	// EDNS details of the query and the answer, nil if there was no OPT record
+	QueryEdns *DnsEdns //
+	Edns      *DnsEdns //
+
+	// the messages parsed from QueryBuf and AnswerBuf, nil if not present
+	// (or, for the query, if it could not be parsed)
+	QueryMsg  *dns.Msg //
+	AnswerMsg *dns.Msg //
+
 	// various bits
 	Response           bool //
 	Opcode             int  //
@@ -228,6 +240,7 @@ func (dns *DnsResult) Parse(from string) (err error) {
 		if err != nil {
 			return err
 		}
+		de.LastTimeSync = idns.LastTimeSync
 		dns.Responses = append(dns.Responses, de)
 	}
 	for _, rs := range idns.RawResultSet {
@@ -240,25 +253,123 @@ func (dns *DnsResult) Parse(from string) (err error) {
 		if err != nil {
 			return fmt.Errorf("error decoding qbuf: %s", err.Error())
 		}
+		answer := dnsAnswer{}
+		if rs.Answer != nil {
+			answer = *rs.Answer
+		}
 		de, err := makeDnsResponse(
 			time.Time(rs.Time),
-			rs.SourceAddr,
-			netip.AddrPortFrom(rs.DestinationAddr, uint16(dstport)),
+			derefAddr(rs.SourceAddr),
+			netip.AddrPortFrom(derefAddr(rs.DestinationAddr), uint16(dstport)),
 			rs.AddressFamily,
 			rs.Protocol,
 			rs.Error,
 			retrycount,
 			qbuf,
-			rs.Answer,
+			answer,
 		)
 		if err != nil {
 			return err
 		}
+		de.LastTimeSync = rs.LastTimeSync
+		de.SubID = rs.SubID
+		de.SubMax = rs.SubMax
 		dns.Responses = append(dns.Responses, de)
 	}
 	return nil
 }
 
+// MarshalJSON turns the result back into the format used by the API
+func (dns DnsResult) MarshalJSON() ([]byte, error) {
+	idns := dnsResult{BaseResult: dns.BaseResult}
+	if len(dns.Error) > 0 {
+		idns.Error = &dnsError{dns.Error[0].Timeout, dns.Error[0].AddrInfo}
+	}
+
+	if dns.singleResponse() {
+		resp := dns.Responses[0]
+		idns.Protocol = resp.Protocol
+		idns.RetryCount = resp.RetryCount
+		idns.RawQBuf = encodeBuf(resp.QueryBuf)
+		answer := resp.rawAnswer()
+		idns.RawResult = &answer
+		return json.Marshal(struct {
+			rawBaseResult
+			dnsResult
+		}{dns.BaseResult.raw(), idns})
+	}
+
+	for _, resp := range dns.Responses {
+		rs := dnsResponse{
+			Time:            uniTime(resp.TimeStamp),
+			LastTimeSync:    resp.LastTimeSync,
+			SourceAddr:      addrOrNil(resp.SourceAddr),
+			DestinationAddr: addrOrNil(resp.Destination.Addr()),
+			AddressFamily:   resp.AddressFamily,
+			Protocol:        resp.Protocol,
+			SubID:           resp.SubID,
+			SubMax:          resp.SubMax,
+			RawQBuf:         encodeBuf(resp.QueryBuf),
+		}
+		if resp.Destination.Port() != 0 {
+			rs.DestinationPort = strconv.Itoa(int(resp.Destination.Port()))
+		}
+		if resp.RetryCount != 0 {
+			rs.RetryCount = &resp.RetryCount
+		}
+		if len(resp.Error) > 0 {
+			rs.Error = &dnsError{resp.Error[0].Timeout, resp.Error[0].AddrInfo}
+		}
+		if len(resp.Error) == 0 || resp.ResponseTime != 0 || resp.ResponseSize != 0 || len(resp.AnswerBuf) != 0 {
+			answer := resp.rawAnswer()
+			rs.Answer = &answer
+		}
+		idns.RawResultSet = append(idns.RawResultSet, rs)
+	}
+	return json.Marshal(struct {
+		rawBaseResult
+		dnsResult
+	}{dns.BaseResult.raw(), idns})
+}
+
+// singleResponse tells if the result can be represented with a single
+// "result" instead of a "resultset", i.e. its details match the result's
+func (dns *DnsResult) singleResponse() bool {
+	if len(dns.Responses) != 1 || dns.DestinationAddr == nil {
+		return false
+	}
+	resp := dns.Responses[0]
+	if len(resp.Error) != len(dns.Error) || (len(resp.Error) > 0 && resp.Error[0] != dns.Error[0]) {
+		return false
+	}
+	return resp.TimeStamp.Equal(time.Time(dns.TimeStamp)) &&
+		resp.SourceAddr == dns.SourceAddr &&
+		resp.Destination == netip.AddrPortFrom(*dns.DestinationAddr, 53) &&
+		resp.AddressFamily == dns.AddressFamily &&
+		resp.LastTimeSync == dns.LastTimeSync &&
+		resp.SubID == 0 && resp.SubMax == 0
+}
+
+// rawAnswer turns the response details back into the API format
+func (resp *DnsResponse) rawAnswer() dnsAnswer {
+	answer := dnsAnswer{
+		ResponseTime:    resp.ResponseTime,
+		ResponseSize:    resp.ResponseSize,
+		QueryID:         resp.QueryID,
+		AnswerCount:     resp.AnswerCount,
+		QueriesCount:    resp.QueriesCount,
+		NameServerCount: resp.NameServerCount,
+		AdditionalCount: resp.AdditionalCount,
+	}
+	if abuf := encodeBuf(resp.AnswerBuf); abuf != nil {
+		answer.Abuf = *abuf
+	}
+	if resp.Ttl6 != 0 {
+		answer.Ttl6 = &resp.Ttl6
+	}
+	return answer
+}
+
 // Filter filters out the desired class/type answers from all answers
 func (result *DnsResult) Filter(class int, typ int) []DnsAnswer {
 	answers := make([]DnsAnswer, 0)
@@ -304,41 +415,41 @@ func (resp *DnsResponse) AllAnswers() []DnsAnswer {
 // this is the JSON structure as reported by the API
 type dnsResult struct {
 	BaseResult
-	Error        *dnsError     `json:"error"`     //
-	Protocol     string        `json:"proto"`     //
-	RetryCount   uint          `json:"retry"`     //
-	RawQBuf      *string       `json:"qbuf"`      //
-	RawResult    *dnsAnswer    `json:"result"`    //
-	RawResultSet []dnsResponse `json:"resultset"` //
+	Error        *dnsError     `json:"error,omitempty"`     //
+	Protocol     string        `json:"proto,omitempty"`     //
+	RetryCount   uint          `json:"retry,omitempty"`     //
+	RawQBuf      *string       `json:"qbuf,omitempty"`      //
+	RawResult    *dnsAnswer    `json:"result,omitempty"`    //
+	RawResultSet []dnsResponse `json:"resultset,omitempty"` //
 }
 
 type dnsResponse struct {
-	Time            uniTime    `json:"time"`     //
-	LastTimeSync    int        `json:"lts"`      //
-	SourceAddr      netip.Addr `json:"src_addr"` //
-	DestinationAddr netip.Addr `json:"dst_addr"` //
-	DestinationPort string     `json:"dst_port"` //
-	Error           *dnsError  `json:"error"`    //
-	AddressFamily   uint       `json:"af"`       //
-	Protocol        string     `json:"proto"`    //
-	RetryCount      *uint      `json:"retry"`    //
-	SubID           uint       `json:"subid"`    //
-	SubMax          uint       `json:"submax"`   //
-	RawQBuf         *string    `json:"qbuf"`     //
-	Answer          dnsAnswer  `json:"result"`   //
+	Time            uniTime     `json:"time"`               //
+	LastTimeSync    int         `json:"lts"`                //
+	SourceAddr      *netip.Addr `json:"src_addr,omitempty"` //
+	DestinationAddr *netip.Addr `json:"dst_addr,omitempty"` //
+	DestinationPort string      `json:"dst_port,omitempty"` //
+	Error           *dnsError   `json:"error,omitempty"`    //
+	AddressFamily   uint        `json:"af"`                 //
+	Protocol        string      `json:"proto"`              //
+	RetryCount      *uint       `json:"retry,omitempty"`    //
+	SubID           uint        `json:"subid"`              //
+	SubMax          uint        `json:"submax"`             //
+	RawQBuf         *string     `json:"qbuf,omitempty"`     //
+	Answer          *dnsAnswer  `json:"result,omitempty"`   //
 }
 
 type dnsAnswer struct {
-	ResponseTime    float64      `json:"rt"`      //
-	ResponseSize    uint         `json:"size"`    //
-	Abuf            string       `json:"abuf"`    //
-	QueryID         uint         `json:"id"`      //
-	AnswerCount     uint         `json:"ancount"` //
-	QueriesCount    uint         `json:"qdcount"` //
-	NameServerCount uint         `json:"nscount"` //
-	AdditionalCount uint         `json:"arcount"` //
-	ResourceRecords *[]dnsRecord `json:"answers"` //
-	Ttl6            *uint        `json:"ttl"`     //
+	ResponseTime    float64      `json:"rt"`                //
+	ResponseSize    uint         `json:"size"`              //
+	Abuf            string       `json:"abuf,omitempty"`    //
+	QueryID         uint         `json:"id"`                //
+	AnswerCount     uint         `json:"ancount"`           //
+	QueriesCount    uint         `json:"qdcount"`           //
+	NameServerCount uint         `json:"nscount"`           //
+	AdditionalCount uint         `json:"arcount"`           //
+	ResourceRecords *[]dnsRecord `json:"answers,omitempty"` //
+	Ttl6            *uint        `json:"ttl,omitempty"`     //
 }
 
 type dnsRecord struct {
@@ -352,8 +463,8 @@ type dnsRecord struct {
 }
 
 type dnsError struct {
-	Timeout  uint   `json:"timeout"`
-	AddrInfo string `json:"getaddrinfo"`
+	Timeout  uint   `json:"timeout,omitempty"`
+	AddrInfo string `json:"getaddrinfo,omitempty"`
 }
 
 // decode an qbuf or an abuf (from base64 string to []byte) if possible
@@ -371,6 +482,15 @@ func decodeBuf(buf *string) ([]byte, error) {
 	return decoded, nil
 }
 
+// encode a qbuf or an abuf to a base64 string; nil if it's empty
+func encodeBuf(buf []byte) *string {
+	if len(buf) == 0 {
+		return nil
+	}
+	encoded := base64.StdEncoding.EncodeToString(buf)
+	return &encoded
+}
+
 // makeDnsResponse assembles a DnsResponse object
 func makeDnsResponse(
 	timestamp time.Time,
@@ -402,6 +522,15 @@ func makeDnsResponse(
 		de.Ttl6 = *ans.Ttl6
 	}
 
+	// a query that can't be parsed is still kept in QueryBuf
+	if len(qbuf) > 0 {
+		var query dns.Msg
+		if query.Unpack(qbuf) == nil {
+			de.QueryMsg = &query
+			de.QueryEdns = makeDnsEdns(&query)
+		}
+	}
+
 	// in case there was an error reported
 	if dnserror != nil {
 		de.Error = append(de.Error, DnsError{dnserror.Timeout, dnserror.AddrInfo})
@@ -455,13 +584,6 @@ func makeDnsResponse(
 				rdata = rtype.String()
 			case *dns.TXT:
 				rdata = strings.Join(rtype.Txt, ", ")
-			case *dns.OPT:
-				for _, opt := range rtype.Option {
-					switch o := opt.(type) {
-					case *dns.EDNS0_NSID:
-						de.Edsn0Nsid = decodeNsid(o.Nsid)
-					}
-				}
 			}
 			list = append(list,
 				DnsAnswer{
@@ -478,6 +600,11 @@ func makeDnsResponse(
 	de.Answer = makeAnswers(parsed.Answer)
 	de.Ns = makeAnswers(parsed.Ns)
 	de.Extra = makeAnswers(parsed.Extra)
+	de.AnswerMsg = &parsed
+	de.Edns = makeDnsEdns(&parsed)
+	if de.Edns != nil {
+		de.Edsn0Nsid = de.Edns.Nsid
+	}
 
 	de.Response = parsed.Response
 	de.Opcode = parsed.Opcode
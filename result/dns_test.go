@@ -0,0 +1,114 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Test if the query and the answer are parsed, including EDNS options
+func TestDnsMessages(t *testing.T) {
+	query := new(dns.Msg)
+	query.SetQuestion("example.com.", dns.TypeA)
+	query.SetEdns0(1232, true)
+	query.IsEdns0().Option = []dns.EDNS0{
+		&dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("192.0.2.0")},
+		&dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "0102030405060708"},
+		&dns.EDNS0_TCP_KEEPALIVE{Code: dns.EDNS0TCPKEEPALIVE},
+	}
+
+	answer := new(dns.Msg)
+	answer.SetRcode(query, dns.RcodeServerFailure)
+	answer.SetEdns0(4096, false)
+	answer.IsEdns0().Option = []dns.EDNS0{
+		&dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 2, SourceNetmask: 48, SourceScope: 40, Address: net.ParseIP("2001:db8::")},
+		&dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "0102030405060708a1a2a3a4a5a6a7a8"},
+		&dns.EDNS0_EDE{InfoCode: dns.ExtendedErrorCodeDNSKEYMissing, ExtraText: "no key"},
+		&dns.EDNS0_EDE{InfoCode: dns.ExtendedErrorCodeNetworkError},
+		&dns.EDNS0_PADDING{Padding: make([]byte, 12)},
+		&dns.EDNS0_TCP_KEEPALIVE{Code: dns.EDNS0TCPKEEPALIVE, Timeout: 150},
+		&dns.EDNS0_NSID{Code: dns.EDNS0NSID, Nsid: "6e73"},
+		&dns.EDNS0_EXPIRE{Code: dns.EDNS0EXPIRE, Expire: 0x01020304},
+		&dns.EDNS0_LOCAL{Code: 65001, Data: []byte{0xca, 0xfe}},
+	}
+
+	qbuf, _ := query.Pack()
+	abuf, _ := answer.Pack()
+	var res DnsResult
+	err := res.Parse(fmt.Sprintf(`{"fw":5080,"type":"dns","msm_id":3001,"prb_id":1,"timestamp":1700000000,"af":4,"dst_addr":"192.0.2.53","proto":"UDP","qbuf":"%s","result":{"rt":10.5,"size":%d,"abuf":"%s","id":%d,"ancount":0,"qdcount":1,"nscount":0,"arcount":1}}`,
+		base64.StdEncoding.EncodeToString(qbuf), len(abuf), base64.StdEncoding.EncodeToString(abuf), answer.Id))
+	if err != nil {
+		t.Fatalf("parsing failed: %v", err)
+	}
+	resp := res.Responses[0]
+
+	if resp.QueryMsg == nil || resp.QueryMsg.Question[0].Name != "example.com." ||
+		resp.AnswerMsg == nil || resp.AnswerMsg.Rcode != dns.RcodeServerFailure {
+		t.Fatalf("messages were not parsed: %v %v", resp.QueryMsg, resp.AnswerMsg)
+	}
+
+	qedns := resp.QueryEdns
+	if qedns == nil || qedns.UDPSize != 1232 || !qedns.DnssecOK ||
+		qedns.ClientSubnet.Prefix != netip.MustParsePrefix("192.0.2.0/24") ||
+		!bytes.Equal(qedns.Cookie.Client, []byte{1, 2, 3, 4, 5, 6, 7, 8}) || qedns.Cookie.Server != nil ||
+		qedns.Keepalive == nil || *qedns.Keepalive != 0 {
+		t.Errorf("query EDNS is wrong: %+v", qedns)
+	}
+
+	edns := resp.Edns
+	if edns == nil || edns.UDPSize != 4096 || edns.DnssecOK {
+		t.Fatalf("answer EDNS is wrong: %+v", edns)
+	}
+	if edns.ClientSubnet.Prefix != netip.MustParsePrefix("2001:db8::/48") || edns.ClientSubnet.Scope != 40 {
+		t.Errorf("client subnet is wrong: %+v", edns.ClientSubnet)
+	}
+	if len(edns.Cookie.Server) != 8 || edns.Cookie.Server[0] != 0xa1 {
+		t.Errorf("cookie is wrong: %+v", edns.Cookie)
+	}
+	if len(edns.ExtendedErrors) != 2 || edns.ExtendedErrors[0].InfoCode != 9 ||
+		edns.ExtendedErrors[0].ExtraText != "no key" || edns.ExtendedErrors[1].String() != "Network Error" {
+		t.Errorf("extended errors are wrong: %+v", edns.ExtendedErrors)
+	}
+	if edns.Padding == nil || *edns.Padding != 12 || edns.Keepalive == nil || *edns.Keepalive != 15*time.Second {
+		t.Errorf("padding or keepalive is wrong: %v %v", edns.Padding, edns.Keepalive)
+	}
+	if string(edns.Nsid) != "ns" || string(resp.Edsn0Nsid) != "ns" {
+		t.Errorf("NSID is wrong: %q", edns.Nsid)
+	}
+	if len(edns.Options) != 2 ||
+		edns.Options[0].Code != dns.EDNS0EXPIRE || !bytes.Equal(edns.Options[0].Data, []byte{1, 2, 3, 4}) ||
+		edns.Options[1].Code != 65001 || !bytes.Equal(edns.Options[1].Data, []byte{0xca, 0xfe}) {
+		t.Errorf("other options are wrong: %+v", edns.Options)
+	}
+}
+
+// Test if a query that can't be parsed doesn't fail the result
+func TestDnsMalformedQuery(t *testing.T) {
+	query := new(dns.Msg)
+	query.SetQuestion("example.com.", dns.TypeA)
+	qbuf, _ := query.Pack()
+	qbuf = qbuf[:len(qbuf)-3] // truncated question
+
+	var res DnsResult
+	err := res.Parse(fmt.Sprintf(`{"fw":5080,"type":"dns","msm_id":3001,"prb_id":1,"timestamp":1700000000,"af":4,"dst_addr":"192.0.2.53","proto":"UDP","qbuf":"%s","result":{"rt":10.5,"size":0,"id":1,"ancount":0,"qdcount":1,"nscount":0,"arcount":0}}`,
+		base64.StdEncoding.EncodeToString(qbuf)))
+	if err != nil {
+		t.Fatalf("parsing failed: %v", err)
+	}
+	resp := res.Responses[0]
+	if resp.QueryMsg != nil || resp.QueryEdns != nil || !bytes.Equal(resp.QueryBuf, qbuf) {
+		t.Errorf("malformed query was not kept as is: %+v", resp)
+	}
+}
@@ -0,0 +1,315 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DnssecStatus is the outcome of validating a DNS response
+type DnssecStatus int
+
+const (
+	DnssecIndeterminate DnssecStatus = iota // there's nothing (or not enough) to check
+	DnssecSecure                            // all records are signed properly by trusted keys
+	DnssecInsecure                          // (some) records are not covered by a trust anchor
+	DnssecBogus                             // signatures are missing, expired or invalid
+)
+
+// DnssecStatusNames translates validation outcomes to their names
+var DnssecStatusNames = map[DnssecStatus]string{
+	DnssecIndeterminate: "indeterminate",
+	DnssecSecure:        "secure",
+	DnssecInsecure:      "insecure",
+	DnssecBogus:         "bogus",
+}
+
+func (status DnssecStatus) String() string {
+	return DnssecStatusNames[status]
+}
+
+// DnssecVerdict is the outcome of validating a DNS response, and the reason
+// for it unless it's secure
+type DnssecVerdict struct {
+	Status DnssecStatus
+	Reason string
+}
+
+// DnssecValidator checks the signatures in DNS responses against trust
+// anchors (DS or DNSKEY records). Responses rarely contain the whole chain
+// of trust, so the anchors should be as close to the names in question
+// as possible: records that are not at or below any of the anchors are
+// considered insecure.
+type DnssecValidator struct {
+	anchors []*dns.DS     // trusted DS records
+	keys    []*dns.DNSKEY // trusted keys
+}
+
+// rrsetKey identifies an RRset in a message
+type rrsetKey struct {
+	name  string
+	typ   uint16
+	class uint16
+}
+
+// NewDnssecValidator returns a validator without any trust anchors
+func NewDnssecValidator() DnssecValidator {
+	return DnssecValidator{
+		anchors: make([]*dns.DS, 0),
+		keys:    make([]*dns.DNSKEY, 0),
+	}
+}
+
+// AddTrustAnchor adds a DS or a DNSKEY record as a trust anchor
+func (validator *DnssecValidator) AddTrustAnchor(rr dns.RR) error {
+	switch anchor := rr.(type) {
+	case *dns.DS:
+		validator.anchors = append(validator.anchors, anchor)
+	case *dns.DNSKEY:
+		validator.keys = append(validator.keys, anchor)
+	default:
+		return fmt.Errorf("trust anchor has to be a DS or a DNSKEY record, not %s",
+			dns.TypeToString[rr.Header().Rrtype])
+	}
+	return nil
+}
+
+// ReadTrustAnchors reads trust anchors (DS and DNSKEY records) in zone file
+// format. Other records are ignored.
+func (validator *DnssecValidator) ReadTrustAnchors(input io.Reader) error {
+	parser := dns.NewZoneParser(input, ".", "")
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		switch rr.(type) {
+		case *dns.DS, *dns.DNSKEY:
+			validator.AddTrustAnchor(rr)
+		}
+	}
+	if err := parser.Err(); err != nil {
+		return fmt.Errorf("error reading trust anchors: %v", err)
+	}
+	if len(validator.anchors) == 0 && len(validator.keys) == 0 {
+		return fmt.Errorf("no trust anchors found")
+	}
+	return nil
+}
+
+// Validate validates all responses of a result
+func (validator *DnssecValidator) Validate(res *DnsResult) []DnssecVerdict {
+	verdicts := make([]DnssecVerdict, 0, len(res.Responses))
+	for _, resp := range res.Responses {
+		verdicts = append(verdicts, validator.ValidateResponse(&resp))
+	}
+	return verdicts
+}
+
+// ValidateResponse validates the records of the answer section, or in
+// case of a negative answer the SOA and NSEC(3) records of the authority
+// section, at the time the response was received. Denial of existence
+// proofs are checked for valid signatures, but not for coverage.
+func (validator *DnssecValidator) ValidateResponse(resp *DnsResponse) DnssecVerdict {
+	msg := resp.AnswerMsg
+	switch {
+	case msg == nil:
+		return DnssecVerdict{DnssecIndeterminate, "no answer"}
+	case resp.QueryMsg != nil && (resp.QueryEdns == nil || !resp.QueryEdns.DnssecOK):
+		return DnssecVerdict{DnssecIndeterminate, "DO bit was not set in the query"}
+	case msg.Truncated:
+		return DnssecVerdict{DnssecIndeterminate, "truncated answer"}
+	case msg.Rcode != dns.RcodeSuccess && msg.Rcode != dns.RcodeNameError:
+		return DnssecVerdict{DnssecIndeterminate, "rcode " + dns.RcodeToString[msg.Rcode]}
+	}
+
+	records := msg.Answer
+	if len(records) == 0 {
+		records = slices.DeleteFunc(slices.Clone(msg.Ns), func(rr dns.RR) bool {
+			switch rr.Header().Rrtype {
+			case dns.TypeSOA, dns.TypeNSEC, dns.TypeNSEC3, dns.TypeRRSIG:
+				return false
+			}
+			return true
+		})
+	}
+	sets, sigs := makeRRsets(records)
+	if len(sets) == 0 {
+		return DnssecVerdict{DnssecIndeterminate, "no records to validate"}
+	}
+
+	// check the sets in the order they appear, so the reason is stable
+	keys := validator.trustedKeys(sets, sigs, resp.TimeStamp)
+	verdicts := make([]DnssecVerdict, 0, len(sets))
+	for _, rr := range records {
+		key := makeRRsetKey(rr)
+		set, ok := sets[key]
+		if !ok {
+			continue
+		}
+		delete(sets, key)
+		if !validator.covered(key.name) {
+			verdicts = append(verdicts, DnssecVerdict{DnssecInsecure,
+				fmt.Sprintf("%s is not covered by a trust anchor", key.name)})
+			continue
+		}
+		verdicts = append(verdicts, verifyRRset(set, sigs[key], keys, resp.TimeStamp))
+	}
+
+	// bogus trumps everything, then the lack of information, then insecure
+	for _, status := range []DnssecStatus{DnssecBogus, DnssecIndeterminate, DnssecInsecure} {
+		for _, verdict := range verdicts {
+			if verdict.Status == status {
+				return verdict
+			}
+		}
+	}
+	return DnssecVerdict{DnssecSecure, ""}
+}
+
+// covered tells if a name is at or below any of the trust anchors
+func (validator *DnssecValidator) covered(name string) bool {
+	for _, ds := range validator.anchors {
+		if dns.IsSubDomain(ds.Hdr.Name, name) {
+			return true
+		}
+	}
+	for _, key := range validator.keys {
+		if dns.IsSubDomain(key.Hdr.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// trustedKeys returns the configured keys and the ones that can be
+// validated in the response itself, via trusted DS records or keys
+func (validator *DnssecValidator) trustedKeys(
+	sets map[rrsetKey][]dns.RR,
+	sigs map[rrsetKey][]*dns.RRSIG,
+	when time.Time,
+) []*dns.DNSKEY {
+	keys := slices.Clone(validator.keys)
+	anchors := slices.Clone(validator.anchors)
+	done := make(map[rrsetKey]bool)
+
+	for changed := true; changed; {
+		changed = false
+		for key, set := range sets {
+			if done[key] {
+				continue
+			}
+			switch key.typ {
+			case dns.TypeDS:
+				if verifyRRset(set, sigs[key], keys, when).Status != DnssecSecure {
+					continue
+				}
+				for _, rr := range set {
+					anchors = append(anchors, rr.(*dns.DS))
+				}
+			case dns.TypeDNSKEY:
+				// the set has to be signed by a key that is trusted already
+				// or matches a trusted DS
+				signers := slices.Clone(keys)
+				for _, rr := range set {
+					if matchesDS(rr.(*dns.DNSKEY), anchors) {
+						signers = append(signers, rr.(*dns.DNSKEY))
+					}
+				}
+				if verifyRRset(set, sigs[key], signers, when).Status != DnssecSecure {
+					continue
+				}
+				for _, rr := range set {
+					keys = append(keys, rr.(*dns.DNSKEY))
+				}
+			default:
+				continue
+			}
+			done[key] = true
+			changed = true
+		}
+	}
+	return keys
+}
+
+// matchesDS tells if a key matches any of the DS records
+func matchesDS(key *dns.DNSKEY, anchors []*dns.DS) bool {
+	for _, ds := range anchors {
+		if !strings.EqualFold(ds.Hdr.Name, key.Hdr.Name) ||
+			ds.KeyTag != key.KeyTag() || ds.Algorithm != key.Algorithm {
+			continue
+		}
+		if digest := key.ToDS(ds.DigestType); digest != nil && strings.EqualFold(digest.Digest, ds.Digest) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyRRset checks if any of the signatures over an RRset is valid and
+// made by one of the keys. If none is, but some were made by unknown keys,
+// then the outcome is indeterminate.
+func verifyRRset(set []dns.RR, sigs []*dns.RRSIG, keys []*dns.DNSKEY, when time.Time) DnssecVerdict {
+	header := set[0].Header()
+	what := header.Name + " " + dns.TypeToString[header.Rrtype]
+	if len(sigs) == 0 {
+		return DnssecVerdict{DnssecBogus, "no signature for " + what}
+	}
+
+	unknown := ""
+	reason := ""
+	for _, sig := range sigs {
+		signers := slices.DeleteFunc(slices.Clone(keys), func(key *dns.DNSKEY) bool {
+			return key.KeyTag() != sig.KeyTag || key.Algorithm != sig.Algorithm ||
+				!strings.EqualFold(key.Hdr.Name, sig.SignerName)
+		})
+		if len(signers) == 0 {
+			unknown = fmt.Sprintf("no trusted key %s/%d for %s", sig.SignerName, sig.KeyTag, what)
+			continue
+		}
+		if !sig.ValidityPeriod(when) {
+			reason = fmt.Sprintf("signature of %s by %s/%d is not valid at %v (%s - %s)",
+				what, sig.SignerName, sig.KeyTag, when.UTC().Format(time.RFC3339),
+				dns.TimeToString(sig.Inception), dns.TimeToString(sig.Expiration))
+			continue
+		}
+		for _, key := range signers {
+			if err := sig.Verify(key, set); err == nil {
+				return DnssecVerdict{DnssecSecure, ""}
+			}
+		}
+		reason = fmt.Sprintf("signature of %s by %s/%d does not verify", what, sig.SignerName, sig.KeyTag)
+	}
+
+	if unknown != "" {
+		return DnssecVerdict{DnssecIndeterminate, unknown}
+	}
+	return DnssecVerdict{DnssecBogus, reason}
+}
+
+// makeRRsets groups records into RRsets, and signatures by what they cover
+func makeRRsets(records []dns.RR) (map[rrsetKey][]dns.RR, map[rrsetKey][]*dns.RRSIG) {
+	sets := make(map[rrsetKey][]dns.RR)
+	sigs := make(map[rrsetKey][]*dns.RRSIG)
+	for _, rr := range records {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			key := rrsetKey{strings.ToLower(sig.Hdr.Name), sig.TypeCovered, sig.Hdr.Class}
+			sigs[key] = append(sigs[key], sig)
+			continue
+		}
+		key := makeRRsetKey(rr)
+		sets[key] = append(sets[key], rr)
+	}
+	return sets, sigs
+}
+
+func makeRRsetKey(rr dns.RR) rrsetKey {
+	header := rr.Header()
+	return rrsetKey{strings.ToLower(header.Name), header.Rrtype, header.Class}
+}
@@ -0,0 +1,183 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"crypto"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// a signed zone to make responses from
+type testZone struct {
+	name string
+	ksk  *dns.DNSKEY
+	zsk  *dns.DNSKEY
+	kpk  crypto.Signer
+	zpk  crypto.Signer
+}
+
+func newTestZone(t *testing.T, name string) *testZone {
+	zone := &testZone{name: name}
+	makeKey := func(flags uint16) (*dns.DNSKEY, crypto.Signer) {
+		key := &dns.DNSKEY{
+			Hdr:       dns.RR_Header{Name: name, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+			Flags:     flags,
+			Protocol:  3,
+			Algorithm: dns.ECDSAP256SHA256,
+		}
+		private, err := key.Generate(256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key, private.(crypto.Signer)
+	}
+	zone.ksk, zone.kpk = makeKey(257)
+	zone.zsk, zone.zpk = makeKey(256)
+	return zone
+}
+
+// sign an RRset with a key, valid between the times
+func (zone *testZone) sign(t *testing.T, ksk bool, from, until time.Time, rrs ...dns.RR) *dns.RRSIG {
+	key, private := zone.zsk, zone.zpk
+	if ksk {
+		key, private = zone.ksk, zone.kpk
+	}
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Ttl: rrs[0].Header().Ttl},
+		Algorithm:  key.Algorithm,
+		SignerName: zone.name,
+		KeyTag:     key.KeyTag(),
+		Inception:  uint32(from.Unix()),
+		Expiration: uint32(until.Unix()),
+	}
+	if err := sig.Sign(private, rrs); err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// make a DNS result with this answer to the query
+func makeDnssecResult(t *testing.T, when time.Time, do bool, answer []dns.RR, ns []dns.RR) *DnsResult {
+	query := new(dns.Msg)
+	query.SetQuestion(answer[0].Header().Name, answer[0].Header().Rrtype)
+	query.SetEdns0(1232, do)
+	reply := new(dns.Msg)
+	reply.SetReply(query)
+	reply.Answer = answer
+	reply.Ns = ns
+	if ns != nil {
+		reply.Answer = nil
+		reply.Rcode = dns.RcodeNameError
+	}
+
+	qbuf, _ := query.Pack()
+	abuf, err := reply.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res DnsResult
+	err = res.Parse(fmt.Sprintf(`{"fw":5080,"type":"dns","msm_id":3001,"prb_id":1,"timestamp":%d,"af":4,"dst_addr":"192.0.2.53","proto":"UDP","qbuf":"%s","result":{"rt":10.5,"size":%d,"abuf":"%s","id":%d}}`,
+		when.Unix(), base64.StdEncoding.EncodeToString(qbuf), len(abuf), base64.StdEncoding.EncodeToString(abuf), reply.Id))
+	if err != nil {
+		t.Fatalf("parsing failed: %v", err)
+	}
+	return &res
+}
+
+// Test if responses are classified properly
+func TestDnssecValidation(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	before, after := now.Add(-24*time.Hour), now.Add(24*time.Hour)
+	zone := newTestZone(t, "example.com.")
+	other := newTestZone(t, "example.net.")
+
+	a, _ := dns.NewRR("www.example.com. 300 IN A 192.0.2.1")
+	forged, _ := dns.NewRR("www.example.com. 300 IN A 192.0.2.66")
+	unsigned, _ := dns.NewRR("www.example.org. 300 IN A 192.0.2.2")
+	soa, _ := dns.NewRR("example.com. 300 IN SOA ns.example.com. admin.example.com. 1 7200 3600 1209600 300")
+	nsec, _ := dns.NewRR("example.com. 300 IN NSEC zzz.example.com. A NS SOA RRSIG NSEC DNSKEY")
+
+	// anchored with the DNSKEY of the zone
+	keyAnchored := NewDnssecValidator()
+	keyAnchored.AddTrustAnchor(zone.zsk)
+
+	// anchored with a DS of the KSK: only DNSKEY responses can be secure
+	dsAnchored := NewDnssecValidator()
+	if err := dsAnchored.ReadTrustAnchors(strings.NewReader(zone.ksk.ToDS(dns.SHA256).String() + "\n")); err != nil {
+		t.Fatalf("reading trust anchors failed: %v", err)
+	}
+	keys := []dns.RR{zone.ksk, zone.zsk}
+
+	tests := []struct {
+		name      string
+		validator DnssecValidator
+		res       *DnsResult
+		status    DnssecStatus
+	}{
+		{"secure", keyAnchored,
+			makeDnssecResult(t, now, true, []dns.RR{a, zone.sign(t, false, before, after, a)}, nil), DnssecSecure},
+		{"stripped", keyAnchored,
+			makeDnssecResult(t, now, true, []dns.RR{a}, nil), DnssecBogus},
+		{"expired", keyAnchored,
+			makeDnssecResult(t, now, true, []dns.RR{a, zone.sign(t, false, before.Add(-time.Hour), before, a)}, nil), DnssecBogus},
+		{"not yet valid", keyAnchored,
+			makeDnssecResult(t, now, true, []dns.RR{a, zone.sign(t, false, after, after.Add(time.Hour), a)}, nil), DnssecBogus},
+		{"forged", keyAnchored,
+			makeDnssecResult(t, now, true, []dns.RR{forged, zone.sign(t, false, before, after, a)}, nil), DnssecBogus},
+		{"unknown key", keyAnchored,
+			makeDnssecResult(t, now, true, []dns.RR{a, zone.sign(t, true, before, after, a)}, nil), DnssecIndeterminate},
+		{"unanchored", keyAnchored,
+			makeDnssecResult(t, now, true, []dns.RR{unsigned}, nil), DnssecInsecure},
+		{"mixed", keyAnchored,
+			makeDnssecResult(t, now, true, []dns.RR{a, zone.sign(t, false, before, after, a), unsigned}, nil), DnssecInsecure},
+		{"no DO bit", keyAnchored,
+			makeDnssecResult(t, now, false, []dns.RR{a}, nil), DnssecIndeterminate},
+		{"negative", keyAnchored,
+			makeDnssecResult(t, now, true, []dns.RR{a}, []dns.RR{soa, zone.sign(t, false, before, after, soa), nsec, zone.sign(t, false, before, after, nsec)}), DnssecSecure},
+		{"negative unsigned", keyAnchored,
+			makeDnssecResult(t, now, true, []dns.RR{a}, []dns.RR{soa, nsec}), DnssecBogus},
+		{"DNSKEY via DS", dsAnchored,
+			makeDnssecResult(t, now, true, append(keys, zone.sign(t, true, before, after, keys...)), nil), DnssecSecure},
+		{"DNSKEY via DS, wrong signer", dsAnchored,
+			makeDnssecResult(t, now, true, append(keys, zone.sign(t, false, before, after, keys...)), nil), DnssecIndeterminate},
+		{"other zone's key", keyAnchored,
+			makeDnssecResult(t, now, true, []dns.RR{a, other.sign(t, false, before, after, a)}, nil), DnssecIndeterminate},
+	}
+
+	for _, test := range tests {
+		verdicts := test.validator.Validate(test.res)
+		if len(verdicts) != 1 {
+			t.Fatalf("%s: expected 1 verdict, got %d", test.name, len(verdicts))
+		}
+		if verdicts[0].Status != test.status {
+			t.Errorf("%s: expected %s, got %s (%s)", test.name, test.status, verdicts[0].Status, verdicts[0].Reason)
+		}
+		if (verdicts[0].Status == DnssecSecure) != (verdicts[0].Reason == "") {
+			t.Errorf("%s: unexpected reason %q", test.name, verdicts[0].Reason)
+		}
+	}
+
+	var failed DnsResult
+	failed.Parse(`{"fw":5080,"type":"dns","msm_id":3001,"prb_id":1,"timestamp":1700000000,"resultset":[{"time":1700000000,"lts":1,"af":4,"dst_addr":"192.0.2.53","proto":"UDP","subid":1,"submax":1,"error":{"timeout":5000}}]}`)
+	if verdicts := keyAnchored.Validate(&failed); len(verdicts) != 1 || verdicts[0].Status != DnssecIndeterminate {
+		t.Errorf("timeout: expected indeterminate, got %v", verdicts)
+	}
+
+	validator := NewDnssecValidator()
+	if err := validator.AddTrustAnchor(a); err == nil {
+		t.Error("an A record was accepted as a trust anchor")
+	}
+	if err := validator.ReadTrustAnchors(strings.NewReader("example.com. 300 IN A 192.0.2.1\n")); err == nil {
+		t.Error("trust anchors without DS or DNSKEY records were accepted")
+	}
+}
@@ -0,0 +1,128 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"encoding/hex"
+	"net/netip"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DnsEdns holds the details of the OPT record of a DNS message
+type DnsEdns struct {
+	Version        uint8              //
+	UDPSize        uint16             //
+	DnssecOK       bool               //
+	ExtendedRcode  int                //
+	Nsid           []byte             //
+	ClientSubnet   *DnsClientSubnet   // EDNS client subnet (RFC 7871)
+	Cookie         *DnsCookie         // DNS cookies (RFC 7873)
+	ExtendedErrors []DnsExtendedError // extended DNS errors (RFC 8914)
+	Padding        *int               // length of padding (RFC 7830)
+	Keepalive      *time.Duration     // TCP keepalive idle timeout (RFC 7828)
+	Options        []DnsEdnsOption    // any other options
+}
+
+// DnsClientSubnet is an EDNS client subnet option
+type DnsClientSubnet struct {
+	Prefix netip.Prefix // address and source prefix length
+	Scope  uint8        // scope prefix length
+}
+
+// DnsCookie is an EDNS cookie option
+type DnsCookie struct {
+	Client []byte // 8 bytes
+	Server []byte // 8 to 32 bytes, if any
+}
+
+// DnsExtendedError is an extended DNS error option
+type DnsExtendedError struct {
+	InfoCode  uint16 //
+	ExtraText string //
+}
+
+// DnsEdnsOption is an EDNS option not known otherwise
+type DnsEdnsOption struct {
+	Code uint16
+	Data []byte
+}
+
+// String returns the name of the info code of the error
+func (ede DnsExtendedError) String() string {
+	if name, ok := dns.ExtendedErrorCodeToString[ede.InfoCode]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// makeDnsEdns decodes the OPT record of a message; nil if there's none
+func makeDnsEdns(msg *dns.Msg) *DnsEdns {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+
+	edns := &DnsEdns{
+		Version:       opt.Version(),
+		UDPSize:       opt.UDPSize(),
+		DnssecOK:      opt.Do(),
+		ExtendedRcode: opt.ExtendedRcode(),
+	}
+	for _, option := range opt.Option {
+		switch o := option.(type) {
+		case *dns.EDNS0_NSID:
+			edns.Nsid = decodeNsid(o.Nsid)
+		case *dns.EDNS0_SUBNET:
+			addr, ok := netip.AddrFromSlice(o.Address)
+			if !ok {
+				continue
+			}
+			if o.Family == 1 {
+				addr = addr.Unmap()
+			}
+			edns.ClientSubnet = &DnsClientSubnet{
+				netip.PrefixFrom(addr, int(o.SourceNetmask)),
+				o.SourceScope,
+			}
+		case *dns.EDNS0_COOKIE:
+			cookie, err := hex.DecodeString(o.Cookie)
+			if err != nil || len(cookie) < 8 {
+				continue
+			}
+			edns.Cookie = &DnsCookie{Client: cookie[:8]}
+			if len(cookie) > 8 {
+				edns.Cookie.Server = cookie[8:]
+			}
+		case *dns.EDNS0_EDE:
+			edns.ExtendedErrors = append(edns.ExtendedErrors, DnsExtendedError{o.InfoCode, o.ExtraText})
+		case *dns.EDNS0_PADDING:
+			length := len(o.Padding)
+			edns.Padding = &length
+		case *dns.EDNS0_TCP_KEEPALIVE:
+			timeout := time.Duration(o.Timeout) * 100 * time.Millisecond
+			edns.Keepalive = &timeout
+		case *dns.EDNS0_LOCAL:
+			edns.Options = append(edns.Options, DnsEdnsOption{o.Code, o.Data})
+		default:
+			// known to the library, but not interesting enough to have
+			// its own field here; keep the raw data
+			packed := new(dns.OPT)
+			packed.Hdr = dns.RR_Header{Name: ".", Rrtype: dns.TypeOPT}
+			packed.Option = []dns.EDNS0{o}
+			buf := make([]byte, dns.Len(packed))
+			n, err := dns.PackRR(packed, buf, 0, nil, false)
+			if err != nil || n < 15 {
+				continue
+			}
+			// skip name, type, class, ttl, rdlength, code and length
+			edns.Options = append(edns.Options, DnsEdnsOption{o.Option(), buf[15:n]})
+		}
+	}
+	return edns
+}
@@ -9,12 +9,20 @@ package result
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/netip"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type HttpResult struct {
 	BaseResult
-	Uri             string   //
+	Uri              string         //
+	Responses        []HttpResponse // all responses (sub-requests)
+	ResultSourceAddr netip.Addr     // source address given for the result itself, not a response (e.g. if resolving failed)
+
+	// the following are details of the first response, kept for compatibility
 	HeaderSize      uint     //
 	Headers         []string //
 	BodySize        uint     //
@@ -26,18 +34,58 @@ type HttpResult struct {
 	TimeToFirstByte float64  //
 	DnsError        string   //
 	Error           string   //
+}
+
+// HttpResponse is one response (sub-request) of an HTTP result
+type HttpResponse struct {
+	SourceAddr      netip.Addr       //
+	DestinationAddr netip.Addr       //
+	AddressFamily   uint             //
+	Method          string           //
+	Version         string           //
+	ResultCode      uint             //
+	HeaderSize      uint             //
+	BodySize        uint             //
+	Headers         []string         // header lines as received (if asked for)
+	Header          http.Header      // parsed headers, keys are canonicalised
+	ReplyTime       float64          // total time (msec)
+	TimeToResolve   float64          // extended timing, 0 if not measured (msec)
+	TimeToConnect   float64          // extended timing, 0 if not measured (msec)
+	TimeToFirstByte float64          // extended timing, 0 if not measured (msec)
+	ReadTiming      []HttpReadTiming // more extended timing, if measured
+	SubID           uint             // sequence number of this sub-request, if any
+	SubMax          uint             // number of sub-requests, if any
+	Time            time.Time        // start of this sub-request, if given
+	DnsError        string           //
+	Error           string           //
+}
 
-	//ReadTiming *HttpReadTiming `json:"readtiming"` //
-	//SubID         *uint               //
-	//SubMax        *uint           //
-	//Time *uniTime `json:"time"` //
+// HttpReadTiming is a chunk of the response body as it was read
+type HttpReadTiming struct {
+	Offset uint    // offset in the response
+	Time   float64 // time since starting to connect (msec)
 }
 
 func (result *HttpResult) TypeName() string {
 	return "http"
 }
 
-func (http *HttpResult) Parse(from string) (err error) {
+// HasExtendedTiming tells if extended timing (time to resolve, to connect
+// and to first byte) is available for this response
+func (resp *HttpResponse) HasExtendedTiming() bool {
+	return resp.TimeToResolve != 0 || resp.TimeToConnect != 0 || resp.TimeToFirstByte != 0
+}
+
+// TimeToRead returns the time between receiving the first and the last
+// chunk of the response (msec), if more extended timing is available
+func (resp *HttpResponse) TimeToRead() float64 {
+	if len(resp.ReadTiming) == 0 {
+		return 0
+	}
+	return resp.ReadTiming[len(resp.ReadTiming)-1].Time - resp.ReadTiming[0].Time
+}
+
+func (res *HttpResult) Parse(from string) (err error) {
 	var ihttp httpResult
 	err = json.Unmarshal([]byte(from), &ihttp)
 	if err != nil {
@@ -47,38 +95,193 @@ func (http *HttpResult) Parse(from string) (err error) {
 		return fmt.Errorf("this is not a HTTP result (type=%s)", ihttp.Type)
 	}
 
-	http.BaseResult = ihttp.BaseResult
-	http.Uri = ihttp.Uri
-	if len(ihttp.RawHttpReply) >= 1 {
-		// only deal with response 1 for now
+	res.BaseResult = ihttp.BaseResult
+	res.ResultSourceAddr = ihttp.SourceAddr
+	res.Uri = ihttp.Uri
+	res.Responses = make([]HttpResponse, 0, len(ihttp.RawHttpReply))
+	for _, raw := range ihttp.RawHttpReply {
+		res.Responses = append(res.Responses, raw.parse())
+	}
 
-		resp := ihttp.RawHttpReply[0]
+	if len(res.Responses) >= 1 {
+		resp := res.Responses[0]
 		// these are not in the common attribute set for HTTP
 		// so fill them in here instead
-		http.SourceAddr = resp.SourceAddr
-		http.DestinationAddr = &resp.DestinationAddr
-		http.AddressFamily = resp.AddressFamily
+		res.SourceAddr = resp.SourceAddr
+		res.DestinationAddr = &resp.DestinationAddr
+		res.AddressFamily = resp.AddressFamily
 
-		if resp.Headers != nil {
-			http.Headers = *resp.Headers
-		}
-		http.HeaderSize = resp.HeaderSize
-		http.BodySize = resp.BodySize
-		http.Method = resp.Method
-		http.Version = resp.Version
-		http.ResultCode = resp.ResultCode
-		http.ReplyTime = resp.ReplyTime
-		http.TimeToConnect = resp.TimeToConnect
-		http.TimeToFirstByte = resp.TimeToFirstByte
-		if resp.DnsError != nil {
-			http.DnsError = *resp.DnsError
+		res.Headers = resp.Headers
+		res.HeaderSize = resp.HeaderSize
+		res.BodySize = resp.BodySize
+		res.Method = resp.Method
+		res.Version = resp.Version
+		res.ResultCode = resp.ResultCode
+		res.ReplyTime = resp.ReplyTime
+		res.TimeToConnect = resp.TimeToConnect
+		res.TimeToFirstByte = resp.TimeToFirstByte
+		res.DnsError = resp.DnsError
+		res.Error = resp.Error
+	}
+
+	return nil
+}
+
+// parse turns the API version of a response into an HttpResponse
+func (raw *rawHttpReply) parse() HttpResponse {
+	resp := HttpResponse{
+		SourceAddr:      derefAddr(raw.SourceAddr),
+		DestinationAddr: derefAddr(raw.DestinationAddr),
+		TimeToResolve:   raw.TimeToResolve,
+		TimeToConnect:   raw.TimeToConnect,
+		TimeToFirstByte: raw.TimeToFirstByte,
+	}
+	if raw.AddressFamily != nil {
+		resp.AddressFamily = *raw.AddressFamily
+	}
+	if raw.Method != nil {
+		resp.Method = *raw.Method
+	}
+	if raw.Version != nil {
+		resp.Version = *raw.Version
+	}
+	if raw.ResultCode != nil {
+		resp.ResultCode = *raw.ResultCode
+	}
+	if raw.HeaderSize != nil {
+		resp.HeaderSize = *raw.HeaderSize
+	}
+	if raw.BodySize != nil {
+		resp.BodySize = *raw.BodySize
+	}
+	if raw.ReplyTime != nil {
+		resp.ReplyTime = *raw.ReplyTime
+	}
+	if raw.Headers != nil {
+		resp.Headers = *raw.Headers
+		resp.Header = parseHttpHeaders(resp.Headers)
+	}
+	for _, rt := range raw.ReadTiming {
+		resp.ReadTiming = append(resp.ReadTiming, HttpReadTiming{uint(rt.Offset), rt.Time})
+	}
+	if raw.SubID != nil {
+		resp.SubID = *raw.SubID
+	}
+	if raw.SubMax != nil {
+		resp.SubMax = *raw.SubMax
+	}
+	if raw.Time != nil {
+		resp.Time = time.Time(*raw.Time)
+	}
+	if raw.DnsError != nil {
+		resp.DnsError = *raw.DnsError
+	}
+	if raw.Error != nil {
+		resp.Error = *raw.Error
+	}
+	return resp
+}
+
+// parseHttpHeaders turns header lines into a multimap. The status line
+// and anything else that doesn't look like a header is skipped, folded
+// (continuation) lines are appended to the previous header.
+func parseHttpHeaders(lines []string) http.Header {
+	header := make(http.Header)
+	last := ""
+	for _, line := range lines {
+		if last != "" && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			values := header[last]
+			values[len(values)-1] += " " + strings.TrimSpace(line)
+			continue
 		}
-		if resp.Error != nil {
-			http.Error = *resp.Error
+		name, value, found := strings.Cut(line, ":")
+		if !found || name == "" || strings.ContainsAny(name, " \t") {
+			last = ""
+			continue
 		}
+		last = http.CanonicalHeaderKey(name)
+		header.Add(last, strings.TrimSpace(value))
 	}
+	return header
+}
 
-	return nil
+// MarshalJSON turns the result back into the format used by the API
+func (res HttpResult) MarshalJSON() ([]byte, error) {
+	ihttp := httpResult{
+		BaseResult:   res.BaseResult,
+		Uri:          res.Uri,
+		RawHttpReply: make([]rawHttpReply, 0, len(res.Responses)),
+	}
+	for _, resp := range res.Responses {
+		ihttp.RawHttpReply = append(ihttp.RawHttpReply, resp.raw())
+	}
+
+	// the addresses and the address family are filled in from the first
+	// response when parsing, the API doesn't have them at this level
+	base := res.BaseResult.raw()
+	base.SourceAddr = addrOrNil(res.ResultSourceAddr)
+	base.DestinationAddr = nil
+	base.AddressFamily = 0
+
+	return json.Marshal(struct {
+		rawBaseResult
+		httpResult
+	}{base, ihttp})
+}
+
+// raw turns the response back into the API's format. Responses that failed
+// (e.g. resolving or connecting) only have the details that are set.
+func (resp *HttpResponse) raw() rawHttpReply {
+	reply := rawHttpReply{
+		TimeToResolve:   resp.TimeToResolve,
+		TimeToConnect:   resp.TimeToConnect,
+		TimeToFirstByte: resp.TimeToFirstByte,
+		DestinationAddr: addrOrNil(resp.DestinationAddr),
+		SourceAddr:      addrOrNil(resp.SourceAddr),
+	}
+	failed := resp.DnsError != "" || resp.Error != ""
+	if !failed || resp.AddressFamily != 0 {
+		reply.AddressFamily = &resp.AddressFamily
+	}
+	if !failed || resp.Method != "" {
+		reply.Method = &resp.Method
+	}
+	if !failed || resp.Version != "" {
+		reply.Version = &resp.Version
+	}
+	if !failed || resp.ResultCode != 0 {
+		reply.ResultCode = &resp.ResultCode
+	}
+	if !failed || resp.HeaderSize != 0 {
+		reply.HeaderSize = &resp.HeaderSize
+	}
+	if !failed || resp.BodySize != 0 {
+		reply.BodySize = &resp.BodySize
+	}
+	if !failed || resp.ReplyTime != 0 {
+		reply.ReplyTime = &resp.ReplyTime
+	}
+	if resp.Headers != nil {
+		reply.Headers = &resp.Headers
+	}
+	for _, rt := range resp.ReadTiming {
+		reply.ReadTiming = append(reply.ReadTiming, httpReadTiming{readOffset(rt.Offset), rt.Time})
+	}
+	if resp.SubID != 0 || resp.SubMax != 0 {
+		reply.SubID = &resp.SubID
+		reply.SubMax = &resp.SubMax
+	}
+	if !resp.Time.IsZero() {
+		t := uniTime(resp.Time)
+		reply.Time = &t
+	}
+	if resp.DnsError != "" {
+		reply.DnsError = &resp.DnsError
+	}
+	if resp.Error != "" {
+		reply.Error = &resp.Error
+	}
+	return reply
 }
 
 //////////////////////////////////////////////////////
@@ -91,28 +294,46 @@ type httpResult struct {
 }
 
 type rawHttpReply struct {
-	BodySize        uint            `json:"bsize"`      //
-	DnsError        *string         `json:"dnserr"`     //
-	DestinationAddr netip.Addr      `json:"dst_addr"`   //
-	Error           *string         `json:"err"`        //
-	Headers         *[]string       `json:"header"`     //
-	HeaderSize      uint            `json:"hsize"`      //
-	Method          string          `json:"method"`     //
-	ReadTiming      *httpReadTiming `json:"readtiming"` //
-	ResultCode      uint            `json:"res"`        //
-	ReplyTime       float64         `json:"rt"`         //
-	SubID           *uint           `json:"subid"`      //
-	SubMax          *uint           `json:"submax"`     //
-	Time            *uniTime        `json:"time"`       //
-	TimeToConnect   float64         `json:"ttc"`        //
-	TimeToFirstByte float64         `json:"ttfb"`       //
-	Version         string          `json:"ver"`        //
-
-	SourceAddr    netip.Addr `json:"src_addr"`
-	AddressFamily uint       `json:"af"`
+	BodySize        *uint            `json:"bsize,omitempty"`      //
+	DnsError        *string          `json:"dnserr,omitempty"`     //
+	DestinationAddr *netip.Addr      `json:"dst_addr,omitempty"`   //
+	Error           *string          `json:"err,omitempty"`        //
+	Headers         *[]string        `json:"header,omitempty"`     //
+	HeaderSize      *uint            `json:"hsize,omitempty"`      //
+	Method          *string          `json:"method,omitempty"`     //
+	ReadTiming      []httpReadTiming `json:"readtiming,omitempty"` //
+	ResultCode      *uint            `json:"res,omitempty"`        //
+	ReplyTime       *float64         `json:"rt,omitempty"`         //
+	SubID           *uint            `json:"subid,omitempty"`      //
+	SubMax          *uint            `json:"submax,omitempty"`     //
+	Time            *uniTime         `json:"time,omitempty"`       //
+	TimeToResolve   float64          `json:"ttr,omitempty"`        //
+	TimeToConnect   float64          `json:"ttc,omitempty"`        //
+	TimeToFirstByte float64          `json:"ttfb,omitempty"`       //
+	Version         *string          `json:"ver,omitempty"`        //
+
+	SourceAddr    *netip.Addr `json:"src_addr,omitempty"`
+	AddressFamily *uint       `json:"af,omitempty"`
 }
 
 type httpReadTiming struct {
-	Offset    uint `json:"o"` //
-	TimeSince uint `json:"t"` //
+	Offset readOffset `json:"o"` //
+	Time   float64    `json:"t"` //
+}
+
+// readOffset is a string in the API, but let's accept numbers too
+type readOffset uint
+
+func (o *readOffset) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid read timing offset %s", b)
+	}
+	*o = readOffset(n)
+	return nil
+}
+
+func (o readOffset) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(strconv.FormatUint(uint64(o), 10))), nil
 }
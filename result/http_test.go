@@ -0,0 +1,53 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// Test if all HTTP responses and their timing details are parsed
+func TestHttpResponses(t *testing.T) {
+	var res HttpResult
+	err := res.Parse(`{"fw":5080,"type":"http","msm_id":2002,"prb_id":11,"timestamp":1700000000,"uri":"https://cdn.example.com/obj","result":[` +
+		`{"af":4,"bsize":2048,"dst_addr":"192.0.2.81","hsize":210,"method":"GET","res":200,"rt":120.5,"src_addr":"192.168.1.11","subid":1,"submax":2,"time":1700000000,"ttr":5.25,"ttc":20.5,"ttfb":60.75,"ver":"1.1",` +
+		`"header":["HTTP/1.1 200 OK","Set-Cookie: a=1","set-cookie: b=2","X-Cache: HIT"," from edge","Server: edge"],` +
+		`"readtiming":[{"o":"0","t":60.75},{"o":"1024","t":90.5},{"o":2048,"t":120.25}]},` +
+		`{"af":6,"dst_addr":"2001:db8::81","method":"GET","res":304,"rt":80.125,"subid":2,"submax":2,"ttc":10.5,"ttfb":40.5,"ver":"1.1"}]}`)
+	if err != nil {
+		t.Fatalf("parsing failed: %v", err)
+	}
+
+	if len(res.Responses) != 2 {
+		t.Fatalf("expected 2 responses, got %d", len(res.Responses))
+	}
+	first, second := res.Responses[0], res.Responses[1]
+
+	if res.ResultCode != 200 || res.DestinationAddr.String() != "192.0.2.81" {
+		t.Errorf("first response is not reflected in the result: %+v", res)
+	}
+	if first.SubID != 1 || first.SubMax != 2 || !first.Time.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("sub-request details are wrong: %d/%d %v", first.SubID, first.SubMax, first.Time)
+	}
+	if !first.HasExtendedTiming() || first.TimeToResolve != 5.25 || first.TimeToConnect != 20.5 || first.TimeToFirstByte != 60.75 {
+		t.Errorf("extended timing is wrong: %+v", first)
+	}
+	if len(first.ReadTiming) != 3 || first.ReadTiming[2] != (HttpReadTiming{2048, 120.25}) || first.TimeToRead() != 59.5 {
+		t.Errorf("read timing is wrong: %+v", first.ReadTiming)
+	}
+	if !slices.Equal(first.Header.Values("set-cookie"), []string{"a=1", "b=2"}) ||
+		first.Header.Get("X-Cache") != "HIT from edge" || len(first.Header) != 3 {
+		t.Errorf("headers are parsed wrong: %v", first.Header)
+	}
+
+	if second.ResultCode != 304 || second.AddressFamily != 6 || second.TimeToResolve != 0 ||
+		second.ReadTiming != nil || second.Header != nil {
+		t.Errorf("second response is parsed wrong: %+v", second)
+	}
+}
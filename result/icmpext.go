@@ -0,0 +1,36 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"fmt"
+	"strings"
+)
+
+// String shows the objects of the extension, separated by spaces
+func (ext *IcmpExtension) String() string {
+	list := make([]string, 0, len(ext.Objects))
+	for _, obj := range ext.Objects {
+		list = append(list, obj.String())
+	}
+	return strings.Join(list, " ")
+}
+
+// String shows the object similar to how traceroute -e does; only MPLS
+// label stacks have details in Atlas results, other objects are shown by
+// their class and type
+func (obj *IcmpExtensionObject) String() string {
+	if len(obj.MplsObject) == 0 {
+		return fmt.Sprintf("<EXT:%d/%d>", obj.Class, obj.Type)
+	}
+	list := make([]string, 0, len(obj.MplsObject))
+	for _, mpls := range obj.MplsObject {
+		list = append(list, fmt.Sprintf("L=%d,E=%d,S=%d,T=%d",
+			mpls.Label, mpls.Experimental, mpls.BottomOfStack, mpls.Ttl))
+	}
+	return "<MPLS:" + strings.Join(list, "/") + ">"
+}
@@ -0,0 +1,37 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Test how extension objects are shown
+func TestIcmpExtensions(t *testing.T) {
+	var trace TracerouteResult
+	err := trace.Parse(`{"fw":5080,"type":"traceroute","msm_id":5001,"prb_id":1,"timestamp":1700000000,"af":4,"dst_addr":"192.0.2.1","paris_id":1,"result":[` +
+		`{"hop":1,"result":[{"from":"203.0.113.9","rtt":4.2,"size":140,"ttl":254,"icmpext":{"version":2,"rfc4884":1,"obj":[` +
+		`{"class":1,"type":1,"mpls":[{"exp":0,"label":24002,"s":0,"ttl":1},{"exp":5,"label":16,"s":1,"ttl":1}]},` +
+		`{"class":2,"type":15}]}}]}]}`)
+	if err != nil {
+		t.Fatalf("parsing failed: %v", err)
+	}
+	ext := trace.Hops[0].Responses[0].IcmpExtensions[0]
+	if len(ext.Objects) != 2 {
+		t.Fatalf("expected 2 extension objects, got %d", len(ext.Objects))
+	}
+	if s := ext.String(); s != "<MPLS:L=24002,E=0,S=0,T=1/L=16,E=5,S=1,T=1> <EXT:2/15>" {
+		t.Errorf("unexpected string: %s", s)
+	}
+
+	marshaled, _ := json.Marshal(trace)
+	if !strings.Contains(string(marshaled), `{"class":2,"type":15}`) {
+		t.Errorf("object without a label stack was not marshaled as is: %s", marshaled)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// Test if parse -> marshal -> parse is lossless for all result types, and
+// if marshaling gives back the same JSON as the original
+func TestMarshalRoundTrip(t *testing.T) {
+	types := make(map[string]int)
+	for _, filename := range []string{"testdata/results.jsonl", "../goattest/demo/results.jsonl"} {
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatalf("could not open fixtures: %v", err)
+		}
+		defer file.Close()
+
+		read := bufio.NewScanner(file)
+		read.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for n := 1; read.Scan(); n++ {
+			first, err := Parse(read.Text())
+			if err != nil {
+				t.Fatalf("%s:%d: parsing failed: %v", filename, n, err)
+			}
+			types[first.TypeName()]++
+
+			marshaled, err := json.Marshal(first)
+			if err != nil {
+				t.Fatalf("%s:%d: marshaling failed: %v", filename, n, err)
+			}
+			second, err := Parse(string(marshaled))
+			if err != nil {
+				t.Fatalf("%s:%d: parsing the marshaled result failed: %v\n%s", filename, n, err, marshaled)
+			}
+			if !reflect.DeepEqual(first, second) {
+				t.Errorf("%s:%d: round trip is not lossless\noriginal: %s\nmarshaled: %s\nfirst: %+v\nsecond: %+v",
+					filename, n, read.Text(), marshaled, first, second)
+			}
+
+			var original, remarshaled map[string]any
+			json.Unmarshal(read.Bytes(), &original)
+			json.Unmarshal(marshaled, &remarshaled)
+			if !reflect.DeepEqual(original, remarshaled) {
+				t.Errorf("%s:%d: marshaled JSON differs from the original\noriginal: %s\nmarshaled: %s",
+					filename, n, read.Text(), marshaled)
+			}
+
+			again, _ := json.Marshal(second)
+			if string(again) != string(marshaled) {
+				t.Errorf("%s:%d: marshaling is not stable:\n%s\n%s", filename, n, marshaled, again)
+			}
+		}
+	}
+
+	for _, typ := range []string{"ping", "traceroute", "dns", "ntp", "sslcert", "http", "uptime", "connection"} {
+		if types[typ] == 0 {
+			t.Errorf("no %s results among the fixtures", typ)
+		}
+	}
+}
+
+// Test if some details survive and look like the API's format
+func TestMarshalDetails(t *testing.T) {
+	res, err := Parse(`{"fw":5080,"type":"traceroute","msm_id":5001,"prb_id":1,"timestamp":1700000000,"af":4,"dst_addr":"192.0.2.1","paris_id":3,"result":[{"hop":1,"result":[{"from":"203.0.113.1","rtt":5.5,"size":140,"ttl":254,"err":3,"icmpext":{"version":2,"rfc4884":1,"obj":[{"class":1,"type":1,"mpls":[{"exp":0,"label":24001,"s":1,"ttl":1}]}]}},{"from":"203.0.113.1","late":2,"size":140,"ttl":254}]}]}`)
+	if err != nil {
+		t.Fatalf("parsing failed: %v", err)
+	}
+	trace := res.(*TracerouteResult)
+	responses := trace.Hops[0].Responses
+	if len(responses) != 2 || responses[1].Late == nil {
+		t.Errorf("late response was not kept: %+v", responses)
+	}
+	if len(responses[0].IcmpExtensions) != 1 || len(responses[0].IcmpExtensions[0].Objects) != 1 ||
+		responses[0].IcmpExtensions[0].Objects[0].MplsObject[0].Label != 24001 {
+		t.Errorf("ICMP extension objects were not parsed: %+v", responses[0].IcmpExtensions)
+	}
+
+	marshaled, _ := json.Marshal(res)
+	var raw map[string]any
+	if err := json.Unmarshal(marshaled, &raw); err != nil {
+		t.Fatalf("marshaled result is not JSON: %v", err)
+	}
+	if raw["timestamp"] != 1700000000.0 || raw["paris_id"] != 3.0 || raw["type"] != "traceroute" {
+		t.Errorf("unexpected marshaled result: %s", marshaled)
+	}
+	hop := raw["result"].([]any)[0].(map[string]any)["result"].([]any)[0].(map[string]any)
+	if hop["err"] != 3.0 {
+		t.Errorf("numeric error code should stay numeric: %s", marshaled)
+	}
+}
+
+// Test if ping packets are marshaled in the order they were received
+func TestMarshalPingOrder(t *testing.T) {
+	original := `[{"x":"*"},{"rtt":1.5},{"error":"network unreachable"},{"rtt":2.5},{"x":"*"}]`
+	res, err := Parse(`{"fw":5080,"type":"ping","msm_id":1001,"prb_id":1,"timestamp":1700000000,"af":4,"dst_addr":"192.0.2.1","result":` +
+		original + `}`)
+	if err != nil {
+		t.Fatalf("parsing failed: %v", err)
+	}
+	marshaled, _ := json.Marshal(res)
+	var raw struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(marshaled, &raw); err != nil {
+		t.Fatalf("marshaled result is not JSON: %v", err)
+	}
+	if string(raw.Result) != original {
+		t.Errorf("packets are not in the original order: %s", raw.Result)
+	}
+}
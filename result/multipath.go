@@ -0,0 +1,233 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"cmp"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// MultipathKey identifies traceroutes that are compared across Paris IDs
+type MultipathKey struct {
+	ProbeID     uint   //
+	Destination string // destination address, or name if there's no address
+}
+
+// MultipathAnalyser groups traceroutes from the same probe to the same
+// destination, to discover load balancing by comparing the paths taken by
+// different flows (Paris IDs). The paths are compared hop position by hop
+// position, so the results are the most meaningful for traceroutes taken
+// in a short time window, when the routing doesn't change.
+type MultipathAnalyser struct {
+	groups map[MultipathKey]*Multipath
+}
+
+// Multipath is the outcome of the analysis for one probe and destination
+type Multipath struct {
+	Key      MultipathKey   //
+	Traces   uint           // number of traceroutes
+	ParisIDs []uint         // distinct Paris IDs, in order
+	Paths    []FlowPath     // distinct paths, in the order they were seen
+	Balanced []MultipathHop // hop positions with more than one address
+	Diamonds []Diamond      //
+	Width    int            // maximum number of addresses at a hop position
+}
+
+// FlowPath is a distinct path: the most frequent responding address of each
+// hop, invalid if there was none
+type FlowPath struct {
+	Hops     []FlowHop //
+	ParisIDs []uint    // Paris IDs that took this path, in order
+	Count    uint      // number of traceroutes that took this path
+}
+
+// FlowHop is a hop on a path
+type FlowHop struct {
+	HopNumber uint       //
+	Addr      netip.Addr //
+}
+
+// MultipathHop lists the addresses seen at a hop position
+type MultipathHop struct {
+	HopNumber    uint         //
+	Addrs        []netip.Addr // in order
+	Unresponsive bool         // some paths had no address here
+}
+
+// Diamond is a part of the paths between a divergence point (a hop
+// position with one address, followed by one with more) and a
+// convergence point (the next position where all paths have the same
+// address)
+type Diamond struct {
+	Divergence   MultipathHop // the hop before the paths diverge
+	Convergence  MultipathHop // the hop where they converge, no addresses if they don't
+	Width        int          // maximum number of addresses at a hop position in the diamond
+	Length       uint         // hop positions from divergence to convergence
+	BalancedHops []uint       // hop positions with more than one address
+	Unconverged  bool         // the paths don't converge again
+}
+
+// NewMultipathAnalyser returns an empty analyser
+func NewMultipathAnalyser() MultipathAnalyser {
+	return MultipathAnalyser{
+		groups: make(map[MultipathKey]*Multipath),
+	}
+}
+
+// Add adds a traceroute to the analysis
+func (analyser *MultipathAnalyser) Add(trace *TracerouteResult) {
+	route := trace.RouteKey()
+	key := MultipathKey{route.ProbeID, route.Destination}
+	group, ok := analyser.groups[key]
+	if !ok {
+		group = &Multipath{Key: key}
+		analyser.groups[key] = group
+	}
+	group.add(trace)
+}
+
+// Len returns the number of probe and destination pairs seen so far
+func (analyser *MultipathAnalyser) Len() int {
+	return len(analyser.groups)
+}
+
+// Results returns the analysis for each probe and destination, ordered by
+// probe ID and destination
+func (analyser *MultipathAnalyser) Results() []*Multipath {
+	list := make([]*Multipath, 0, len(analyser.groups))
+	for _, group := range analyser.groups {
+		group.analyse()
+		list = append(list, group)
+	}
+	slices.SortFunc(list, func(a, b *Multipath) int {
+		return cmp.Or(cmp.Compare(a.Key.ProbeID, b.Key.ProbeID), strings.Compare(a.Key.Destination, b.Key.Destination))
+	})
+	return list
+}
+
+// LoadBalanced tells if any hop position had more than one address
+func (mp *Multipath) LoadBalanced() bool {
+	return len(mp.Balanced) > 0
+}
+
+func (mp *Multipath) add(trace *TracerouteResult) {
+	mp.Traces++
+	if !slices.Contains(mp.ParisIDs, trace.ParisID) {
+		mp.ParisIDs = append(mp.ParisIDs, trace.ParisID)
+		slices.Sort(mp.ParisIDs)
+	}
+
+	hops := make([]FlowHop, 0, len(trace.Hops))
+	for _, hop := range trace.Hops {
+		hops = append(hops, FlowHop{hop.HopNumber, hop.Address()})
+	}
+	for i := range mp.Paths {
+		path := &mp.Paths[i]
+		if slices.Equal(path.Hops, hops) {
+			path.Count++
+			if !slices.Contains(path.ParisIDs, trace.ParisID) {
+				path.ParisIDs = append(path.ParisIDs, trace.ParisID)
+				slices.Sort(path.ParisIDs)
+			}
+			return
+		}
+	}
+	mp.Paths = append(mp.Paths, FlowPath{hops, []uint{trace.ParisID}, 1})
+}
+
+// analyse finds the load balanced positions and the diamonds
+func (mp *Multipath) analyse() {
+	positions := make(map[uint][]netip.Addr)
+	unresponsive := make(map[uint]bool)
+	for _, path := range mp.Paths {
+		for _, hop := range path.Hops {
+			addrs := positions[hop.HopNumber]
+			if !hop.Addr.IsValid() {
+				unresponsive[hop.HopNumber] = true
+			} else if !slices.Contains(addrs, hop.Addr) {
+				addrs = append(addrs, hop.Addr)
+			}
+			positions[hop.HopNumber] = addrs
+		}
+	}
+	numbers := make([]uint, 0, len(positions))
+	for number := range positions {
+		numbers = append(numbers, number)
+	}
+	slices.Sort(numbers)
+
+	hops := make([]MultipathHop, 0, len(numbers))
+	mp.Width = 0
+	mp.Balanced = make([]MultipathHop, 0)
+	for _, number := range numbers {
+		addrs := positions[number]
+		slices.SortFunc(addrs, func(a, b netip.Addr) int { return a.Compare(b) })
+		hop := MultipathHop{number, addrs, unresponsive[number]}
+		hops = append(hops, hop)
+		mp.Width = max(mp.Width, len(addrs))
+		if len(addrs) > 1 {
+			mp.Balanced = append(mp.Balanced, hop)
+		}
+	}
+	mp.Diamonds = findDiamonds(hops)
+}
+
+// findDiamonds walks the hop positions in order; a diamond only ends where
+// all paths responded with the same address
+func findDiamonds(hops []MultipathHop) []Diamond {
+	diamonds := make([]Diamond, 0)
+	var current *Diamond
+	for i, hop := range hops {
+		switch {
+		case current == nil:
+			if len(hop.Addrs) == 1 && i+1 < len(hops) && len(hops[i+1].Addrs) > 1 {
+				current = &Diamond{Divergence: hop, Width: 1}
+			}
+		case len(hop.Addrs) == 1 && !hop.Unresponsive:
+			current.Convergence = hop
+			current.Length = hop.HopNumber - current.Divergence.HopNumber
+			diamonds = append(diamonds, *current)
+			current = nil
+			// the convergence point can be the divergence point of the next one
+			if i+1 < len(hops) && len(hops[i+1].Addrs) > 1 {
+				current = &Diamond{Divergence: hop, Width: 1}
+			}
+		default:
+			current.Width = max(current.Width, len(hop.Addrs))
+			if len(hop.Addrs) > 1 {
+				current.BalancedHops = append(current.BalancedHops, hop.HopNumber)
+			}
+		}
+	}
+	if current != nil {
+		// the length is up to the last position that had any address
+		last := current.Divergence
+		for _, hop := range hops {
+			if len(hop.Addrs) > 0 {
+				last = hop
+			}
+		}
+		current.Unconverged = true
+		current.Length = last.HopNumber - current.Divergence.HopNumber
+		diamonds = append(diamonds, *current)
+	}
+	return diamonds
+}
+
+func (path FlowPath) String() string {
+	list := make([]string, 0, len(path.Hops))
+	for _, hop := range path.Hops {
+		if hop.Addr.IsValid() {
+			list = append(list, hop.Addr.String())
+		} else {
+			list = append(list, "*")
+		}
+	}
+	return strings.Join(list, " ")
+}
@@ -0,0 +1,97 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+// parseParisTrace makes a traceroute with one responding address per hop,
+// "*" for hops without a response
+func parseParisTrace(t *testing.T, probe uint, paris uint, hops ...string) *TracerouteResult {
+	t.Helper()
+	json := ""
+	for i, addr := range hops {
+		if i > 0 {
+			json += ","
+		}
+		if addr == "*" {
+			json += fmt.Sprintf(`{"hop":%d,"result":[{"x":"*"},{"x":"*"}]}`, i+1)
+		} else {
+			json += fmt.Sprintf(`{"hop":%d,"result":[{"from":"%s","rtt":1.0,"size":28,"ttl":60},{"from":"%s","rtt":1.0,"size":28,"ttl":60}]}`,
+				i+1, addr, addr)
+		}
+	}
+	return parseTestTrace(t, probe, 1700000000, paris, json)
+}
+
+func TestMultipathAnalyser(t *testing.T) {
+	analyser := NewMultipathAnalyser()
+	// two diamonds: 1 > {2,3} > 4 and 4 > {5,6,7} > {8,9} > 10
+	analyser.Add(parseParisTrace(t, 1, 1, "192.0.2.1", "192.0.2.2", "192.0.2.4", "192.0.2.5", "192.0.2.8", "198.51.100.7"))
+	analyser.Add(parseParisTrace(t, 1, 2, "192.0.2.1", "192.0.2.3", "192.0.2.4", "192.0.2.6", "192.0.2.9", "198.51.100.7"))
+	analyser.Add(parseParisTrace(t, 1, 3, "192.0.2.1", "192.0.2.2", "192.0.2.4", "192.0.2.7", "*", "198.51.100.7"))
+	analyser.Add(parseParisTrace(t, 1, 1, "192.0.2.1", "192.0.2.2", "192.0.2.4", "192.0.2.5", "192.0.2.8", "198.51.100.7"))
+	// no load balancing for another probe
+	analyser.Add(parseParisTrace(t, 2, 1, "192.0.2.1", "198.51.100.7"))
+	analyser.Add(parseParisTrace(t, 2, 2, "192.0.2.1", "198.51.100.7"))
+
+	// paths that don't converge
+	analyser.Add(parseParisTrace(t, 3, 1, "192.0.2.1", "192.0.2.2", "192.0.2.5", "*"))
+	analyser.Add(parseParisTrace(t, 3, 2, "192.0.2.1", "192.0.2.3", "*", "*"))
+
+	results := analyser.Results()
+	if len(results) != 3 || analyser.Len() != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+
+	mp := results[0]
+	if mp.Key.ProbeID != 1 || mp.Traces != 4 || !slices.Equal(mp.ParisIDs, []uint{1, 2, 3}) {
+		t.Errorf("unexpected group: %+v", mp)
+	}
+	if len(mp.Paths) != 3 || mp.Paths[0].Count != 2 || !slices.Equal(mp.Paths[0].ParisIDs, []uint{1}) {
+		t.Errorf("unexpected paths: %+v", mp.Paths)
+	}
+	if mp.Paths[2].String() != "192.0.2.1 192.0.2.2 192.0.2.4 192.0.2.7 * 198.51.100.7" {
+		t.Errorf("unexpected path: %s", mp.Paths[2])
+	}
+	balanced := make([]uint, 0)
+	for _, hop := range mp.Balanced {
+		balanced = append(balanced, hop.HopNumber)
+	}
+	if !slices.Equal(balanced, []uint{2, 4, 5}) || mp.Width != 3 || !mp.LoadBalanced() {
+		t.Errorf("unexpected load balancing: %v width %d", balanced, mp.Width)
+	}
+	if len(mp.Diamonds) != 2 {
+		t.Fatalf("expected 2 diamonds, got %+v", mp.Diamonds)
+	}
+	first, second := mp.Diamonds[0], mp.Diamonds[1]
+	if first.Divergence.Addrs[0] != netip.MustParseAddr("192.0.2.1") ||
+		first.Convergence.Addrs[0] != netip.MustParseAddr("192.0.2.4") ||
+		first.Width != 2 || first.Length != 2 {
+		t.Errorf("unexpected first diamond: %+v", first)
+	}
+	if second.Divergence.HopNumber != 3 || second.Convergence.HopNumber != 6 ||
+		second.Width != 3 || second.Length != 3 || !slices.Equal(second.BalancedHops, []uint{4, 5}) ||
+		second.Unconverged {
+		t.Errorf("unexpected second diamond: %+v", second)
+	}
+
+	if other := results[1]; other.LoadBalanced() || len(other.Paths) != 1 || len(other.Diamonds) != 0 ||
+		other.Width != 1 {
+		t.Errorf("unexpected load balancing: %+v", other)
+	}
+
+	open := results[2].Diamonds
+	if len(open) != 1 || !open[0].Unconverged || open[0].Length != 2 || open[0].Width != 2 ||
+		len(open[0].Convergence.Addrs) != 0 {
+		t.Errorf("unexpected unconverged diamond: %+v", open)
+	}
+}
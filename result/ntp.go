@@ -69,6 +69,36 @@ func (ntp *NtpResult) Parse(from string) (err error) {
 	return nil
 }
 
+// MarshalJSON turns the result back into the format used by the API
+func (ntp NtpResult) MarshalJSON() ([]byte, error) {
+	intp := ntpResult{
+		BaseResult:         ntp.BaseResult,
+		Protocol:           ntp.Protocol,
+		Version:            ntp.Version,
+		LeapIndicator:      ntp.LeapIndicator,
+		Mode:               ntp.Mode,
+		Stratum:            ntp.Stratum,
+		PollInterval:       ntp.PollInterval,
+		Precision:          ntp.Precision,
+		RootDelay:          ntp.RootDelay,
+		RootDispersion:     ntp.RootDispersion,
+		ReferenceID:        ntp.ReferenceID,
+		ReferenceTimestamp: ntp.ReferenceTimestamp,
+		RawResult:          make([]any, 0),
+	}
+	for _, reply := range ntp.Replies {
+		intp.RawResult = append(intp.RawResult, rawNtpReply(reply))
+	}
+	for _, err := range ntp.Errors {
+		intp.RawResult = append(intp.RawResult, map[string]string{"x": err})
+	}
+
+	return json.Marshal(struct {
+		rawBaseResult
+		ntpResult
+	}{ntp.BaseResult.raw(), intp})
+}
+
 //////////////////////////////////////////////////////
 // API version of an NTP result
 
@@ -89,6 +119,16 @@ type ntpResult struct {
 	RawResult          []any   `json:"result"`          //
 }
 
+// one reply, as produced when marshaling
+type rawNtpReply struct {
+	OriginTimestamp   float64 `json:"origin-ts"`   //
+	TransmitTimestamp float64 `json:"transmit-ts"` //
+	ReceiveTimestamp  float64 `json:"receive-ts"`  //
+	FinalTimestamp    float64 `json:"final-ts"`    //
+	Offset            float64 `json:"offset"`      //
+	Rtt               float64 `json:"rtt"`         //
+}
+
 func (result *ntpResult) Replies() []NtpReply {
 	r := make([]NtpReply, 0)
 	for _, item := range result.RawResult {
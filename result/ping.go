@@ -25,6 +25,7 @@ type PingResult struct {
 	Replies                           []PingReply //
 	Errors                            []string    //
 	Timeouts                          uint        //
+	order                             []byte      // kinds of the packets as received, to marshal them in order
 }
 
 // one successful ping reply
@@ -48,6 +49,7 @@ func (ping *PingResult) Parse(from string) (err error) {
 	ping.Replies = iping.Replies()
 	ping.Errors = iping.Errors()
 	ping.Timeouts = uint(iping.Timeouts())
+	ping.order = iping.order()
 	ping.Minimum = iping.Minimum
 	ping.Average = iping.Average
 	ping.Maximum = iping.Maximum
@@ -68,6 +70,74 @@ func (ping *PingResult) Parse(from string) (err error) {
 	return nil
 }
 
+// MarshalJSON turns the result back into the format used by the API
+func (ping PingResult) MarshalJSON() ([]byte, error) {
+	iping := pingResult{
+		BaseResult: ping.BaseResult,
+		Minimum:    ping.Minimum,
+		Average:    ping.Average,
+		Maximum:    ping.Maximum,
+		Sent:       ping.Sent,
+		Received:   ping.Received,
+		Duplicates: ping.Duplicates,
+		PacketSize: ping.PacketSize,
+		Protocol:   ping.Protocol,
+		Step:       ping.Step,
+		RawResult:  make([]any, 0),
+	}
+	if ping.Ttl != 0 {
+		iping.Ttl = &ping.Ttl
+	}
+
+	// packets are written in the order they were received; anything not
+	// covered by that (e.g. replies added later) comes after them
+	replies, errs, timeouts := 0, 0, uint(0)
+	for _, kind := range ping.order {
+		switch {
+		case kind == pingItemReply && replies < len(ping.Replies):
+			iping.RawResult = append(iping.RawResult, ping.rawReply(replies))
+			replies++
+		case kind == pingItemError && errs < len(ping.Errors):
+			iping.RawResult = append(iping.RawResult, rawPingReply{Error: ping.Errors[errs]})
+			errs++
+		case kind == pingItemTimeout && timeouts < ping.Timeouts:
+			iping.RawResult = append(iping.RawResult, rawPingReply{Timeout: "*"})
+			timeouts++
+		}
+	}
+	for ; replies < len(ping.Replies); replies++ {
+		iping.RawResult = append(iping.RawResult, ping.rawReply(replies))
+	}
+	for ; errs < len(ping.Errors); errs++ {
+		iping.RawResult = append(iping.RawResult, rawPingReply{Error: ping.Errors[errs]})
+	}
+	for ; timeouts < ping.Timeouts; timeouts++ {
+		iping.RawResult = append(iping.RawResult, rawPingReply{Timeout: "*"})
+	}
+
+	return json.Marshal(struct {
+		rawBaseResult
+		pingResult
+	}{ping.BaseResult.raw(), iping})
+}
+
+// rawReply returns a reply in the API format; replies without a source or
+// TTL inherit these from the result
+func (ping *PingResult) rawReply(i int) rawPingReply {
+	reply := ping.Replies[i]
+	item := rawPingReply{Rtt: &reply.Rtt}
+	if reply.Source.IsValid() && (ping.DestinationAddr == nil || reply.Source != *ping.DestinationAddr) {
+		item.Source = &reply.Source
+	}
+	if reply.Ttl != ping.Ttl {
+		item.Ttl = &reply.Ttl
+	}
+	if reply.Duplicate {
+		item.Duplicate = 1
+	}
+	return item
+}
+
 func (result *PingResult) TypeName() string {
 	return "ping"
 }
@@ -86,17 +156,27 @@ func (result *PingResult) ReplyRtts() []float64 {
 // this is the JSON structure as reported by the API
 type pingResult struct {
 	BaseResult
-	Minimum    float64 `json:"min"`    //
-	Average    float64 `json:"avg"`    //
-	Maximum    float64 `json:"max"`    //
-	Sent       uint    `json:"sent"`   //
-	Received   uint    `json:"rcvd"`   //
-	Duplicates uint    `json:"dup"`    //
-	PacketSize uint    `json:"size"`   //
-	Protocol   string  `json:"proto"`  //
-	Step       *uint   `json:"step"`   //
-	Ttl        *uint   `json:"ttl"`    //
-	RawResult  []any   `json:"result"` //
+	Minimum    float64 `json:"min"`            //
+	Average    float64 `json:"avg"`            //
+	Maximum    float64 `json:"max"`            //
+	Sent       uint    `json:"sent"`           //
+	Received   uint    `json:"rcvd"`           //
+	Duplicates uint    `json:"dup"`            //
+	PacketSize uint    `json:"size"`           //
+	Protocol   string  `json:"proto"`          //
+	Step       *uint   `json:"step,omitempty"` //
+	Ttl        *uint   `json:"ttl,omitempty"`  //
+	RawResult  []any   `json:"result"`         //
+}
+
+// one item of the result list, as produced when marshaling
+type rawPingReply struct {
+	Rtt       *float64    `json:"rtt,omitempty"`      //
+	Source    *netip.Addr `json:"src_addr,omitempty"` //
+	Ttl       *uint       `json:"ttl,omitempty"`      //
+	Duplicate uint        `json:"dup,omitempty"`      //
+	Error     string      `json:"error,omitempty"`    //
+	Timeout   string      `json:"x,omitempty"`        //
 }
 
 // parse replies in the result
@@ -165,6 +245,29 @@ func (result *pingResult) Timeouts() uint {
 	return n
 }
 
+// kinds of items in the result list
+const (
+	pingItemReply byte = iota
+	pingItemError
+	pingItemTimeout
+)
+
+// order returns the kinds of the items in the result list, in order
+func (result *pingResult) order() []byte {
+	r := make([]byte, 0, len(result.RawResult))
+	for _, item := range result.RawResult {
+		mapitem := item.(map[string]any)
+		if _, ok := mapitem["rtt"]; ok {
+			r = append(r, pingItemReply)
+		} else if _, ok := mapitem["error"]; ok {
+			r = append(r, pingItemError)
+		} else if _, ok := mapitem["x"]; ok {
+			r = append(r, pingItemTimeout)
+		}
+	}
+	return r
+}
+
 func median(vals []float64) float64 {
 	n := len(vals)
 	slice := vals[:]
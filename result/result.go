@@ -18,6 +18,7 @@ type Result interface {
 	TypeName() string
 	GetTimeStamp() time.Time
 	GetProbeID() uint
+	GetMeasurementID() uint
 	GetFirmwareVersion() uint
 }
 
@@ -107,6 +108,11 @@ func (ut *uniTime) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// uniTime is marshaled as UNIX epoch, as the API does
+func (ut uniTime) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(time.Time(ut).Unix(), 10)), nil
+}
+
 // default output format for uniTime type is ISO8601
 func (ut uniTime) String() string {
 	return time.Time(ut).UTC().Format("2006-01-02T15:04:05Z")
@@ -0,0 +1,263 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"fmt"
+	"math"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RouteKey identifies a series of traceroutes that are expected to take
+// the same path
+type RouteKey struct {
+	ProbeID     uint   //
+	Destination string // destination address, or name if there's no address
+	ParisID     uint   //
+}
+
+// RouteChangeDetector compares each traceroute to the previous one with
+// the same key, and reports the differences
+type RouteChangeDetector struct {
+	table     *AsnTable                      // to compare AS paths, if any
+	threshold float64                        // RTT difference (ms) to report
+	last      map[RouteKey]*TracerouteResult //
+}
+
+// RouteChange describes how a traceroute differs from the previous one
+// with the same key
+type RouteChange struct {
+	Key           RouteKey    //
+	Before        time.Time   // timestamp of the previous traceroute
+	After         time.Time   // timestamp of this traceroute
+	Hops          []HopChange // hops where the responding addresses differ
+	AsPathBefore  AsPath      // only if there's routing data
+	AsPathAfter   AsPath      // only if there's routing data
+	AsChanged     bool        // the sequence of ASes is different
+	RttBefore     float64     // RTT to the destination, 0 if it was not reached
+	RttAfter      float64     // RTT to the destination, 0 if it was not reached
+	RttShifted    bool        // the RTT changed more than the threshold
+	ReachedBefore bool        //
+	ReachedAfter  bool        //
+}
+
+// HopChange lists the responding addresses of a hop before and after a
+// change. A nil list means that the hop was not there at all.
+type HopChange struct {
+	HopNumber uint         //
+	Before    []netip.Addr //
+	After     []netip.Addr //
+}
+
+// NewRouteChangeDetector returns a detector that compares hops only, and
+// reports RTT shifts of 10 ms or more
+func NewRouteChangeDetector() RouteChangeDetector {
+	return RouteChangeDetector{
+		threshold: 10,
+		last:      make(map[RouteKey]*TracerouteResult),
+	}
+}
+
+// AsnTable sets the routing data used to compare AS paths as well
+func (detector *RouteChangeDetector) AsnTable(table *AsnTable) {
+	detector.table = table
+}
+
+// RttThreshold sets the smallest change in the RTT to the destination
+// (in ms) that is reported
+func (detector *RouteChangeDetector) RttThreshold(threshold float64) {
+	detector.threshold = threshold
+}
+
+// Len returns the number of traceroute series seen so far
+func (detector *RouteChangeDetector) Len() int {
+	return len(detector.last)
+}
+
+// RouteKey returns the key that identifies the series of the traceroute
+func (trace *TracerouteResult) RouteKey() RouteKey {
+	key := RouteKey{ProbeID: trace.ProbeID, Destination: trace.DestinationName, ParisID: trace.ParisID}
+	if trace.DestinationAddr != nil {
+		key.Destination = trace.DestinationAddr.String()
+	}
+	return key
+}
+
+// Add compares a traceroute to the previous one in its series, and makes
+// it the one to compare the next one to. It returns nil if there was no
+// previous traceroute, if nothing changed, or if the traceroute is not
+// newer than the previous one (which is then kept).
+func (detector *RouteChangeDetector) Add(trace *TracerouteResult) *RouteChange {
+	key := trace.RouteKey()
+	prev, ok := detector.last[key]
+	if ok && !trace.GetTimeStamp().After(prev.GetTimeStamp()) {
+		return nil
+	}
+	detector.last[key] = trace
+	if !ok {
+		return nil
+	}
+
+	change := RouteChange{
+		Key:           key,
+		Before:        prev.GetTimeStamp(),
+		After:         trace.GetTimeStamp(),
+		Hops:          diffHops(prev, trace),
+		ReachedBefore: reached(prev),
+		ReachedAfter:  reached(trace),
+		RttBefore:     destinationRtt(prev),
+		RttAfter:      destinationRtt(trace),
+	}
+	if detector.table != nil {
+		change.AsPathBefore = prev.AsPath(detector.table)
+		change.AsPathAfter = trace.AsPath(detector.table)
+		change.AsChanged = !slices.Equal(change.AsPathBefore.Asns(), change.AsPathAfter.Asns())
+	}
+	change.RttShifted = change.ReachedBefore && change.ReachedAfter &&
+		math.Abs(change.RttAfter-change.RttBefore) >= detector.threshold
+
+	if !change.Changed() {
+		return nil
+	}
+	return &change
+}
+
+// Changed tells if there's anything to report
+func (change *RouteChange) Changed() bool {
+	return change.PathChanged() || change.AsChanged || change.RttShifted || change.ReachabilityChanged()
+}
+
+// PathChanged tells if any of the hops changed
+func (change *RouteChange) PathChanged() bool {
+	return len(change.Hops) > 0
+}
+
+// ReachabilityChanged tells if the destination became (un)reachable
+func (change *RouteChange) ReachabilityChanged() bool {
+	return change.ReachedBefore != change.ReachedAfter
+}
+
+// Kinds lists what changed as short keywords: path, as, rtt, reach
+func (change *RouteChange) Kinds() []string {
+	kinds := make([]string, 0)
+	for _, check := range []struct {
+		changed bool
+		kind    string
+	}{
+		{change.PathChanged(), "path"},
+		{change.AsChanged, "as"},
+		{change.RttShifted, "rtt"},
+		{change.ReachabilityChanged(), "reach"},
+	} {
+		if check.changed {
+			kinds = append(kinds, check.kind)
+		}
+	}
+	return kinds
+}
+
+func (hop HopChange) String() string {
+	return fmt.Sprintf("%d:%s>%s", hop.HopNumber, addrListString(hop.Before), addrListString(hop.After))
+}
+
+// addrListString shows addresses separated by /, "*" if there are none
+// and "-" if the hop was missing
+func addrListString(addrs []netip.Addr) string {
+	switch {
+	case addrs == nil:
+		return "-"
+	case len(addrs) == 0:
+		return "*"
+	}
+	list := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		list = append(list, addr.String())
+	}
+	return strings.Join(list, "/")
+}
+
+// diffHops compares the responding addresses hop by hop. Hops that didn't
+// respond in either traceroute match anything; hops that responded in both
+// have to have an address in common.
+func diffHops(before, after *TracerouteResult) []HopChange {
+	prev := hopAddresses(before)
+	next := hopAddresses(after)
+	numbers := make([]uint, 0, len(prev)+len(next))
+	for number := range prev {
+		numbers = append(numbers, number)
+	}
+	for number := range next {
+		if _, ok := prev[number]; !ok {
+			numbers = append(numbers, number)
+		}
+	}
+	slices.Sort(numbers)
+
+	changes := make([]HopChange, 0)
+	for _, number := range numbers {
+		b, inBefore := prev[number]
+		a, inAfter := next[number]
+		switch {
+		case inBefore && inAfter && (len(a) == 0 || len(b) == 0):
+			continue
+		case inBefore && inAfter && slices.ContainsFunc(a, func(addr netip.Addr) bool {
+			return slices.Contains(b, addr)
+		}):
+			continue
+		case !inBefore && len(a) == 0, !inAfter && len(b) == 0:
+			continue
+		}
+		changes = append(changes, HopChange{number, b, a})
+	}
+	return changes
+}
+
+// hopAddresses returns the distinct responding addresses per hop, sorted
+func hopAddresses(trace *TracerouteResult) map[uint][]netip.Addr {
+	hops := make(map[uint][]netip.Addr)
+	for _, hop := range trace.Hops {
+		addrs := make([]netip.Addr, 0)
+		for _, resp := range hop.Responses {
+			if resp.Timeout || resp.Error != nil || resp.Late != nil || !resp.From.IsValid() {
+				continue
+			}
+			if !slices.Contains(addrs, resp.From) {
+				addrs = append(addrs, resp.From)
+			}
+		}
+		slices.SortFunc(addrs, func(a, b netip.Addr) int { return a.Compare(b) })
+		hops[hop.HopNumber] = addrs
+	}
+	return hops
+}
+
+// reached is DestinationReached() for traceroutes that may not have a
+// destination address
+func reached(trace *TracerouteResult) bool {
+	return trace.DestinationAddr != nil && trace.DestinationReached()
+}
+
+// destinationRtt returns the lowest RTT to the destination, 0 if it was
+// not reached
+func destinationRtt(trace *TracerouteResult) float64 {
+	if !reached(trace) {
+		return 0
+	}
+	best := 0.0
+	for _, resp := range trace.Hops[len(trace.Hops)-1].Responses {
+		if resp.From != *trace.DestinationAddr || resp.Late != nil || resp.Error != nil {
+			continue
+		}
+		if best == 0 || resp.Rtt < best {
+			best = resp.Rtt
+		}
+	}
+	return best
+}
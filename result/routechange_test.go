@@ -0,0 +1,84 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRouteChangeDetector(t *testing.T) {
+	table := NewAsnTable()
+	table.ReadPfx2as(strings.NewReader(testPfx2as))
+	detector := NewRouteChangeDetector()
+	detector.AsnTable(&table)
+
+	base := parseTestTrace(t, 1, 1700000000, 3,
+		`{"hop":1,"result":[{"from":"192.0.2.1","rtt":1.0,"size":28,"ttl":64}]},`+
+			`{"hop":2,"result":[{"from":"192.0.2.2","rtt":2.0,"size":28,"ttl":63},{"from":"192.0.2.3","rtt":2.0,"size":28,"ttl":63}]},`+
+			`{"hop":3,"result":[{"from":"198.51.100.7","rtt":5.0,"size":28,"ttl":62},{"from":"198.51.100.7","rtt":4.0,"size":28,"ttl":62}]}`)
+	if change := detector.Add(base); change != nil {
+		t.Errorf("first traceroute should not be a change: %+v", change)
+	}
+
+	// a timeout and a partially overlapping hop are not changes
+	same := parseTestTrace(t, 1, 1700000900, 3,
+		`{"hop":1,"result":[{"x":"*"}]},`+
+			`{"hop":2,"result":[{"from":"192.0.2.3","rtt":2.0,"size":28,"ttl":63}]},`+
+			`{"hop":3,"result":[{"from":"198.51.100.7","rtt":6.0,"size":28,"ttl":62}]}`)
+	if change := detector.Add(same); change != nil {
+		t.Errorf("unexpected change: %+v", change)
+	}
+
+	// older results are ignored
+	if change := detector.Add(base); change != nil {
+		t.Errorf("older traceroute should be ignored: %+v", change)
+	}
+
+	moved := parseTestTrace(t, 1, 1700001800, 3,
+		`{"hop":1,"result":[{"from":"192.0.2.1","rtt":1.0,"size":28,"ttl":64}]},`+
+			`{"hop":2,"result":[{"from":"192.0.2.130","rtt":20.0,"size":28,"ttl":63}]},`+
+			`{"hop":3,"result":[{"from":"192.0.2.131","rtt":25.0,"size":28,"ttl":62}]},`+
+			`{"hop":4,"result":[{"from":"198.51.100.7","rtt":30.0,"size":28,"ttl":61}]}`)
+	change := detector.Add(moved)
+	if change == nil {
+		t.Fatalf("change was not detected")
+	}
+	if !slices.Equal(change.Kinds(), []string{"path", "as", "rtt"}) {
+		t.Errorf("unexpected kinds of change: %v", change.Kinds())
+	}
+	hops := make([]string, 0)
+	for _, hop := range change.Hops {
+		hops = append(hops, hop.String())
+	}
+	if !slices.Equal(hops, []string{"2:192.0.2.3>192.0.2.130", "3:198.51.100.7>192.0.2.131", "4:->198.51.100.7"}) {
+		t.Errorf("unexpected hop changes: %v", hops)
+	}
+	if change.AsPathBefore.String() != "* AS64500 AS64502_AS64503" ||
+		change.AsPathAfter.String() != "AS64500 AS64501 AS64502_AS64503" {
+		t.Errorf("unexpected AS paths: %s / %s", change.AsPathBefore, change.AsPathAfter)
+	}
+	if change.RttBefore != 6.0 || change.RttAfter != 30.0 || change.Key.ParisID != 3 ||
+		change.Key.Destination != "198.51.100.7" {
+		t.Errorf("unexpected change: %+v", change)
+	}
+
+	lost := parseTestTrace(t, 1, 1700002700, 3,
+		`{"hop":1,"result":[{"from":"192.0.2.1","rtt":1.0,"size":28,"ttl":64}]},`+
+			`{"hop":2,"result":[{"from":"192.0.2.130","rtt":20.0,"size":28,"ttl":63}]},`+
+			`{"hop":3,"result":[{"from":"192.0.2.131","rtt":25.0,"size":28,"ttl":62}]},`+
+			`{"hop":255,"result":[{"x":"*"}]}`)
+	change = detector.Add(lost)
+	if change == nil || !slices.Equal(change.Kinds(), []string{"path", "as", "reach"}) ||
+		!change.ReachedBefore || change.ReachedAfter || change.RttShifted {
+		t.Errorf("unexpected reachability change: %+v", change)
+	}
+	if detector.Len() != 1 {
+		t.Errorf("expected one series, got %d", detector.Len())
+	}
+}
@@ -0,0 +1,585 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// Topology combines traceroutes into a directed graph, either of the
+// addresses seen (IP level) or of the ASes they map to (AS level). Hops
+// without a response are represented by placeholder nodes, identified by
+// the nodes before and after them, so the same gap seen by different
+// traceroutes maps to the same placeholders.
+type Topology struct {
+	table   *AsnTable                         // to map addresses to ASes, if any
+	asLevel bool                              // AS level graph instead of IP level
+	nodes   map[string]*TopologyNode          //
+	edges   map[topologyEdgeKey]*TopologyEdge //
+	traces  uint                              // number of traceroutes added
+}
+
+// TopologyNodeKind tells what a node in the topology stands for
+type TopologyNodeKind int
+
+const (
+	TopologyProbe       TopologyNodeKind = iota // the probe that did the traceroute
+	TopologyAddress                             // an address that responded
+	TopologyAs                                  // an AS (or private, IXP, unannounced addresses)
+	TopologyPlaceholder                         // one or more hops without a response
+)
+
+// TopologyNodeKindNames translates node kinds to their names
+var TopologyNodeKindNames = map[TopologyNodeKind]string{
+	TopologyProbe:       "probe",
+	TopologyAddress:     "address",
+	TopologyAs:          "as",
+	TopologyPlaceholder: "placeholder",
+}
+
+func (kind TopologyNodeKind) String() string {
+	return TopologyNodeKindNames[kind]
+}
+
+// TopologyNode is a node in the topology
+type TopologyNode struct {
+	ID     string           // address or AS path element (@ the previous node if private), "probe:ID", or "*..." for placeholders
+	Kind   TopologyNodeKind //
+	Addr   netip.Addr       // for address nodes
+	AsHop  *AsHop           // AS of address nodes (if there's routing data) and of AS nodes
+	Count  uint             // number of traceroutes this node was seen in
+	Probes map[uint]uint    // number of traceroutes per probe this node was seen in
+}
+
+// TopologyEdge is a link between two nodes as seen by traceroutes
+type TopologyEdge struct {
+	From   string        // ID of the node closer to the probe
+	To     string        // ID of the node further away
+	Count  uint          // number of traceroutes this edge was seen in
+	Rtt    RttStats      // RTTs measured to the far end of the edge
+	Probes map[uint]uint // number of traceroutes per probe this edge was seen in
+}
+
+type topologyEdgeKey struct {
+	from string
+	to   string
+}
+
+// RttStats accumulates RTT statistics
+type RttStats struct {
+	Count uint    //
+	Min   float64 //
+	Max   float64 //
+	Sum   float64 //
+	SumSq float64 // sum of squares, for the standard deviation
+}
+
+// topologyStep is what a traceroute hop (IP level) or a path element (AS
+// level) contributes: its node(s), with the RTTs measured to them
+type topologyStep struct {
+	nodes []*TopologyNode
+	rtts  map[string][]float64
+}
+
+// NewTopology returns an empty IP level topology
+func NewTopology() Topology {
+	return Topology{
+		nodes: make(map[string]*TopologyNode),
+		edges: make(map[topologyEdgeKey]*TopologyEdge),
+	}
+}
+
+// AsnTable sets the routing data used to annotate address nodes with
+// their ASes
+func (topo *Topology) AsnTable(table *AsnTable) {
+	topo.table = table
+}
+
+// AsLevel turns this into an AS level topology, using the routing data to
+// map hops to ASes (see TracerouteResult.AsPath())
+func (topo *Topology) AsLevel(table *AsnTable) {
+	topo.table = table
+	topo.asLevel = true
+}
+
+// Traces returns the number of traceroutes added
+func (topo *Topology) Traces() uint {
+	return topo.traces
+}
+
+// Add adds the hops of a traceroute to the topology. The first node is
+// always the probe itself.
+func (topo *Topology) Add(trace *TracerouteResult) {
+	topo.traces++
+
+	probe := topo.node(fmt.Sprintf("probe:%d", trace.ProbeID), TopologyProbe)
+	var steps []topologyStep
+	if topo.asLevel {
+		steps = topo.asSteps(probe.ID, trace)
+	} else {
+		steps = topo.ipSteps(probe.ID, trace)
+	}
+	steps = topo.resolvePlaceholders(probe.ID, steps)
+
+	// nodes and edges are counted once per traceroute, RTTs every time
+	seenNodes := map[string]bool{probe.ID: true}
+	seenEdges := make(map[topologyEdgeKey]bool)
+	probe.Count++
+	probe.Probes[trace.ProbeID]++
+	prev := []*TopologyNode{probe}
+	for _, step := range steps {
+		for _, node := range step.nodes {
+			if !seenNodes[node.ID] {
+				seenNodes[node.ID] = true
+				node.Count++
+				node.Probes[trace.ProbeID]++
+			}
+		}
+		for _, from := range prev {
+			for _, to := range step.nodes {
+				if from == to {
+					continue
+				}
+				key := topologyEdgeKey{from.ID, to.ID}
+				edge := topo.edge(key)
+				if !seenEdges[key] {
+					seenEdges[key] = true
+					edge.Count++
+					edge.Probes[trace.ProbeID]++
+				}
+				for _, rtt := range step.rtts[to.ID] {
+					edge.Rtt.Add(rtt)
+				}
+			}
+		}
+		prev = step.nodes
+	}
+}
+
+// ipSteps makes a step of the responding addresses of each hop. Private
+// addresses are only unique behind the node before them.
+func (topo *Topology) ipSteps(before string, trace *TracerouteResult) []topologyStep {
+	steps := make([]topologyStep, 0, len(trace.Hops))
+	for _, hop := range trace.Hops {
+		step := topologyStep{rtts: make(map[string][]float64)}
+		for _, resp := range hop.Responses {
+			if resp.Timeout || resp.Error != nil || resp.Late != nil || !resp.From.IsValid() {
+				continue
+			}
+			id := resp.From.String()
+			if isPrivateAddr(resp.From) {
+				id += "@" + before
+			}
+			if _, ok := step.rtts[id]; !ok {
+				node := topo.node(id, TopologyAddress)
+				if node.AsHop == nil && topo.table != nil {
+					as := topo.table.Classify(resp.From)
+					node.AsHop = &as
+				}
+				node.Addr = resp.From
+				step.nodes = append(step.nodes, node)
+				step.rtts[id] = make([]float64, 0)
+			}
+			step.rtts[id] = append(step.rtts[id], resp.Rtt)
+		}
+		if len(step.nodes) > 0 {
+			before = step.nodes[0].ID
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// asSteps makes a step of each element of the AS path; the RTTs are the
+// ones measured to the first hop of the element. Private and unannounced
+// elements are only unique behind the node before them.
+func (topo *Topology) asSteps(before string, trace *TracerouteResult) []topologyStep {
+	path := trace.AsPath(topo.table)
+	steps := make([]topologyStep, 0, len(path))
+	for _, elem := range path {
+		step := topologyStep{rtts: make(map[string][]float64)}
+		if elem.Kind != AsHopUnresponsive {
+			id := elem.AsHop.String()
+			if elem.Kind == AsHopPrivate || elem.Kind == AsHopUnannounced {
+				id += "@" + before
+			}
+			before = id
+			node := topo.node(id, TopologyAs)
+			if node.AsHop == nil {
+				as := elem.AsHop
+				node.AsHop = &as
+			}
+			step.nodes = []*TopologyNode{node}
+			step.rtts[id] = make([]float64, 0)
+			for _, hop := range trace.Hops {
+				if hop.HopNumber != elem.FirstHop {
+					continue
+				}
+				addr := hop.Address()
+				for _, resp := range hop.Responses {
+					if resp.From == addr && !resp.Timeout && resp.Error == nil && resp.Late == nil {
+						step.rtts[id] = append(step.rtts[id], resp.Rtt)
+					}
+				}
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// resolvePlaceholders fills the steps without nodes with placeholders
+// named after the nodes around the gap. Unresponsive hops at the end are
+// collapsed into one placeholder.
+func (topo *Topology) resolvePlaceholders(first string, steps []topologyStep) []topologyStep {
+	last := len(steps) - 1
+	for last >= 0 && len(steps[last].nodes) == 0 {
+		last--
+	}
+	before := first
+	for i := 0; i <= last; i++ {
+		if len(steps[i].nodes) > 0 {
+			before = steps[i].nodes[0].ID
+			continue
+		}
+		gap := i
+		for len(steps[gap].nodes) == 0 {
+			gap++
+		}
+		after := steps[gap].nodes[0].ID
+		for k := i; k < gap; k++ {
+			id := fmt.Sprintf("*%s|%s#%d", before, after, k-i+1)
+			steps[k].nodes = []*TopologyNode{topo.node(id, TopologyPlaceholder)}
+		}
+		i = gap - 1
+	}
+	if last < len(steps)-1 {
+		steps[last+1].nodes = []*TopologyNode{topo.node("*"+before+"|", TopologyPlaceholder)}
+		steps = steps[:last+2]
+	}
+	return steps
+}
+
+func (topo *Topology) node(id string, kind TopologyNodeKind) *TopologyNode {
+	node, ok := topo.nodes[id]
+	if !ok {
+		node = &TopologyNode{ID: id, Kind: kind, Probes: make(map[uint]uint)}
+		topo.nodes[id] = node
+	}
+	return node
+}
+
+func (topo *Topology) edge(key topologyEdgeKey) *TopologyEdge {
+	edge, ok := topo.edges[key]
+	if !ok {
+		edge = &TopologyEdge{From: key.from, To: key.to, Probes: make(map[uint]uint)}
+		topo.edges[key] = edge
+	}
+	return edge
+}
+
+// Nodes returns the nodes ordered by their IDs
+func (topo *Topology) Nodes() []*TopologyNode {
+	nodes := make([]*TopologyNode, 0, len(topo.nodes))
+	for _, node := range topo.nodes {
+		nodes = append(nodes, node)
+	}
+	slices.SortFunc(nodes, func(a, b *TopologyNode) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return nodes
+}
+
+// Edges returns the edges ordered by the IDs of their ends
+func (topo *Topology) Edges() []*TopologyEdge {
+	edges := make([]*TopologyEdge, 0, len(topo.edges))
+	for _, edge := range topo.edges {
+		edges = append(edges, edge)
+	}
+	slices.SortFunc(edges, func(a, b *TopologyEdge) int {
+		if c := strings.Compare(a.From, b.From); c != 0 {
+			return c
+		}
+		return strings.Compare(a.To, b.To)
+	})
+	return edges
+}
+
+// Label returns a short description of the node
+func (node *TopologyNode) Label() string {
+	switch node.Kind {
+	case TopologyPlaceholder:
+		return "*"
+	case TopologyAddress:
+		if node.AsHop != nil {
+			return node.Addr.String() + "\n" + node.AsHop.String()
+		}
+		return node.Addr.String()
+	case TopologyAs:
+		return node.AsHop.String()
+	}
+	return node.ID
+}
+
+// ProbeIDs returns the probes that saw this node, in order
+func (node *TopologyNode) ProbeIDs() []uint {
+	return sortedProbeIDs(node.Probes)
+}
+
+// ProbeIDs returns the probes that saw this edge, in order
+func (edge *TopologyEdge) ProbeIDs() []uint {
+	return sortedProbeIDs(edge.Probes)
+}
+
+func sortedProbeIDs(probes map[uint]uint) []uint {
+	ids := make([]uint, 0, len(probes))
+	for id := range probes {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Add adds an RTT to the statistics
+func (stats *RttStats) Add(rtt float64) {
+	if stats.Count == 0 || rtt < stats.Min {
+		stats.Min = rtt
+	}
+	if stats.Count == 0 || rtt > stats.Max {
+		stats.Max = rtt
+	}
+	stats.Count++
+	stats.Sum += rtt
+	stats.SumSq += rtt * rtt
+}
+
+// Average returns the average RTT, or 0 if there are none
+func (stats *RttStats) Average() float64 {
+	if stats.Count == 0 {
+		return 0
+	}
+	return stats.Sum / float64(stats.Count)
+}
+
+// StdDev returns the (population) standard deviation of the RTTs
+func (stats *RttStats) StdDev() float64 {
+	if stats.Count == 0 {
+		return 0
+	}
+	avg := stats.Average()
+	return math.Sqrt(max(0, stats.SumSq/float64(stats.Count)-avg*avg))
+}
+
+//////////////////////////////////////////////////////
+// exporting
+
+// WriteDot writes the topology in Graphviz DOT format. Edges are labeled
+// with the number of traceroutes that saw them and the average RTT.
+func (topo *Topology) WriteDot(w io.Writer) error {
+	var out strings.Builder
+	out.WriteString("digraph topology {\n")
+	out.WriteString("\trankdir=LR;\n")
+	for _, node := range topo.Nodes() {
+		attrs := fmt.Sprintf("label=%s", dotQuote(node.Label()))
+		switch node.Kind {
+		case TopologyProbe:
+			attrs += ", shape=box"
+		case TopologyPlaceholder:
+			attrs += ", shape=circle, style=dashed"
+		default:
+			attrs += ", shape=ellipse"
+		}
+		fmt.Fprintf(&out, "\t%s [%s];\n", dotQuote(node.ID), attrs)
+	}
+	for _, edge := range topo.Edges() {
+		label := fmt.Sprintf("%d", edge.Count)
+		if edge.Rtt.Count > 0 {
+			label += fmt.Sprintf(" / %.1f ms", edge.Rtt.Average())
+		}
+		fmt.Fprintf(&out, "\t%s -> %s [label=%s];\n", dotQuote(edge.From), dotQuote(edge.To), dotQuote(label))
+	}
+	out.WriteString("}\n")
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + strings.ReplaceAll(s, "\n", `\n`) + `"`
+}
+
+type graphml struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphmlKey `xml:"key"`
+	Graph   graphmlGraph `xml:"graph"`
+}
+
+type graphmlKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphmlGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphmlNode `xml:"node"`
+	Edges       []graphmlEdge `xml:"edge"`
+}
+
+type graphmlNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphmlData `xml:"data"`
+}
+
+type graphmlEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphmlData `xml:"data"`
+}
+
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the topology in GraphML format
+func (topo *Topology) WriteGraphML(w io.Writer) error {
+	doc := graphml{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphmlKey{
+			{"kind", "node", "kind", "string"},
+			{"label", "node", "label", "string"},
+			{"as", "node", "as", "string"},
+			{"ncount", "node", "count", "int"},
+			{"nprobes", "node", "probes", "string"},
+			{"count", "edge", "count", "int"},
+			{"rttmin", "edge", "rtt_min", "double"},
+			{"rttavg", "edge", "rtt_avg", "double"},
+			{"rttmax", "edge", "rtt_max", "double"},
+			{"rttstddev", "edge", "rtt_stddev", "double"},
+			{"probes", "edge", "probes", "string"},
+		},
+		Graph: graphmlGraph{ID: "topology", EdgeDefault: "directed"},
+	}
+	for _, node := range topo.Nodes() {
+		data := []graphmlData{
+			{"kind", node.Kind.String()},
+			{"label", node.Label()},
+		}
+		if node.AsHop != nil {
+			data = append(data, graphmlData{"as", node.AsHop.String()})
+		}
+		data = append(data,
+			graphmlData{"ncount", fmt.Sprint(node.Count)},
+			graphmlData{"nprobes", joinProbeIDs(node.ProbeIDs())},
+		)
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphmlNode{node.ID, data})
+	}
+	for _, edge := range topo.Edges() {
+		data := []graphmlData{{"count", fmt.Sprint(edge.Count)}}
+		if edge.Rtt.Count > 0 {
+			data = append(data,
+				graphmlData{"rttmin", fmt.Sprint(edge.Rtt.Min)},
+				graphmlData{"rttavg", fmt.Sprint(edge.Rtt.Average())},
+				graphmlData{"rttmax", fmt.Sprint(edge.Rtt.Max)},
+				graphmlData{"rttstddev", fmt.Sprint(edge.Rtt.StdDev())},
+			)
+		}
+		data = append(data, graphmlData{"probes", joinProbeIDs(edge.ProbeIDs())})
+		doc.Graph.Edges = append(doc.Graph.Edges, graphmlEdge{edge.From, edge.To, data})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func joinProbeIDs(ids []uint) string {
+	list := make([]string, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, fmt.Sprint(id))
+	}
+	return strings.Join(list, " ")
+}
+
+type topologyNodeJSON struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Addr   string `json:"addr,omitempty"`
+	As     string `json:"as,omitempty"`
+	Count  uint   `json:"count"`
+	Probes []uint `json:"probes"`
+}
+
+type topologyEdgeJSON struct {
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Count     uint     `json:"count"`
+	RttMin    *float64 `json:"rtt_min,omitempty"`
+	RttAvg    *float64 `json:"rtt_avg,omitempty"`
+	RttMax    *float64 `json:"rtt_max,omitempty"`
+	RttStdDev *float64 `json:"rtt_stddev,omitempty"`
+	Probes    []uint   `json:"probes"`
+}
+
+// MarshalJSON turns the topology into a JSON object with a list of
+// nodes and a list of edges
+func (topo Topology) MarshalJSON() ([]byte, error) {
+	nodes := make([]topologyNodeJSON, 0, len(topo.nodes))
+	for _, node := range topo.Nodes() {
+		item := topologyNodeJSON{
+			ID:     node.ID,
+			Kind:   node.Kind.String(),
+			Count:  node.Count,
+			Probes: node.ProbeIDs(),
+		}
+		if node.Addr.IsValid() {
+			item.Addr = node.Addr.String()
+		}
+		if node.AsHop != nil {
+			item.As = node.AsHop.String()
+		}
+		nodes = append(nodes, item)
+	}
+	edges := make([]topologyEdgeJSON, 0, len(topo.edges))
+	for _, edge := range topo.Edges() {
+		item := topologyEdgeJSON{
+			From:   edge.From,
+			To:     edge.To,
+			Count:  edge.Count,
+			Probes: edge.ProbeIDs(),
+		}
+		if edge.Rtt.Count > 0 {
+			avg, stddev := edge.Rtt.Average(), edge.Rtt.StdDev()
+			item.RttMin, item.RttAvg, item.RttMax, item.RttStdDev = &edge.Rtt.Min, &avg, &edge.Rtt.Max, &stddev
+		}
+		edges = append(edges, item)
+	}
+	return json.Marshal(struct {
+		Traces uint               `json:"traces"`
+		Nodes  []topologyNodeJSON `json:"nodes"`
+		Edges  []topologyEdgeJSON `json:"edges"`
+	}{topo.traces, nodes, edges})
+}
@@ -0,0 +1,157 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package result
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// parseTestTrace makes a traceroute to 198.51.100.7 with the given hops
+func parseTestTrace(t *testing.T, probe uint, timestamp int64, paris uint, hops string) *TracerouteResult {
+	t.Helper()
+	var trace TracerouteResult
+	err := trace.Parse(fmt.Sprintf(`{"fw":5080,"type":"traceroute","msm_id":5001,"prb_id":%d,"timestamp":%d,`+
+		`"af":4,"dst_addr":"198.51.100.7","paris_id":%d,"result":[%s]}`, probe, timestamp, paris, hops))
+	if err != nil {
+		t.Fatalf("parsing failed: %v", err)
+	}
+	return &trace
+}
+
+func TestTopology(t *testing.T) {
+	topo := NewTopology()
+	topo.Add(parseTestTrace(t, 1, 1700000000, 1,
+		`{"hop":1,"result":[{"from":"192.0.2.1","rtt":1.0,"size":28,"ttl":64},{"from":"192.0.2.1","rtt":3.0,"size":28,"ttl":64}]},`+
+			`{"hop":2,"result":[{"x":"*"},{"x":"*"}]},`+
+			`{"hop":3,"result":[{"from":"198.51.100.7","rtt":5.0,"size":28,"ttl":62}]}`))
+	topo.Add(parseTestTrace(t, 2, 1700000000, 1,
+		`{"hop":1,"result":[{"from":"192.0.2.1","rtt":2.0,"size":28,"ttl":64}]},`+
+			`{"hop":2,"result":[{"x":"*"}]},`+
+			`{"hop":3,"result":[{"from":"198.51.100.7","rtt":6.0,"size":28,"ttl":62},{"from":"198.51.100.8","rtt":7.0,"size":28,"ttl":62}]},`+
+			`{"hop":4,"result":[{"x":"*"}]},`+
+			`{"hop":255,"result":[{"x":"*"}]}`))
+
+	if topo.Traces() != 2 {
+		t.Errorf("expected 2 traces, got %d", topo.Traces())
+	}
+
+	ids := make([]string, 0)
+	for _, node := range topo.Nodes() {
+		ids = append(ids, node.ID)
+	}
+	expected := []string{
+		"*192.0.2.1|198.51.100.7#1",
+		"*198.51.100.7|",
+		"192.0.2.1",
+		"198.51.100.7",
+		"198.51.100.8",
+		"probe:1",
+		"probe:2",
+	}
+	if !slices.Equal(ids, expected) {
+		t.Errorf("unexpected nodes: %v", ids)
+	}
+
+	edges := make(map[string]*TopologyEdge)
+	for _, edge := range topo.Edges() {
+		edges[edge.From+" "+edge.To] = edge
+	}
+	if len(edges) != 7 {
+		t.Errorf("expected 7 edges, got %d", len(edges))
+	}
+	first := edges["probe:1 192.0.2.1"]
+	if first == nil || first.Count != 1 || first.Rtt.Count != 2 || first.Rtt.Average() != 2.0 ||
+		math.Abs(first.Rtt.StdDev()-1.0) > 1e-9 {
+		t.Errorf("unexpected first edge: %+v", first)
+	}
+	gap := edges["192.0.2.1 *192.0.2.1|198.51.100.7#1"]
+	if gap == nil || gap.Count != 2 || gap.Rtt.Count != 0 || !slices.Equal(gap.ProbeIDs(), []uint{1, 2}) {
+		t.Errorf("unexpected placeholder edge: %+v", gap)
+	}
+	dest := edges["*192.0.2.1|198.51.100.7#1 198.51.100.7"]
+	if dest == nil || dest.Count != 2 || dest.Rtt.Min != 5.0 || dest.Rtt.Max != 6.0 {
+		t.Errorf("unexpected destination edge: %+v", dest)
+	}
+	if edges["*192.0.2.1|198.51.100.7#1 198.51.100.8"] == nil ||
+		edges["198.51.100.8 *198.51.100.7|"] == nil {
+		t.Errorf("parallel addresses are not linked")
+	}
+
+	var dot bytes.Buffer
+	if err := topo.WriteDot(&dot); err != nil {
+		t.Fatalf("writing DOT failed: %v", err)
+	}
+	if !strings.Contains(dot.String(), `"probe:1" -> "192.0.2.1" [label="1 / 2.0 ms"];`) {
+		t.Errorf("unexpected DOT output:\n%s", dot.String())
+	}
+
+	var gml bytes.Buffer
+	if err := topo.WriteGraphML(&gml); err != nil {
+		t.Fatalf("writing GraphML failed: %v", err)
+	}
+	var doc graphml
+	if err := xml.Unmarshal(gml.Bytes(), &doc); err != nil {
+		t.Fatalf("GraphML output doesn't parse: %v", err)
+	}
+	if len(doc.Graph.Nodes) != 7 || len(doc.Graph.Edges) != 7 {
+		t.Errorf("unexpected GraphML output:\n%s", gml.String())
+	}
+
+	data, err := json.Marshal(topo)
+	if err != nil {
+		t.Fatalf("marshaling failed: %v", err)
+	}
+	var parsed struct {
+		Traces uint
+		Nodes  []map[string]any
+		Edges  []map[string]any
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil || parsed.Traces != 2 ||
+		len(parsed.Nodes) != 7 || len(parsed.Edges) != 7 {
+		t.Errorf("unexpected JSON output: %s", data)
+	}
+}
+
+func TestTopologyAsLevel(t *testing.T) {
+	table := NewAsnTable()
+	table.ReadPfx2as(strings.NewReader(testPfx2as))
+
+	topo := NewTopology()
+	topo.AsLevel(&table)
+	topo.Add(parseTestTrace(t, 1, 1700000000, 1,
+		`{"hop":1,"result":[{"from":"192.168.1.1","rtt":1.0,"size":28,"ttl":64}]},`+
+			`{"hop":2,"result":[{"from":"192.0.2.1","rtt":2.0,"size":28,"ttl":63}]},`+
+			`{"hop":3,"result":[{"from":"192.0.2.2","rtt":3.0,"size":28,"ttl":62}]},`+
+			`{"hop":4,"result":[{"x":"*"}]},`+
+			`{"hop":5,"result":[{"from":"198.51.100.7","rtt":5.0,"size":28,"ttl":60}]}`))
+
+	edges := make([]string, 0)
+	for _, edge := range topo.Edges() {
+		edges = append(edges, edge.From+" "+edge.To)
+	}
+	expected := []string{
+		"*AS64500|AS64502_AS64503#1 AS64502_AS64503",
+		"AS64500 *AS64500|AS64502_AS64503#1",
+		"private@probe:1 AS64500",
+		"probe:1 private@probe:1",
+	}
+	if !slices.Equal(edges, expected) {
+		t.Errorf("unexpected edges: %v", edges)
+	}
+	for _, edge := range topo.Edges() {
+		if edge.To == "AS64500" && edge.Rtt.Average() != 2.0 {
+			t.Errorf("RTT should be the one to the first hop in the AS: %+v", edge.Rtt)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/netip"
+	"strconv"
 )
 
 type TracerouteResult struct {
@@ -20,6 +21,7 @@ type TracerouteResult struct {
 	PacketSize    uint            //
 	TypeOfService uint            //
 	Hops          []TracerouteHop //
+	hasTos        bool            // to only marshal the TOS if the result had it
 }
 
 // one hop - error or data
@@ -54,10 +56,15 @@ type IcmpExtension struct {
 	Objects []IcmpExtensionObject //
 }
 
+// IcmpExtensionObject is one object of an RFC 4884 extension. The Atlas
+// result format (https://atlas.ripe.net/docs/apis/result-format/) only
+// contains the class and the type of the objects, and the decoded label
+// stack of MPLS objects (class 1, type 1); the payload of other objects
+// (e.g. RFC 5837 interface information) is not available.
 type IcmpExtensionObject struct {
 	Class      uint         //
-	Type       uint         //
-	MplsObject []MplsObject //
+	Type       uint         // C-Type
+	MplsObject []MplsObject // MPLS label stack (class 1)
 }
 
 type MplsObject struct {
@@ -85,7 +92,10 @@ func (trace *TracerouteResult) Parse(from string) (err error) {
 	trace.EndTime = itrace.EndTime
 	trace.ParisID = itrace.ParisID
 	trace.PacketSize = itrace.PacketSize
-	trace.TypeOfService = itrace.TypeOfService
+	if itrace.TypeOfService != nil {
+		trace.TypeOfService = *itrace.TypeOfService
+		trace.hasTos = true
+	}
 
 	trace.Hops = make([]TracerouteHop, 0)
 	for _, ihop := range itrace.RawResult {
@@ -97,6 +107,10 @@ func (trace *TracerouteResult) Parse(from string) (err error) {
 			continue
 		}
 		hop.Responses = make([]TraceRouteHopData, 0)
+		if ihop.HopData == nil {
+			trace.Hops = append(trace.Hops, hop)
+			continue
+		}
 		for _, ihopdata := range *ihop.HopData {
 			hopdata := TraceRouteHopData{}
 			if ihopdata.Timeout != nil {
@@ -112,7 +126,7 @@ func (trace *TracerouteResult) Parse(from string) (err error) {
 			if ihopdata.ErrorCode != nil {
 				hopdata.ErrorCode = fmt.Sprint(*ihopdata.ErrorCode)
 			}
-			hopdata.From = ihopdata.From
+			hopdata.From = derefAddr(ihopdata.From)
 			if ihopdata.Size != nil {
 				hopdata.Size = *ihopdata.Size
 			}
@@ -121,6 +135,7 @@ func (trace *TracerouteResult) Parse(from string) (err error) {
 			}
 			if ihopdata.Late != nil {
 				hopdata.Late = ihopdata.Late
+				hop.Responses = append(hop.Responses, hopdata)
 				continue // no other data is it was a LATE packet
 			}
 			if ihopdata.Rtt != nil {
@@ -156,12 +171,11 @@ func (trace *TracerouteResult) Parse(from string) (err error) {
 					iext.Rfc4884,
 					make([]IcmpExtensionObject, 0),
 				}
-				hopdata.IcmpExtensions = append(hopdata.IcmpExtensions, ext)
 				for _, iextobj := range iext.Objects {
 					extobj := IcmpExtensionObject{
-						iextobj.Class,
-						iextobj.Type,
-						make([]MplsObject, 0),
+						Class:      iextobj.Class,
+						Type:       iextobj.Type,
+						MplsObject: make([]MplsObject, 0),
 					}
 					if iextobj.MplsObject != nil {
 						for _, implsobj := range *iextobj.MplsObject {
@@ -177,6 +191,7 @@ func (trace *TracerouteResult) Parse(from string) (err error) {
 					}
 					ext.Objects = append(ext.Objects, extobj)
 				}
+				hopdata.IcmpExtensions = append(hopdata.IcmpExtensions, ext)
 			}
 			hop.Responses = append(hop.Responses, hopdata)
 		}
@@ -186,6 +201,88 @@ func (trace *TracerouteResult) Parse(from string) (err error) {
 	return nil
 }
 
+// MarshalJSON turns the result back into the format used by the API
+func (trace TracerouteResult) MarshalJSON() ([]byte, error) {
+	itrace := tracerouteResult{
+		BaseResult: trace.BaseResult,
+		EndTime:    trace.EndTime,
+		ParisID:    trace.ParisID,
+		Protocol:   trace.Protocol,
+		PacketSize: trace.PacketSize,
+		RawResult:  make([]rawTraceHop, 0),
+	}
+	if trace.hasTos || trace.TypeOfService != 0 {
+		itrace.TypeOfService = &trace.TypeOfService
+	}
+
+	for _, hop := range trace.Hops {
+		ihop := rawTraceHop{HopNumber: hop.HopNumber, SendError: hop.SendError}
+		if hop.SendError == nil {
+			hopdata := make([]rawTraceHopData, 0)
+			for _, resp := range hop.Responses {
+				hopdata = append(hopdata, resp.raw())
+			}
+			ihop.HopData = &hopdata
+		}
+		itrace.RawResult = append(itrace.RawResult, ihop)
+	}
+
+	return json.Marshal(struct {
+		rawBaseResult
+		tracerouteResult
+	}{trace.BaseResult.raw(), itrace})
+}
+
+// raw turns one response back into the API format
+func (resp TraceRouteHopData) raw() rawTraceHopData {
+	if resp.Timeout {
+		timeout := "*"
+		return rawTraceHopData{Timeout: &timeout}
+	}
+	if resp.Error != nil {
+		return rawTraceHopData{Error: resp.Error}
+	}
+
+	iresp := rawTraceHopData{
+		From: addrOrNil(resp.From),
+		Size: &resp.Size,
+		Ttl:  &resp.Ttl,
+	}
+	if resp.ErrorCode != "" {
+		code := errorCode(resp.ErrorCode)
+		iresp.ErrorCode = &code
+	}
+	if resp.Late != nil {
+		iresp.Late = resp.Late
+		return iresp
+	}
+	iresp.Rtt = &resp.Rtt
+	iresp.ITtl = resp.ITtl
+	iresp.ITypeOfService = resp.ITypeOfService
+	iresp.ErrorDestination = resp.ErrorDestination
+	iresp.Mtu = resp.Mtu
+	iresp.Flags = resp.Flags
+	iresp.DestOptSize = resp.DestOptSize
+	iresp.HopByHopOptSize = resp.HopByHopOptSize
+	if len(resp.IcmpExtensions) > 0 {
+		ext := resp.IcmpExtensions[0]
+		iext := rawIcmpExtension{ext.Version, ext.Rfc4884, make([]rawIcmpExtensionObject, 0)}
+		for _, obj := range ext.Objects {
+			iobj := rawIcmpExtensionObject{Class: obj.Class, Type: obj.Type}
+			if len(obj.MplsObject) > 0 {
+				mpls := make([]rawMplsObject, 0)
+				for _, m := range obj.MplsObject {
+					mpls = append(mpls, rawMplsObject{m.Label, m.BottomOfStack, m.Ttl, m.Experimental})
+				}
+				iobj.MplsObject = &mpls
+			}
+			iext.Objects = append(iext.Objects, iobj)
+		}
+		iresp.IcmpExtension = &iext
+	}
+	return iresp
+}
+
 func (trace *TracerouteResult) DestinationReached() bool {
 	if len(trace.Hops) == 0 {
 		return false
@@ -205,23 +302,31 @@ func (trace *TracerouteResult) DestinationReached() bool {
 
 type tracerouteResult struct {
 	BaseResult
-	EndTime       uniTime       `json:"endtime"`  //
-	ParisID       uint          `json:"paris_id"` //
-	Protocol      string        `json:"proto"`    //
-	PacketSize    uint          `json:"size"`     //
-	TypeOfService uint          `json:"tos"`      //
-	RawResult     []rawTraceHop `json:"result"`   //
+	EndTime       uniTime       `json:"endtime"`       //
+	ParisID       uint          `json:"paris_id"`      //
+	Protocol      string        `json:"proto"`         //
+	PacketSize    uint          `json:"size"`          //
+	TypeOfService *uint         `json:"tos,omitempty"` //
+	RawResult     []rawTraceHop `json:"result"`        //
 }
 
 // one hop - error or data
 type rawTraceHop struct {
-	HopNumber uint               `json:"hop"`    //
-	SendError *string            `json:"error"`  //
-	HopData   *[]rawTraceHopData `json:"result"` //
+	HopNumber uint               `json:"hop"`              //
+	SendError *string            `json:"error,omitempty"`  //
+	HopData   *[]rawTraceHopData `json:"result,omitempty"` //
 }
 
 type errorCode string
 
+// numeric error codes are numbers in the API
+func (e errorCode) MarshalJSON() ([]byte, error) {
+	if _, err := strconv.Atoi(string(e)); err == nil {
+		return []byte(e), nil
+	}
+	return json.Marshal(string(e))
+}
+
 func (e *errorCode) UnmarshalJSON(b []byte) error {
 	var val any
 	if err := json.Unmarshal(b, &val); err != nil {
@@ -242,22 +347,22 @@ func (e *errorCode) UnmarshalJSON(b []byte) error {
 
 // one hop detail
 type rawTraceHopData struct {
-	Timeout          *string           `json:"x"`          //
-	Error            *string           `json:"error"`      //
-	ErrorCode        *errorCode        `json:"err"`        // N/H/A/P/p/h/(int)
-	From             netip.Addr        `json:"from"`       //
-	ITypeOfService   *uint             `json:"itos"`       //
-	ITtl             *uint             `json:"ittl"`       //
-	ErrorDestination *netip.Addr       `json:"edst"`       //
-	Late             *uint             `json:"late"`       //
-	Mtu              *uint             `json:"mtu"`        //
-	Rtt              *float64          `json:"rtt"`        //
-	Size             *uint             `json:"size"`       //
-	Ttl              *int              `json:"ttl"`        //
-	Flags            *string           `json:"flags"`      //
-	DestOptSize      *uint             `json:"dstoptsize"` //
-	HopByHopOptSize  *uint             `json:"hbhoptsize"` //
-	IcmpExtension    *rawIcmpExtension `json:"icmpext"`    //
+	Timeout          *string           `json:"x,omitempty"`          //
+	Error            *string           `json:"error,omitempty"`      //
+	ErrorCode        *errorCode        `json:"err,omitempty"`        // N/H/A/P/p/h/(int)
+	From             *netip.Addr       `json:"from,omitempty"`       //
+	ITypeOfService   *uint             `json:"itos,omitempty"`       //
+	ITtl             *uint             `json:"ittl,omitempty"`       //
+	ErrorDestination *netip.Addr       `json:"edst,omitempty"`       //
+	Late             *uint             `json:"late,omitempty"`       //
+	Mtu              *uint             `json:"mtu,omitempty"`        //
+	Rtt              *float64          `json:"rtt,omitempty"`        //
+	Size             *uint             `json:"size,omitempty"`       //
+	Ttl              *int              `json:"ttl,omitempty"`        //
+	Flags            *string           `json:"flags,omitempty"`      //
+	DestOptSize      *uint             `json:"dstoptsize,omitempty"` //
+	HopByHopOptSize  *uint             `json:"hbhoptsize,omitempty"` //
+	IcmpExtension    *rawIcmpExtension `json:"icmpext,omitempty"`    //
 }
 
 type rawIcmpExtension struct {
@@ -267,9 +372,9 @@ type rawIcmpExtension struct {
 }
 
 type rawIcmpExtensionObject struct {
-	Class      uint             `json:"class"` //
-	Type       uint             `json:"type"`  //
-	MplsObject *[]rawMplsObject `json:"mpls"`  //
+	Class      uint             `json:"class"`          //
+	Type       uint             `json:"type"`           //
+	MplsObject *[]rawMplsObject `json:"mpls,omitempty"` //
 }
 
 type rawMplsObject struct {
@@ -35,6 +35,14 @@ func (uptime *UptimeResult) Parse(from string) (err error) {
 	return nil
 }
 
+// MarshalJSON turns the result back into the format used by the API
+func (uptime UptimeResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		rawBaseResult
+		uptimeResult
+	}{uptime.BaseResult.raw(), uptimeResult{uptime.BaseResult, uptime.Uptime}})
+}
+
 //////////////////////////////////////////////////////
 // API version of an uptime result
 
@@ -8,9 +8,11 @@ package goat
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
+	"iter"
 	"net/url"
-	"os"
 	"slices"
 	"strings"
 	"time"
@@ -22,6 +24,7 @@ import (
 
 // ResultsFilter struct holds specified filters and other options
 type ResultsFilter struct {
+	client       *Client
 	params       url.Values
 	id           uint   // which measurement
 	file         string // which file to read from
@@ -34,15 +37,33 @@ type ResultsFilter struct {
 	latest       bool
 	lookbackdays uint // used for latest results
 	typehint     string
-	saveFile     *os.File // save results to this file (if not nil)
+	saveFile     io.Writer // save results to this file (if not nil)
 	saveAll      bool
 	timeout      time.Duration
 	backlog      bool
+	speed        uint   // stream replay speed
+	reconnect    bool   // reconnect the stream if it's lost?
+	parallel     uint   // number of workers for sharded downloads
+	dump         bool   // mixed results, as in the daily dumps
+	msmIDs       []uint // these are checked before parsing
+	resultType   string
+	af           uint
+	destination  string
+	verbose      bool
+	seen         map[resultKey]bool // for deduplication when reconnecting
+	lastSeen     time.Time          // latest result timestamp seen on the stream
+	pruned       time.Time          // lastSeen when seen was last pruned
 }
 
-// NewResultsFilter prepares a new result filter object
+// NewResultsFilter prepares a new result filter object using the default client
 func NewResultsFilter() ResultsFilter {
+	return defaultClient.NewResultsFilter()
+}
+
+// NewResultsFilter prepares a new result filter object using this client
+func (client *Client) NewResultsFilter() ResultsFilter {
 	filter := ResultsFilter{}
+	filter.client = client
 	filter.params = url.Values{}
 	filter.params.Add("format", "txt")
 	filter.probes = make([]uint, 0)
@@ -99,8 +120,9 @@ func (filter *ResultsFilter) FilterLatestLookbackDays(days uint) {
 	filter.params.Add("lookback_days", fmt.Sprintf("%d", days))
 }
 
-// Save the results to this particular file
-func (filter *ResultsFilter) Save(file *os.File) {
+// Save the results to this particular file (or any writer). Use
+// CreateResultFile() to make one that compresses the results.
+func (filter *ResultsFilter) Save(file io.Writer) {
 	filter.saveFile = file
 }
 
@@ -124,6 +146,26 @@ func (filter *ResultsFilter) SendBacklog(backlog bool) {
 	filter.backlog = backlog
 }
 
+// StreamSpeed sets the speed of replaying past results on the stream,
+// e.g. 10 means ten times faster than real time. Replay is used when
+// streaming with a start time (see FilterStart and FilterStop).
+func (filter *ResultsFilter) StreamSpeed(speed uint) {
+	filter.speed = speed
+}
+
+// Reconnect turns on/off automatic reconnection of the stream. If the
+// connection is lost, goat reconnects with backoff, fetches results that
+// were missed in the meantime via the data API, and drops duplicates.
+// Reconnections are reported as (non-fatal) StreamReconnectError errors.
+func (filter *ResultsFilter) Reconnect(reconnect bool) {
+	filter.reconnect = reconnect
+}
+
+// Verbose turns on verbose mode, same as the verbose parameter of GetResults
+func (filter *ResultsFilter) Verbose(verbose bool) {
+	filter.verbose = verbose
+}
+
 // Limit limits the number of result retrieved
 func (filter *ResultsFilter) Limit(max uint) {
 	filter.limit = max
@@ -134,6 +176,15 @@ func (filter *ResultsFilter) verifyFilters() error {
 	if filter.id == 0 && filter.file == "" {
 		return fmt.Errorf("ID or filename must be specified")
 	}
+	if filter.speed != 0 && (!filter.stream || filter.start == nil) {
+		return fmt.Errorf("replay speed can only be used when streaming with a start time")
+	}
+	if filter.stream && filter.start != nil && filter.stop != nil && !filter.stop.After(*filter.start) {
+		return fmt.Errorf("replay stop time should be after the start time")
+	}
+	if filter.parallel > 1 && (filter.stream || filter.latest || filter.file != "") {
+		return fmt.Errorf("parallel downloading cannot be used with streaming, latest results or files")
+	}
 
 	return nil
 }
@@ -144,55 +195,111 @@ func (filter *ResultsFilter) GetResults(
 	verbose bool,
 	results chan result.AsyncResult,
 ) {
+	filter.GetResultsContext(context.Background(), verbose, results)
+}
+
+// GetResultsContext is like GetResults, but it stops fetching (or streaming)
+// results and closes the channel when the context is cancelled
+func (filter *ResultsFilter) GetResultsContext(
+	ctx context.Context,
+	verbose bool,
+	results chan result.AsyncResult,
+) {
+	verbose = verbose || filter.verbose
+
 	var err error
 	switch {
 	case filter.id != 0 && !filter.stream:
-		err = filter.downloadResults(verbose, results)
+		err = filter.downloadResults(ctx, verbose, results)
 	case filter.id != 0 && filter.stream:
-		err = filter.streamResults(verbose, results)
+		err = filter.streamResults(ctx, verbose, results)
 	case filter.id == 0 && filter.stream:
 		err = fmt.Errorf("no ID was speficied for stream")
 	case filter.file != "":
-		err = filter.getFileResults(verbose, results)
+		err = filter.getFileResults(ctx, verbose, results)
 	default:
 		err = fmt.Errorf("neither ID nor input file were specified")
 	}
 
 	if err != nil {
-		results <- result.AsyncResult{Result: nil, Error: err}
+		sendContext(ctx, results, result.AsyncResult{Result: nil, Error: err})
 		close(results)
 	}
 }
 
+// Results is an iterator over the results, from whichever source the
+// filter uses. Breaking out of the loop stops the download or the stream.
+// Errors don't necessarily end the iteration.
+func (filter *ResultsFilter) Results(ctx context.Context) iter.Seq2[result.Result, error] {
+	list := func(ctx context.Context, results chan result.AsyncResult) {
+		filter.GetResultsContext(ctx, filter.verbose, results)
+	}
+	return iterate(ctx, list, splitResult)
+}
+
 // DownloadResults returns results from the data API
 // via a channel by applying the specified filters
 func (filter *ResultsFilter) downloadResults(
+	ctx context.Context,
 	verbose bool,
 	results chan result.AsyncResult,
 ) error {
+	if filter.parallel > 1 {
+		return filter.parallelDownload(ctx, verbose, results)
+	}
+
 	// prepare to read results
-	read, err := filter.openNetworkResults(verbose)
+	read, err := filter.openNetworkResults(ctx, verbose)
 	if err != nil {
 		return err
 	}
 
-	return filter.readResults(verbose, read, results)
+	return filter.readResults(ctx, verbose, read, results)
 }
 
 // StreamResults returns results from the streaming API
 // via a channel by applying the specified filters
 func (filter *ResultsFilter) streamResults(
+	ctx context.Context,
 	verbose bool,
 	results chan result.AsyncResult,
 ) error {
-	// connect to the streaming API
-	conn, _, err := websocket.DefaultDialer.Dial(streamBaseURL+"?client=goat_"+version, nil)
+	if err := filter.verifyFilters(); err != nil {
+		return err
+	}
+
+	if filter.reconnect {
+		go filter.reconnectingStream(ctx, verbose, results)
+		return nil
+	}
+
+	conn, err := filter.connectStream(ctx, verbose, filter.backlog, filter.start)
 	if err != nil {
-		results <- result.AsyncResult{Result: nil, Error: err}
+		sendContext(ctx, results, result.AsyncResult{Result: nil, Error: err})
 		close(results)
 		return nil
 	}
 
+	// handle the results coming from the websocket
+	go filter.streamReceiveHandler(ctx, verbose, conn, results)
+
+	return nil
+}
+
+// connectStream connects to the streaming API and subscribes; if there's
+// a start time then it asks for a replay of results from then on
+func (filter *ResultsFilter) connectStream(
+	ctx context.Context,
+	verbose bool,
+	backlog bool,
+	start *time.Time,
+) (*websocket.Conn, error) {
+	client := clientOrDefault(filter.client)
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, client.streamBaseURL+"?client=goat_"+version, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	if filter.timeout != 0 {
 		err = conn.SetReadDeadline(time.Now().Add(filter.timeout))
 		if err != nil && verbose {
@@ -200,9 +307,6 @@ func (filter *ResultsFilter) streamResults(
 		}
 	}
 
-	// handle the results coming from the websocket
-	go filter.streamReceiveHandler(verbose, conn, results)
-
 	// using types and marshaling may be overkill - but it's flexible
 	subscription := make([]any, 2)
 	subscription[0] = "atlas_subscribe"
@@ -210,79 +314,112 @@ func (filter *ResultsFilter) streamResults(
 		StreamType  string `json:"streamType"`
 		Measurement uint   `json:"msm"`
 		SendBacklog bool   `json:"sendBacklog"`
+		StartTime   int64  `json:"startTime,omitempty"`
+		StopTime    int64  `json:"stopTime,omitempty"`
+		Speed       uint   `json:"speed,omitempty"`
 	}
-	subscription[1] = params{"result", filter.id, filter.backlog}
+	subParams := params{StreamType: "result", Measurement: filter.id, SendBacklog: backlog}
+	if start != nil {
+		subParams.StartTime = start.Unix()
+		if filter.stop != nil {
+			subParams.StopTime = filter.stop.Unix()
+		}
+		subParams.Speed = filter.speed
+	}
+	subscription[1] = subParams
 
 	err = conn.WriteJSON(subscription)
 	if err != nil {
-		return err
+		conn.Close()
+		return nil, err
 	}
 
 	if verbose {
 		fmt.Printf("# Stream parameters: %+v\n", subscription[1])
 	}
-	return nil
+	return conn, nil
 }
 
 // getFileResults returns results from a file via a channel
 // If the file is "-" then it reads from stdin
 func (filter *ResultsFilter) getFileResults(
+	ctx context.Context,
 	verbose bool,
 	results chan result.AsyncResult,
 ) error {
-	var file *os.File
-	if filter.file == "-" {
-		file = os.Stdin
-		if verbose {
-			fmt.Printf("# Reading results from stdin\n")
-		}
-	} else {
-		var err error
-		file, err = os.Open(filter.file)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
+	file, err := OpenResultFile(filter.file)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
-		if verbose {
+	if verbose {
+		if filter.file == "-" {
+			fmt.Printf("# Reading results from stdin\n")
+		} else {
 			fmt.Printf("# Reading results from file: %s\n", filter.file)
 		}
 	}
 
-	read := bufio.NewScanner(bufio.NewReader(file))
+	read := bufio.NewScanner(file)
+	read.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
 
-	return filter.readResults(verbose, read, results)
+	return filter.readResults(ctx, verbose, read, results)
 }
 
 func (filter *ResultsFilter) readResults(
+	ctx context.Context,
 	verbose bool,
 	read *bufio.Scanner,
 	results chan result.AsyncResult,
 ) error {
 	defer close(results)
 
-	for read.Scan() && (filter.limit == 0 || filter.fetched < filter.limit) {
+	for ctx.Err() == nil && read.Scan() && (filter.limit == 0 || filter.fetched < filter.limit) {
 		line := read.Text()
-		filter.processResult(line, verbose, results)
+		filter.processResult(ctx, line, verbose, results)
 	}
 
 	return nil
 }
 
 func (filter *ResultsFilter) streamReceiveHandler(
+	ctx context.Context,
 	verbose bool,
 	connection *websocket.Conn,
 	results chan result.AsyncResult,
 ) {
-	defer connection.Close()
 	defer close(results)
 
+	err := filter.receiveStream(ctx, verbose, connection, results)
+	if err != nil {
+		sendContext(ctx, results, result.AsyncResult{Result: nil, Error: err})
+	}
+}
+
+// receiveStream processes messages from the stream until the connection
+// is lost (this is returned as an error), or the context is cancelled or
+// the limit is reached (these are not errors)
+func (filter *ResultsFilter) receiveStream(
+	ctx context.Context,
+	verbose bool,
+	connection *websocket.Conn,
+	results chan result.AsyncResult,
+) error {
+	defer connection.Close()
+
+	// closing the connection unblocks the reader if the context is cancelled
+	stop := context.AfterFunc(ctx, func() { connection.Close() })
+	defer stop()
+
 	for {
 		_, msg, err := connection.ReadMessage()
 		if err != nil {
-			err := fmt.Errorf("disconnected")
-			results <- result.AsyncResult{Result: nil, Error: err}
-			return
+			if ctx.Err() != nil {
+				// we were asked to stop, this is not an error
+				return nil
+			}
+			return &streamDisconnectError{err}
 		}
 
 		if filter.timeout != 0 {
@@ -298,61 +435,96 @@ func (filter *ResultsFilter) streamReceiveHandler(
 		const expectedBacklogSentPrefix = "[\"atlas_backlog_sent\","
 		const expectedErrorPrefix = "[\"atlas_error\","
 
+		text := string(msg)
 		switch {
-		case expectedResultPrefix == string(msg[:len(expectedResultPrefix)]):
+		case strings.HasPrefix(text, expectedResultPrefix):
 			// cool, a result
-		case expectedSubscribePrefix == string(msg[:len(expectedSubscribePrefix)]):
+		case strings.HasPrefix(text, expectedSubscribePrefix):
 			// cool, subscribe has been confirmed
 			continue
-		case expectedBacklogSentPrefix == string(msg[:len(expectedBacklogSentPrefix)]):
+		case strings.HasPrefix(text, expectedBacklogSentPrefix):
 			// cool, backlog is done
 			continue
-		case expectedErrorPrefix == string(msg[:len(expectedErrorPrefix)]):
-			fmt.Printf("# WARNING: error message received from stream: %v\n", msg)
+		case strings.HasPrefix(text, expectedErrorPrefix):
+			fmt.Printf("# WARNING: error message received from stream: %v\n", text)
 			continue
 		default:
-			err := fmt.Errorf("unknown stream message received: %v", string(msg))
-			results <- result.AsyncResult{Result: nil, Error: err}
-			return
+			return fmt.Errorf("unknown stream message received: %v", text)
 		}
 
-		pduresult := strings.TrimPrefix(string(msg), expectedResultPrefix)
+		pduresult := strings.TrimPrefix(text, expectedResultPrefix)
 		pduresult = strings.TrimSuffix(pduresult, "]")
 
-		filter.processResult(pduresult, verbose, results)
+		filter.processResult(ctx, pduresult, verbose, results)
 
-		if filter.limit > 0 && filter.fetched >= filter.limit {
-			return
+		if ctx.Err() != nil || filter.limitReached() {
+			return nil
 		}
-
 	}
 }
 
+// limitReached tells if we have enough results
+func (filter *ResultsFilter) limitReached() bool {
+	return filter.limit > 0 && filter.fetched >= filter.limit
+}
+
 func (filter *ResultsFilter) processResult(
+	ctx context.Context,
+	resultString string,
+	verbose bool,
+	results chan result.AsyncResult,
+) {
+	match, typehint := filter.prefilter(resultString, filter.typehint)
+	if !match {
+		if filter.saveAll {
+			filter.saveResult(ctx, resultString, verbose, results)
+		}
+		return
+	}
+
+	res, err := result.ParseWithTypeHint(resultString, typehint)
+	filter.deliverResult(ctx, resultString, res, err, verbose, results)
+}
+
+// saveResult saves the result to the file, if needed
+func (filter *ResultsFilter) saveResult(
+	ctx context.Context,
 	resultString string,
 	verbose bool,
 	results chan result.AsyncResult,
 ) {
-	saveResult := func() {
-		if filter.saveFile != nil {
-			_, err := filter.saveFile.WriteString(resultString + "\n")
-			if err != nil {
-				if verbose {
-					fmt.Printf("# WARNING: error writing to file: %v\n", err)
-				}
-				results <- result.AsyncResult{Result: nil, Error: err}
+	if filter.saveFile != nil {
+		_, err := io.WriteString(filter.saveFile, resultString+"\n")
+		if err != nil {
+			if verbose {
+				fmt.Printf("# WARNING: error writing to file: %v\n", err)
 			}
-			// continue regardless of whether writing was successful
+			sendContext(ctx, results, result.AsyncResult{Result: nil, Error: err})
 		}
+		// continue regardless of whether writing was successful
+	}
+}
+
+// deliverResult saves, filters and sends a result that was already parsed
+// (or failed to parse)
+func (filter *ResultsFilter) deliverResult(
+	ctx context.Context,
+	resultString string,
+	res result.Result,
+	err error,
+	verbose bool,
+	results chan result.AsyncResult,
+) {
+	if err == nil && filter.seen != nil && filter.isDuplicate(res) {
+		return
 	}
 
 	if filter.saveAll {
-		saveResult()
+		filter.saveResult(ctx, resultString, verbose, results)
 	}
 
-	res, err := result.ParseWithTypeHint(resultString, filter.typehint)
 	if err != nil {
-		results <- result.AsyncResult{Result: nil, Error: err}
+		sendContext(ctx, results, result.AsyncResult{Result: nil, Error: err})
 		return
 	}
 
@@ -362,22 +534,23 @@ func (filter *ResultsFilter) processResult(
 	if (filter.start == nil || filter.start.Before(ts.Add(time.Duration(1)))) &&
 		(filter.stop == nil || filter.stop.After(ts.Add(time.Duration(-1)))) &&
 		(len(filter.probes) == 0 || slices.Contains(filter.probes, res.GetProbeID())) {
-		results <- result.AsyncResult{Result: &res, Error: nil}
+		sendContext(ctx, results, result.AsyncResult{Result: &res, Error: nil})
 		filter.fetched++
 
 		if !filter.saveAll {
-			saveResult()
+			filter.saveResult(ctx, resultString, verbose, results)
 		}
 	}
 
 	// a type hint makes parsing much faster
-	if filter.typehint == "" {
+	if filter.typehint == "" && !filter.dump {
 		filter.typehint = res.TypeName()
 	}
 }
 
 // prepare fetching results, i.e. verify parameters, connect to the API, etc.
 func (filter *ResultsFilter) openNetworkResults(
+	ctx context.Context,
 	verbose bool,
 ) (
 	read *bufio.Scanner,
@@ -389,7 +562,8 @@ func (filter *ResultsFilter) openNetworkResults(
 		return nil, err
 	}
 
-	query := fmt.Sprintf("%smeasurements/%d/", apiBaseURL, filter.id)
+	client := clientOrDefault(filter.client)
+	query := fmt.Sprintf("%smeasurements/%d/", client.apiBaseURL, filter.id)
 	if filter.latest {
 		query += "latest/"
 	} else {
@@ -397,7 +571,7 @@ func (filter *ResultsFilter) openNetworkResults(
 	}
 	query += fmt.Sprintf("?%s", filter.params.Encode())
 
-	resp, err := apiGetRequest(verbose, query, nil)
+	resp, err := client.apiGetRequest(ctx, verbose, query, client.apiKeyFor(nil, ApiKeyListMeasurements))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,148 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+// Test if cancelling the context closes a (silent) stream cleanly
+func TestStreamContextCancel(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// read the subscription, then stay silent until the client goes away
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.SetStreamBase("ws" + strings.TrimPrefix(server.URL, "http") + "/")
+	filter := client.NewResultsFilter()
+	filter.FilterID(1001)
+	filter.Stream(true)
+	filter.StreamTimeout(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan result.AsyncResult)
+	go filter.GetResultsContext(ctx, false, results)
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case res, ok := <-results:
+		if ok {
+			t.Errorf("expected a closed channel, got %+v", res)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel was not closed after the context was cancelled")
+	}
+}
+
+// Test if replay parameters are sent when streaming with a start time
+func TestStreamReplay(t *testing.T) {
+	subscriptions := make(chan map[string]any, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var msg []any
+		if err := conn.ReadJSON(&msg); err != nil || len(msg) != 2 {
+			return
+		}
+		subscriptions <- msg[1].(map[string]any)
+		_ = conn.WriteMessage(websocket.TextMessage,
+			[]byte(`["atlas_result",{"fw":5080,"type":"ping","msm_id":1001,"prb_id":1,"timestamp":1700000100,"result":[]}]`))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.SetStreamBase("ws" + strings.TrimPrefix(server.URL, "http") + "/")
+
+	filter := client.NewResultsFilter()
+	filter.FilterID(1001)
+	filter.StreamSpeed(10)
+	results := make(chan result.AsyncResult)
+	go filter.GetResults(false, results)
+	if res := <-results; res.Error == nil {
+		t.Error("replay speed without streaming was accepted")
+	}
+
+	filter = client.NewResultsFilter()
+	filter.FilterID(1001)
+	filter.Stream(true)
+	filter.FilterStart(time.Unix(1700000000, 0))
+	filter.FilterStop(time.Unix(1700003600, 0))
+	filter.StreamSpeed(10)
+	filter.Limit(1)
+	results = make(chan result.AsyncResult)
+	go filter.GetResults(false, results)
+	for res := range results {
+		if res.Error != nil {
+			t.Fatalf("replay failed: %v", res.Error)
+		}
+	}
+
+	params := <-subscriptions
+	if params["startTime"] != 1700000000.0 || params["stopTime"] != 1700003600.0 || params["speed"] != 10.0 {
+		t.Errorf("replay parameters were not sent properly: %v", params)
+	}
+}
+
+// Test if deduplication state is pruned while a stream is running
+func TestDeduplicationPruning(t *testing.T) {
+	filter := NewResultsFilter()
+	filter.seen = make(map[resultKey]bool)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 120; i++ {
+		ts := start.Add(time.Duration(i) * 30 * time.Second).Unix()
+		res, err := result.Parse(fmt.Sprintf(
+			`{"fw":5080,"type":"ping","msm_id":1001,"prb_id":%d,"timestamp":%d,"af":4,"dst_addr":"192.0.2.1","result":[{"rtt":1.0}]}`,
+			i%3+1, ts))
+		if err != nil {
+			t.Fatalf("parsing failed: %v", err)
+		}
+		if filter.isDuplicate(res) {
+			t.Fatalf("result %d is not a duplicate", i)
+		}
+		if !filter.isDuplicate(res) {
+			t.Fatalf("result %d was not deduplicated", i)
+		}
+	}
+
+	// one hour of results, but only the last two overlap windows are kept
+	if limit := int(2 * streamResumeOverlap / (30 * time.Second)); len(filter.seen) > limit {
+		t.Errorf("seen results were not pruned: %d entries, expected at most %d", len(filter.seen), limit)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy determines how API calls are retried on transient failures,
+// i.e. rate limiting (429), gateway errors (502, 503, 504) and connection
+// level problems. Non-idempotent calls (POST, e.g. scheduling a measurement)
+// are only retried if the API explicitly refused to process them (429) or
+// if the connection could not even be established.
+type RetryPolicy struct {
+	MaxAttempts int           // total number of attempts, including the first one
+	BaseDelay   time.Duration // delay before the first retry, doubled for each next one
+	MaxDelay    time.Duration // upper limit for the (exponential) delay
+}
+
+// DefaultRetryPolicy returns the retry policy new clients use
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Second * 30,
+	}
+}
+
+// shouldRetry decides if another attempt is warranted after this response
+// or error, and if so, how long to wait before making it
+func (policy RetryPolicy) shouldRetry(
+	ctx context.Context,
+	method string,
+	attempt int,
+	resp *http.Response,
+	err error,
+) (time.Duration, bool) {
+	if attempt >= policy.MaxAttempts || ctx.Err() != nil {
+		return 0, false
+	}
+
+	idempotent := method != "POST" && method != "PATCH"
+
+	if err != nil {
+		if !isTransientError(err) {
+			return 0, false
+		}
+		if !idempotent && !isConnectError(err) {
+			// the request may have been processed, we can't know
+			return 0, false
+		}
+		return policy.backoff(attempt), true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		// safe even for non-idempotent calls
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if !idempotent {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	if after, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		// the server doesn't get to stall us beyond our own limit
+		if policy.MaxDelay > 0 && after > policy.MaxDelay {
+			after = policy.MaxDelay
+		}
+		return after, true
+	}
+	return policy.backoff(attempt), true
+}
+
+// backoff calculates the exponential delay with some jitter for this attempt
+func (policy RetryPolicy) backoff(attempt int) time.Duration {
+	delay := policy.BaseDelay
+	for i := 1; i < attempt && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// "equal jitter": somewhere between half and the full delay
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// parseRetryAfter understands both forms of Retry-After: seconds or HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		delay := time.Until(when)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// isTransientError tells if a connection level error is worth retrying
+func isTransientError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return isConnectError(err) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// isConnectError tells if the connection could not be established at all,
+// i.e. the request surely did not reach the API
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// sleepContext waits for the specified time, or until the context is cancelled
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
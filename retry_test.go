@@ -0,0 +1,199 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedServer responds with the scripted status codes in order, then
+// with success and the specified body
+type scriptedServer struct {
+	mu         sync.Mutex
+	script     []int
+	retryAfter string
+	body       string
+	calls      int
+}
+
+func (ss *scriptedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.calls++
+	if len(ss.script) > 0 {
+		status := ss.script[0]
+		ss.script = ss.script[1:]
+		if ss.retryAfter != "" {
+			w.Header().Set("Retry-After", ss.retryAfter)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"error":{"status":503,"title":"Service Unavailable","detail":"try later"}}`))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == "POST" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	_, _ = w.Write([]byte(ss.body))
+}
+
+func newScriptedClient(t *testing.T, ss *scriptedServer) *Client {
+	server := httptest.NewServer(ss)
+	t.Cleanup(server.Close)
+
+	client := NewClient()
+	client.SetAPIBase(server.URL + "/")
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+	return client
+}
+
+// Test if transient errors are retried for downloads
+func TestRetryTransientGet(t *testing.T) {
+	ss := &scriptedServer{
+		script: []int{503, 429, 502},
+		body:   `{"count":7,"next":"","previous":"","results":[]}`,
+	}
+	client := newScriptedClient(t, ss)
+
+	filter := client.NewProbeFilter()
+	count, err := filter.GetProbeCount()
+	if err != nil {
+		t.Fatalf("probe count failed despite retries: %v", err)
+	}
+	if count != 7 {
+		t.Errorf("probe count is %d, expected 7", count)
+	}
+	if ss.calls != 4 {
+		t.Errorf("API was called %d times, expected 4", ss.calls)
+	}
+}
+
+// Test if the attempt budget is observed
+func TestRetryBudget(t *testing.T) {
+	ss := &scriptedServer{
+		script: []int{503, 503, 503, 503, 503},
+		body:   `{"count":7,"next":"","previous":"","results":[]}`,
+	}
+	client := newScriptedClient(t, ss)
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+
+	filter := client.NewProbeFilter()
+	if _, err := filter.GetProbeCount(); err == nil {
+		t.Error("probe count should have failed")
+	}
+	if ss.calls != 2 {
+		t.Errorf("API was called %d times, expected 2", ss.calls)
+	}
+}
+
+// Test if Retry-After is honoured
+func TestRetryAfter(t *testing.T) {
+	ss := &scriptedServer{
+		script:     []int{429},
+		retryAfter: "1",
+		body:       `{"count":7,"next":"","previous":"","results":[]}`,
+	}
+	client := newScriptedClient(t, ss)
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second})
+
+	filter := client.NewProbeFilter()
+	start := time.Now()
+	if _, err := filter.GetProbeCount(); err != nil {
+		t.Fatalf("probe count failed despite retries: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Retry-After was not honoured, retried after %v", elapsed)
+	}
+}
+
+// Test if Retry-After is capped by the maximum delay
+func TestRetryAfterCapped(t *testing.T) {
+	ss := &scriptedServer{
+		script:     []int{429},
+		retryAfter: "3600",
+		body:       `{"count":7,"next":"","previous":"","results":[]}`,
+	}
+	client := newScriptedClient(t, ss)
+
+	filter := client.NewProbeFilter()
+	start := time.Now()
+	if _, err := filter.GetProbeCount(); err != nil {
+		t.Fatalf("probe count failed despite retries: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Retry-After was not capped, retried after %v", elapsed)
+	}
+}
+
+// Test if scheduling a measurement is only retried when it's safe
+func TestRetrySchedule(t *testing.T) {
+	newSpec := func(client *Client) *MeasurementSpec {
+		spec := client.NewMeasurementSpec()
+		_ = spec.AddProbesCountry("NL", 5)
+		_ = spec.AddPing("test", "ping.ripe.net", 4, nil, nil)
+		return spec
+	}
+
+	// a gateway error may or may not have been processed: don't retry
+	ss := &scriptedServer{
+		script: []int{503},
+		body:   `{"measurements":[1001]}`,
+	}
+	client := newScriptedClient(t, ss)
+	if _, err := newSpec(client).Schedule(); err == nil {
+		t.Error("schedule should have failed")
+	}
+	if ss.calls != 1 {
+		t.Errorf("schedule was tried %d times, expected 1", ss.calls)
+	}
+
+	// rate limiting means the request was not processed: retry
+	ss = &scriptedServer{
+		script: []int{429},
+		body:   `{"measurements":[1001]}`,
+	}
+	client = newScriptedClient(t, ss)
+	ids, err := newSpec(client).Schedule()
+	if err != nil || len(ids) != 1 || ids[0] != 1001 {
+		t.Errorf("schedule failed despite retries: %v %v", ids, err)
+	}
+	if ss.calls != 2 {
+		t.Errorf("schedule was tried %d times, expected 2", ss.calls)
+	}
+}
+
+// Test parsing of Retry-After values
+func TestParseRetryAfter(t *testing.T) {
+	if d, ok := parseRetryAfter("3"); !ok || d != 3*time.Second {
+		t.Errorf("Retry-After in seconds parsed incorrectly: %v %v", d, ok)
+	}
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if d, ok := parseRetryAfter(future); !ok || d <= 0 || d > time.Minute {
+		t.Errorf("Retry-After as a date parsed incorrectly: %v %v", d, ok)
+	}
+	if _, ok := parseRetryAfter("soon"); ok {
+		t.Error("bogus Retry-After accepted")
+	}
+}
+
+// Test if backoff grows and stays within limits
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 1; attempt < 10; attempt++ {
+		d := policy.backoff(attempt)
+		if d < 50*time.Millisecond || d > time.Second {
+			t.Errorf("backoff for attempt %d is out of range: %v", attempt, d)
+		}
+	}
+	if d := policy.backoff(4); d < 400*time.Millisecond {
+		t.Errorf("backoff does not grow exponentially: %v", d)
+	}
+}
@@ -7,6 +7,7 @@
 package goat
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -62,14 +63,21 @@ func (sc *StatusCheckResult) LongString() string {
 
 // StatusCheckFilter struct holds specified filters and other options
 type StatusCheckFilter struct {
+	client  *Client
 	params  url.Values
 	id      uint
 	showall bool
 }
 
-// NewStatusCheckFilter prepares a new status check filter object
+// NewStatusCheckFilter prepares a new status check filter object using the default client
 func NewStatusCheckFilter() StatusCheckFilter {
+	return defaultClient.NewStatusCheckFilter()
+}
+
+// NewStatusCheckFilter prepares a new status check filter object using this client
+func (client *Client) NewStatusCheckFilter() StatusCheckFilter {
 	sc := StatusCheckFilter{}
+	sc.client = client
 	sc.params = url.Values{}
 	return sc
 }
@@ -100,6 +108,16 @@ func (filter *StatusCheckFilter) verifyFilters() error {
 func (filter *StatusCheckFilter) StatusCheck(
 	verbose bool,
 	statuses chan AsyncStatusCheckResult,
+) {
+	filter.StatusCheckContext(context.Background(), verbose, statuses)
+}
+
+// StatusCheckContext is like StatusCheck, but it stops and closes the channel
+// when the context is cancelled
+func (filter *StatusCheckFilter) StatusCheckContext(
+	ctx context.Context,
+	verbose bool,
+	statuses chan AsyncStatusCheckResult,
 ) {
 	defer close(statuses)
 
@@ -108,43 +126,40 @@ func (filter *StatusCheckFilter) StatusCheck(
 	// sanity checks - late in the process, but not too late
 	err := filter.verifyFilters()
 	if err != nil {
-		statuses <- AsyncStatusCheckResult{&status, err}
+		sendContext(ctx, statuses, AsyncStatusCheckResult{&status, err})
 		return
 	}
 
 	// make the request
-	query := fmt.Sprintf("%smeasurements/%d/status-check?%s", apiBaseURL, filter.id, filter.params.Encode())
-	resp, err := apiGetRequest(verbose, query, nil)
+	client := clientOrDefault(filter.client)
+	query := fmt.Sprintf("%smeasurements/%d/status-check?%s", client.apiBaseURL, filter.id, filter.params.Encode())
+	resp, err := client.apiGetRequest(ctx, verbose, query, client.apiKeyFor(nil, ApiKeyDefault))
 	if err != nil {
-		statuses <- AsyncStatusCheckResult{&status, err}
+		sendContext(ctx, statuses, AsyncStatusCheckResult{&status, err})
 		return
 	}
 
-	// read the response - it is a single JSON
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		statuses <- AsyncStatusCheckResult{&status, err}
-		return
-	}
+	defer resp.Body.Close()
 
 	// check for error(s)
 	if resp.StatusCode != 200 {
-		var errors MultiErrorResponse
-		err = json.Unmarshal(data, &errors)
-		if err != nil {
-			statuses <- AsyncStatusCheckResult{&status, err}
-			return
-		}
-		statuses <- AsyncStatusCheckResult{&status, fmt.Errorf("%v", errors.Error.Detail)}
+		sendContext(ctx, statuses, AsyncStatusCheckResult{&status, parseAPIError(resp)})
+		return
+	}
+
+	// read the response - it is a single JSON
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		sendContext(ctx, statuses, AsyncStatusCheckResult{&status, err})
 		return
 	}
 
 	// parse the response into a status object
 	err = json.Unmarshal(data, &status)
 	if err != nil {
-		statuses <- AsyncStatusCheckResult{&status, err}
+		sendContext(ctx, statuses, AsyncStatusCheckResult{&status, err})
 		return
 	}
 
-	statuses <- AsyncStatusCheckResult{&status, nil}
+	sendContext(ctx, statuses, AsyncStatusCheckResult{&status, nil})
 }
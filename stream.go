@@ -0,0 +1,366 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/robert-kisteleki/goat/result"
+)
+
+// StreamSubscription describes what results to receive on a stream session.
+// The filters are applied by the streaming service.
+type StreamSubscription struct {
+	msm         uint
+	probe       uint
+	source      *netip.Addr
+	destination *netip.Addr
+	typ         string
+	backlog     bool
+}
+
+// AsyncStreamResult is a result from a stream session, along with the
+// subscription it belongs to
+type AsyncStreamResult struct {
+	Result       *result.Result
+	Subscription *StreamSubscription
+	Error        error
+}
+
+// StreamSession is one connection to the streaming API, with any number
+// of subscriptions on it
+type StreamSession struct {
+	client        *Client
+	verbose       bool
+	timeout       time.Duration
+	conn          *websocket.Conn
+	write         sync.Mutex // protects conn writes and subscriptions
+	subscriptions []*StreamSubscription
+	closing       bool
+}
+
+// NewStreamSubscription prepares a new subscription for results of a
+// measurement; 0 means any measurement (other filters should be used then)
+func NewStreamSubscription(msm uint) *StreamSubscription {
+	return &StreamSubscription{msm: msm}
+}
+
+// FilterProbe asks for results from this probe only
+func (sub *StreamSubscription) FilterProbe(id uint) {
+	sub.probe = id
+}
+
+// FilterSourceAddress asks for results from this (probe) address only
+func (sub *StreamSubscription) FilterSourceAddress(addr netip.Addr) {
+	sub.source = &addr
+}
+
+// FilterDestinationAddress asks for results towards this address only
+func (sub *StreamSubscription) FilterDestinationAddress(addr netip.Addr) {
+	sub.destination = &addr
+}
+
+// FilterType asks for results of this measurement type only
+func (sub *StreamSubscription) FilterType(typ string) {
+	sub.typ = typ
+}
+
+// SendBacklog turns on/off the request for the backlog
+func (sub *StreamSubscription) SendBacklog(backlog bool) {
+	sub.backlog = backlog
+}
+
+// Measurement returns the measurement ID this subscription is for
+func (sub *StreamSubscription) Measurement() uint {
+	return sub.msm
+}
+
+// String produces a short description of the subscription
+func (sub *StreamSubscription) String() string {
+	parts := make([]string, 0)
+	if sub.msm != 0 {
+		parts = append(parts, fmt.Sprintf("msm=%d", sub.msm))
+	}
+	if sub.probe != 0 {
+		parts = append(parts, fmt.Sprintf("prb=%d", sub.probe))
+	}
+	if sub.source != nil {
+		parts = append(parts, "src="+sub.source.String())
+	}
+	if sub.destination != nil {
+		parts = append(parts, "dst="+sub.destination.String())
+	}
+	if sub.typ != "" {
+		parts = append(parts, "type="+sub.typ)
+	}
+	return strings.Join(parts, ",")
+}
+
+// verify sanity of the subscription
+func (sub *StreamSubscription) verify() error {
+	if sub.msm == 0 && sub.probe == 0 && sub.source == nil && sub.destination == nil && sub.typ == "" {
+		return fmt.Errorf("subscription needs a measurement ID or some other filter")
+	}
+	if sub.typ != "" && !slices.Contains(MeasurementTypes, sub.typ) {
+		return fmt.Errorf("invalid measurement type: %s", sub.typ)
+	}
+	return nil
+}
+
+// params are what is sent to the server when (un)subscribing
+func (sub *StreamSubscription) params() map[string]any {
+	params := map[string]any{"streamType": "result"}
+	if sub.msm != 0 {
+		params["msm"] = sub.msm
+	}
+	if sub.probe != 0 {
+		params["prb"] = sub.probe
+	}
+	if sub.source != nil {
+		params["sourceAddress"] = sub.source.String()
+	}
+	if sub.destination != nil {
+		params["destinationAddress"] = sub.destination.String()
+	}
+	if sub.typ != "" {
+		params["type"] = sub.typ
+	}
+	if sub.backlog {
+		params["sendBacklog"] = true
+	}
+	return params
+}
+
+// matches checks if a result belongs to this subscription
+func (sub *StreamSubscription) matches(base *result.BaseResult) bool {
+	return (sub.msm == 0 || sub.msm == base.MeasurementID) &&
+		(sub.probe == 0 || sub.probe == base.ProbeID) &&
+		(sub.source == nil || *sub.source == base.FromAddr || *sub.source == base.SourceAddr) &&
+		(sub.destination == nil || (base.DestinationAddr != nil && *sub.destination == *base.DestinationAddr)) &&
+		(sub.typ == "" || sub.typ == base.Type)
+}
+
+// NewStreamSession prepares a new stream session using the default client
+func NewStreamSession() *StreamSession {
+	return defaultClient.NewStreamSession()
+}
+
+// NewStreamSession prepares a new stream session using this client
+func (client *Client) NewStreamSession() *StreamSession {
+	return &StreamSession{
+		client:  client,
+		timeout: time.Second * 60,
+	}
+}
+
+// Verbose sets verbosity
+func (session *StreamSession) Verbose(verbose bool) {
+	session.verbose = verbose
+}
+
+// Timeout sets the read timeout for the stream; 0 means no timeout
+func (session *StreamSession) Timeout(timeout time.Duration) {
+	session.timeout = timeout
+}
+
+// Subscriptions returns the current subscriptions
+func (session *StreamSession) Subscriptions() []*StreamSubscription {
+	session.write.Lock()
+	defer session.write.Unlock()
+	return slices.Clone(session.subscriptions)
+}
+
+// Subscribe adds a subscription; this can be done before or after Start()
+func (session *StreamSession) Subscribe(sub *StreamSubscription) error {
+	if err := sub.verify(); err != nil {
+		return err
+	}
+
+	session.write.Lock()
+	defer session.write.Unlock()
+
+	if slices.Contains(session.subscriptions, sub) {
+		return fmt.Errorf("already subscribed: %v", sub)
+	}
+	if session.conn != nil {
+		if err := session.send("atlas_subscribe", sub.params()); err != nil {
+			return err
+		}
+	}
+	session.subscriptions = append(session.subscriptions, sub)
+	return nil
+}
+
+// Unsubscribe removes a subscription
+func (session *StreamSession) Unsubscribe(sub *StreamSubscription) error {
+	session.write.Lock()
+	defer session.write.Unlock()
+
+	i := slices.Index(session.subscriptions, sub)
+	if i == -1 {
+		return fmt.Errorf("not subscribed: %v", sub)
+	}
+	if session.conn != nil {
+		if err := session.send("atlas_unsubscribe", sub.params()); err != nil {
+			return err
+		}
+	}
+	session.subscriptions = slices.Delete(session.subscriptions, i, i+1)
+	return nil
+}
+
+// send a message; the caller has to hold the write lock
+func (session *StreamSession) send(msgType string, params map[string]any) error {
+	if session.verbose {
+		fmt.Printf("# Stream %s: %v\n", msgType, params)
+	}
+	return session.conn.WriteJSON([]any{msgType, params})
+}
+
+// Start connects to the streaming API, sends the subscriptions so far and
+// delivers results on the channel until Close() is called or the
+// connection is lost. The channel is closed when the session ends.
+func (session *StreamSession) Start(results chan AsyncStreamResult) error {
+	return session.StartContext(context.Background(), results)
+}
+
+// StartContext is like Start, but the session also ends when the
+// context is cancelled
+func (session *StreamSession) StartContext(ctx context.Context, results chan AsyncStreamResult) error {
+	session.write.Lock()
+	defer session.write.Unlock()
+
+	if session.conn != nil {
+		return fmt.Errorf("stream session is already started")
+	}
+
+	client := clientOrDefault(session.client)
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, client.streamBaseURL+"?client=goat_"+version, nil)
+	if err != nil {
+		close(results)
+		return err
+	}
+	session.conn = conn
+	session.setDeadline()
+
+	for _, sub := range session.subscriptions {
+		if err := session.send("atlas_subscribe", sub.params()); err != nil {
+			conn.Close()
+			close(results)
+			return err
+		}
+	}
+
+	go session.receive(ctx, results)
+	return nil
+}
+
+// Close ends the session
+func (session *StreamSession) Close() error {
+	session.write.Lock()
+	defer session.write.Unlock()
+
+	if session.conn == nil {
+		return nil
+	}
+	session.closing = true
+	return session.conn.Close()
+}
+
+func (session *StreamSession) setDeadline() {
+	if session.timeout != 0 {
+		err := session.conn.SetReadDeadline(time.Now().Add(session.timeout))
+		if err != nil && session.verbose {
+			fmt.Printf("# WARNING: error setting read deadline: %v\n", err)
+		}
+	}
+}
+
+// receive handles messages coming from the stream
+func (session *StreamSession) receive(ctx context.Context, results chan AsyncStreamResult) {
+	defer close(results)
+	defer session.conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { session.conn.Close() })
+	defer stop()
+
+	for {
+		_, msg, err := session.conn.ReadMessage()
+		if err != nil {
+			session.write.Lock()
+			closing := session.closing
+			session.write.Unlock()
+			if ctx.Err() == nil && !closing {
+				sendContext(ctx, results, AsyncStreamResult{Error: fmt.Errorf("disconnected: %v", err)})
+			}
+			return
+		}
+		session.setDeadline()
+
+		var parts []json.RawMessage
+		var msgType string
+		if json.Unmarshal(msg, &parts) != nil || len(parts) != 2 || json.Unmarshal(parts[0], &msgType) != nil {
+			err := fmt.Errorf("unknown stream message received: %v", string(msg))
+			sendContext(ctx, results, AsyncStreamResult{Error: err})
+			return
+		}
+
+		switch msgType {
+		case "atlas_result":
+			res := session.processResult(string(parts[1]))
+			if !sendContext(ctx, results, res) {
+				return
+			}
+		case "atlas_subscribed", "atlas_unsubscribed", "atlas_backlog_sent":
+			if session.verbose {
+				fmt.Printf("# Stream %s: %s\n", msgType, string(parts[1]))
+			}
+		case "atlas_error":
+			// not fatal, the session goes on
+			err := fmt.Errorf("error message received from stream: %s", string(parts[1]))
+			if !sendContext(ctx, results, AsyncStreamResult{Error: err}) {
+				return
+			}
+		default:
+			err := fmt.Errorf("unknown stream message received: %v", string(msg))
+			sendContext(ctx, results, AsyncStreamResult{Error: err})
+			return
+		}
+	}
+}
+
+// processResult parses a result and finds the subscription it belongs to
+func (session *StreamSession) processResult(line string) AsyncStreamResult {
+	var base result.BaseResult
+	if err := base.Parse(line); err != nil {
+		return AsyncStreamResult{Error: err}
+	}
+
+	// a type hint makes parsing much faster
+	res, err := result.ParseWithTypeHint(line, base.Type)
+	if err != nil {
+		return AsyncStreamResult{Error: err}
+	}
+
+	session.write.Lock()
+	defer session.write.Unlock()
+
+	for _, sub := range session.subscriptions {
+		if sub.matches(&base) {
+			return AsyncStreamResult{Result: &res, Subscription: sub}
+		}
+	}
+	return AsyncStreamResult{Result: &res}
+}
@@ -0,0 +1,93 @@
+/*
+  (C) Robert Kisteleki & RIPE NCC
+
+  See LICENSE file for the license.
+*/
+
+package goat_test
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/robert-kisteleki/goat"
+	"github.com/robert-kisteleki/goat/goattest"
+)
+
+// Test if one session can have multiple, filtered subscriptions
+func TestStreamSession(t *testing.T) {
+	fake := goattest.New()
+	defer fake.Close()
+
+	session := fake.Client().NewStreamSession()
+	session.Timeout(5 * time.Second)
+
+	sub1 := goat.NewStreamSubscription(1001)
+	sub1.FilterProbe(1)
+	sub2 := goat.NewStreamSubscription(1002)
+	sub2.FilterDestinationAddress(netip.MustParseAddr("192.0.2.2"))
+	sub3 := goat.NewStreamSubscription(0)
+	sub3.FilterType("bogus")
+
+	if err := session.Subscribe(sub1); err != nil {
+		t.Fatalf("subscribing failed: %v", err)
+	}
+	if err := session.Subscribe(sub3); err == nil {
+		t.Error("invalid subscription was accepted")
+	}
+
+	results := make(chan goat.AsyncStreamResult)
+	if err := session.Start(results); err != nil {
+		t.Fatalf("starting the session failed: %v", err)
+	}
+	// subscribing after the start should work too
+	if err := session.Subscribe(sub2); err != nil {
+		t.Fatalf("subscribing failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	publish := func(msm, probe uint, dst string) {
+		line := fmt.Sprintf(`{"fw":5080,"type":"ping","msm_id":%d,"prb_id":%d,"dst_addr":"%s","timestamp":1700000000,"result":[]}`,
+			msm, probe, dst)
+		if err := fake.Publish(line); err != nil {
+			t.Fatalf("publishing failed: %v", err)
+		}
+	}
+	publish(1001, 2, "192.0.2.1") // filtered out
+	publish(1001, 1, "192.0.2.1")
+	publish(1002, 2, "192.0.2.1") // filtered out
+	publish(1003, 1, "192.0.2.2") // filtered out
+	publish(1002, 3, "192.0.2.2")
+
+	expect := []struct {
+		sub   *goat.StreamSubscription
+		probe uint
+	}{{sub1, 1}, {sub2, 3}}
+	for _, exp := range expect {
+		res := <-results
+		if res.Error != nil {
+			t.Fatalf("stream error: %v", res.Error)
+		}
+		if res.Subscription != exp.sub || (*res.Result).GetProbeID() != exp.probe {
+			t.Errorf("unexpected result for %v: %v", res.Subscription, *res.Result)
+		}
+	}
+
+	if err := session.Unsubscribe(sub1); err != nil {
+		t.Fatalf("unsubscribing failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	publish(1001, 1, "192.0.2.1") // no longer subscribed
+	publish(1002, 4, "192.0.2.2")
+	res := <-results
+	if res.Error != nil || res.Subscription != sub2 || (*res.Result).GetProbeID() != 4 {
+		t.Errorf("unexpected result after unsubscribing: %+v", res)
+	}
+
+	session.Close()
+	if res, ok := <-results; ok {
+		t.Errorf("expected a closed channel, got %+v", res)
+	}
+}
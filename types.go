@@ -7,9 +7,7 @@
 package goat
 
 import (
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -99,33 +97,3 @@ func (ut *uniTime) UnmarshalJSON(data []byte) error {
 func (ut uniTime) String() string {
 	return time.Time(ut).UTC().Format(time.RFC3339)
 }
-
-// something went wrong; see if the error page can be parsed
-// it could be a single error or a bunch of them
-func parseAPIError(resp *http.Response) error {
-	var err error
-
-	var decoded MultiErrorResponse
-	err = json.NewDecoder(resp.Body).Decode(&decoded)
-	if err != nil {
-		return err
-	}
-
-	r := make([]string, 0)
-	if decoded.Status != 0 {
-		r = append(r, decoded.Title)
-		for _, e := range decoded.Errors {
-			r = append(r, e.Detail)
-		}
-	}
-
-	if decoded.Error.Status != 0 {
-		r = append(r, decoded.Error.Title)
-		r = append(r, decoded.Error.Detail)
-		for _, e := range decoded.Error.Errors {
-			r = append(r, e.Detail)
-		}
-	}
-
-	return fmt.Errorf("%d %s %s", decoded.Status, decoded.Title, strings.Join(r, ", "))
-}
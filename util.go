@@ -7,11 +7,12 @@
 package goat
 
 import (
+	"context"
 	"fmt"
-	"net/http"
+	"iter"
 	"strings"
 
-	"github.com/google/uuid"
+	"github.com/robert-kisteleki/goat/result"
 )
 
 // Turn a slice of ints to a comma CSV string
@@ -19,28 +20,45 @@ func makeCsv(list []uint) string {
 	return strings.Trim(strings.Join(strings.Fields(fmt.Sprint(list)), ","), "[]")
 }
 
-func apiGetRequest(
-	verbose bool,
-	url string,
-	key *uuid.UUID,
-) (*http.Response, error) {
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", uaString)
-	if key != nil {
-		req.Header.Set("Authorization", "Key "+(*key).String())
+// sendContext puts an item on a channel, unless the context is cancelled
+// while waiting for the receiver; returns false in the latter case
+func sendContext[T any](ctx context.Context, channel chan T, item T) bool {
+	select {
+	case channel <- item:
+		return true
+	case <-ctx.Done():
+		return false
 	}
+}
+
+// iterate turns a channel based listing into an iterator. Breaking out of
+// the loop cancels the listing, and waits for it to finish.
+func iterate[A any, T any](
+	ctx context.Context,
+	list func(context.Context, chan A),
+	split func(A) (T, error),
+) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
 
-	if verbose {
-		msg := fmt.Sprintf("# API call: GET %s", url)
-		if key != nil {
-			msg += fmt.Sprintf(" (using API key %s...)", (*key).String()[:8])
+		items := make(chan A)
+		go list(ctx, items)
+		for item := range items {
+			if !yield(split(item)) {
+				cancel()
+				for range items {
+				}
+				return
+			}
 		}
-		fmt.Println(msg)
 	}
-	client := &http.Client{}
+}
 
-	return client.Do(req)
+// splitResult unpacks an AsyncResult for iterators
+func splitResult(item result.AsyncResult) (result.Result, error) {
+	if item.Result == nil {
+		return nil, item.Error
+	}
+	return *item.Result, item.Error
 }